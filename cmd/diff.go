@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/paullesiak/policyparser/pkg/diff"
+	"github.com/paullesiak/policyparser/pkg/parser"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// runDiff implements `policyparser diff [flags] old new`, which reports the
+// permissions the new policy file adds and removes.
+func runDiff(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	escaped := fs.Bool("escaped", false, "the policy files are URL encoded")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("diff needs two policy files, got %d", fs.NArg())
+	}
+
+	before, err := diffDocument(fs.Arg(0), *escaped)
+	if err != nil {
+		return err
+	}
+	after, err := diffDocument(fs.Arg(1), *escaped)
+	if err != nil {
+		return err
+	}
+
+	result := diff.Policies(before, after)
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "text":
+		fmt.Fprint(out, result)
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	return nil
+}
+
+func diffDocument(filename string, escaped bool) ([]*policy.Policy, error) {
+	text, err := readPolicyText(filename)
+	if err != nil {
+		return nil, err
+	}
+	p, err := parser.NewParser(parser.Aws, string(text), escaped)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Parse(); err != nil {
+		return nil, fmt.Errorf("parse %q: %w", filename, err)
+	}
+	return p.GetPolicy()
+}
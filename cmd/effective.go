@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/paullesiak/policyparser/pkg/effective"
+	"github.com/paullesiak/policyparser/pkg/eval"
+	"github.com/paullesiak/policyparser/pkg/parser"
+)
+
+// runEffective implements `policyparser effective [flags] details`, which
+// reports what a user or role of an authorization details dump is effectively
+// allowed to do, per service.
+func runEffective(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("effective", flag.ContinueOnError)
+	principal := fs.String("principal", "", "ARN of the user or role")
+	format := fs.String("format", "table", "output format: table or json")
+	escaped := fs.Bool("escaped", false, "the SCP files are URL encoded")
+	var scps []string
+	fs.Func("scp", "policy file of a service control policy that applies to the account; repeatable", func(s string) error {
+		scps = append(scps, s)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *principal == "" {
+		return fmt.Errorf("effective needs -principal and one authorization details file")
+	}
+
+	details, err := parser.ImportAuthorizationDetails(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	sets, err := effective.SetsFor(details, *principal)
+	if err != nil {
+		return err
+	}
+	for _, scp := range scps {
+		policies, err := diffDocument(scp, *escaped)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, eval.PolicySet{Type: eval.ServiceControlPolicy, Name: scp, Policies: policies})
+	}
+
+	report := effective.Analyze(sets)
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "table":
+		fmt.Fprint(out, report)
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	log "github.com/paullesiak/policyparser/internal/logger"
+	"github.com/paullesiak/policyparser/pkg/cloudtrail"
+	"github.com/paullesiak/policyparser/pkg/generate"
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/paullesiak/policyparser/pkg/render"
+)
+
+// runGenerate implements `policyparser generate [flags] log...`, which writes
+// the policy that allows what CloudTrail recorded for a principal. With
+// -current it reports how the principal's current policy differs from it
+// instead, and the permissions the diff removes are unused.
+func runGenerate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	principal := fs.String("principal", "", "ARN or account id of the principal")
+	start := fs.String("start", "", "start of the time window, a date or RFC 3339 time")
+	end := fs.String("end", "", "end of the time window, exclusive")
+	current := fs.String("current", "", "policy file of the principal to diff against")
+	output := fs.String("output", "", "also write the generated policy to this file")
+	format := fs.String("format", "text", "output format of the diff: text or json")
+	includeDenied := fs.Bool("include-denied", false, "also allow calls that were denied")
+	maxResources := fs.Int("max-resources", 0, "replace more resources than this per action with a wildcard")
+	escaped := fs.Bool("escaped", false, "the policy file is URL encoded")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("generate needs CloudTrail log files or directories")
+	}
+
+	filter := cloudtrail.Filter{Principal: *principal}
+	var err error
+	if filter.Start, err = parseTime(*start); err != nil {
+		return err
+	}
+	if filter.End, err = parseTime(*end); err != nil {
+		return err
+	}
+	events, err := cloudtrail.ReadFiles(fs.Args(), filter)
+	if err != nil {
+		return err
+	}
+
+	generated, unmapped := generate.Policy(events, generate.Options{IncludeDenied: *includeDenied, MaxResources: *maxResources})
+	for _, err := range unmapped {
+		log.Warnf("left out: %v", err)
+	}
+	data, err := render.Aws(generated).Json()
+	if err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := os.WriteFile(*output, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("write %q: %w", *output, err)
+		}
+	}
+	if *current == "" {
+		fmt.Fprintln(out, string(data))
+		return nil
+	}
+
+	policies, err := diffDocument(*current, *escaped)
+	if err != nil {
+		return err
+	}
+	result := generate.Unused(policies, generated)
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "text":
+		fmt.Fprint(out, result)
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, ok := policy.ParseDate(s)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%q is not a date or RFC 3339 time", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/paullesiak/policyparser/pkg/lint"
+	"github.com/paullesiak/policyparser/pkg/parser"
+)
+
+// runLint implements `policyparser lint [flags] [file...]`. The files are
+// linted together, as the documents attached to one principal. Without files
+// it lints the policyFile of config.yaml.
+func runLint(args []string, out io.Writer) error {
+	configureDefaults()
+	if err := readConfig(); err != nil && !errors.As(err, &viper.ConfigFileNotFoundError{}) {
+		return err
+	}
+
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	configFile := fs.String("config", "", "lint configuration file, by default the lint section of config.yaml")
+	escaped := fs.Bool("escaped", viper.GetBool("urlEscaped"), "the policy files are URL encoded, by default urlEscaped of config.yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := lintConfig(*configFile)
+	if err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{viper.GetString("policyFile")}
+	}
+	var docs []lint.Document
+	for _, filename := range files {
+		doc, err := lintDocument(filename, *escaped)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+
+	findings := lint.New(cfg).Run(docs...)
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "text":
+		for _, f := range findings {
+			fmt.Fprintf(out, "%s %s\n    %s\n", f.Severity, f, f.Remediation)
+		}
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+
+	errorCount := 0
+	for _, f := range findings {
+		if f.Severity == lint.SeverityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("lint found %d errors", errorCount)
+	}
+	return nil
+}
+
+func lintConfig(filename string) (lint.Config, error) {
+	if filename == "" {
+		var cfg lint.Config
+		if err := viper.UnmarshalKey("lint", &cfg); err != nil {
+			return lint.Config{}, fmt.Errorf("read lint config: %w", err)
+		}
+		return cfg, cfg.Validate()
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return lint.Config{}, fmt.Errorf("open lint config %q: %w", filename, err)
+	}
+	defer f.Close()
+	return lint.LoadConfig(f)
+}
+
+func lintDocument(filename string, escaped bool) (lint.Document, error) {
+	text, err := readPolicyText(filename)
+	if err != nil {
+		return lint.Document{}, err
+	}
+	p, err := parser.NewParser(parser.Aws, string(text), escaped)
+	if err != nil {
+		return lint.Document{}, err
+	}
+	if err := p.Parse(); err != nil {
+		return lint.Document{}, fmt.Errorf("parse %q: %w", filename, err)
+	}
+	policies, err := p.GetPolicy()
+	if err != nil {
+		return lint.Document{}, err
+	}
+	return lint.Document{Name: filename, Policies: policies, Positions: parser.Positions(p)}, nil
+}
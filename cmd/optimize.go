@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/paullesiak/policyparser/pkg/optimize"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// runOptimize implements `policyparser optimize [flags] file...`, which
+// rewrites the policies of the files as few documents as fit the size limit.
+func runOptimize(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("optimize", flag.ContinueOnError)
+	limit := fs.Int("limit", optimize.ManagedPolicyLimit, "largest document size in characters")
+	collapse := fs.Bool("collapse-actions", false, "replace action lists with wildcards although the catalog is partial")
+	format := fs.String("format", "text", "output format: text or json")
+	escaped := fs.Bool("escaped", false, "the policy files are URL encoded")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("optimize needs at least one policy file")
+	}
+
+	var policies []*policy.Policy
+	for _, path := range fs.Args() {
+		read, err := diffDocument(path, *escaped)
+		if err != nil {
+			return err
+		}
+		policies = append(policies, read...)
+	}
+
+	res, err := optimize.Optimize(policies, optimize.Options{Limit: *limit, CollapseActions: *collapse})
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "text":
+		for i, doc := range res.Documents {
+			data, err := doc.Aws.Json()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "# document %d of %d, %d characters\n%s\n", i+1, len(res.Documents), doc.Size, data)
+		}
+		fmt.Fprintf(out, "# %d characters, %d before\n", res.Size, res.OriginalSize)
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	return nil
+}
@@ -12,6 +12,31 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		var command func([]string, io.Writer) error
+		switch os.Args[1] {
+		case "lint":
+			command = runLint
+		case "diff":
+			command = runDiff
+		case "generate":
+			command = runGenerate
+		case "usage":
+			command = runUsage
+		case "effective":
+			command = runEffective
+		case "trust":
+			command = runTrust
+		case "optimize":
+			command = runOptimize
+		}
+		if command != nil {
+			if err := command(os.Args[2:], os.Stdout); err != nil {
+				log.Fatalf("%v", err)
+			}
+			return
+		}
+	}
 	if err := run(); err != nil {
 		log.Fatalf("%v", err)
 	}
@@ -69,7 +94,7 @@ func run() error {
 }
 
 func configureDefaults() {
-	viper.SetDefault("cloud", "aws")
+	viper.SetDefault("cloud", parser.Auto)
 	viper.SetDefault("policyFile", "awspolicy.json")
 	viper.SetDefault("urlEscaped", true)
 	viper.SetDefault("outputFile", "parsed.json")
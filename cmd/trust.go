@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/paullesiak/policyparser/pkg/arn"
+	"github.com/paullesiak/policyparser/pkg/parser"
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/paullesiak/policyparser/pkg/trust"
+)
+
+// trustReport holds what was found in the trust policy of one role.
+type trustReport struct {
+	Document    string              `json:"document"` // file name, or role ARN for authorization details
+	Diagnostics []policy.Diagnostic `json:"diagnostics,omitempty"`
+	Findings    []trust.Finding     `json:"findings"`
+}
+
+// runTrust implements `policyparser trust [flags] file...`, which checks role
+// trust policy files, or with -details the trust policy of every role of
+// authorization details dumps.
+func runTrust(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("trust", flag.ContinueOnError)
+	account := fs.String("account", "", "account that owns the roles; by default that of the role ARN with -details")
+	details := fs.Bool("details", false, "the files are authorization details dumps")
+	format := fs.String("format", "text", "output format: text or json")
+	escaped := fs.Bool("escaped", false, "the policy files are URL encoded")
+	var trusted []string
+	fs.Func("trusted-account", "account whose principals are not third parties; repeatable", func(s string) error {
+		trusted = append(trusted, s)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("trust needs trust policy files")
+	}
+
+	var reports []trustReport
+	for _, filename := range fs.Args() {
+		if *details {
+			r, err := trustDetails(filename, *account, trusted)
+			if err != nil {
+				return err
+			}
+			reports = append(reports, r...)
+			continue
+		}
+		r, err := trustDocument(filename, *escaped, trust.Config{Account: *account, TrustedAccounts: trusted})
+		if err != nil {
+			return err
+		}
+		reports = append(reports, r)
+	}
+
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "text":
+		for _, r := range reports {
+			for _, d := range r.Diagnostics {
+				fmt.Fprintf(out, "%s: %s\n", r.Document, d)
+			}
+			for _, f := range r.Findings {
+				fmt.Fprintf(out, "%s: %s\n", r.Document, f)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+
+	errorCount := 0
+	for _, r := range reports {
+		for _, f := range r.Findings {
+			if f.Severity == trust.SeverityError {
+				errorCount++
+			}
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("trust found %d errors", errorCount)
+	}
+	return nil
+}
+
+func trustDocument(filename string, escaped bool, cfg trust.Config) (trustReport, error) {
+	text, err := readPolicyText(filename)
+	if err != nil {
+		return trustReport{}, err
+	}
+	p, err := parser.NewParser(parser.AwsTrust, string(text), escaped)
+	if err != nil {
+		return trustReport{}, err
+	}
+	if err := p.Parse(); err != nil {
+		return trustReport{}, fmt.Errorf("parse %q: %w", filename, err)
+	}
+	policies, err := p.GetPolicy()
+	if err != nil {
+		return trustReport{}, err
+	}
+	return trustReport{Document: filename, Diagnostics: parser.Diagnostics(p), Findings: trust.Analyze(policies, cfg)}, nil
+}
+
+// trustDetails checks the trust policy of every role of an authorization
+// details dump, reporting the diagnostics of parsing it as a trust policy. Each role is owned by the account of its ARN unless account
+// is set.
+func trustDetails(filename, account string, trusted []string) ([]trustReport, error) {
+	details, err := parser.ImportAuthorizationDetails(filename)
+	if err != nil {
+		return nil, err
+	}
+	var out []trustReport
+	for _, e := range details.Entities {
+		var policies []*policy.Policy
+		for _, p := range details.PoliciesFor(e.Arn) {
+			if p.Source.Relation == policy.RelationTrust {
+				policies = append(policies, p)
+			}
+		}
+		if len(policies) == 0 {
+			continue
+		}
+		cfg := trust.Config{Account: account, TrustedAccounts: trusted}
+		if a, err := arn.Parse(e.Arn); err == nil && cfg.Account == "" {
+			cfg.Account = a.Account
+		}
+		out = append(out, trustReport{Document: e.Arn, Diagnostics: e.TrustDiagnostics, Findings: trust.Analyze(policies, cfg)})
+	}
+	return out, nil
+}
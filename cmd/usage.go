@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/cloudtrail"
+	"github.com/paullesiak/policyparser/pkg/usage"
+)
+
+// runUsage implements `policyparser usage [flags] policy access...`, which
+// reports how often each statement, action and resource of a policy file was
+// used according to CloudTrail logs, or CSV files of action,resource,time rows.
+func runUsage(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	principal := fs.String("principal", "", "ARN or account id of the principal in CloudTrail logs")
+	start := fs.String("start", "", "start of the time window, a date or RFC 3339 time")
+	end := fs.String("end", "", "end of the time window, exclusive")
+	format := fs.String("format", "text", "output format: text or json")
+	escaped := fs.Bool("escaped", false, "the policy file is URL encoded")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage needs a policy file and access logs")
+	}
+
+	filter := cloudtrail.Filter{Principal: *principal}
+	var err error
+	if filter.Start, err = parseTime(*start); err != nil {
+		return err
+	}
+	if filter.End, err = parseTime(*end); err != nil {
+		return err
+	}
+
+	policies, err := diffDocument(fs.Arg(0), *escaped)
+	if err != nil {
+		return err
+	}
+	var accesses []usage.Access
+	var logs []string
+	for _, path := range fs.Args()[1:] {
+		if !strings.HasSuffix(strings.ToLower(path), ".csv") {
+			logs = append(logs, path)
+			continue
+		}
+		read, err := readAccessCSV(path)
+		if err != nil {
+			return err
+		}
+		// CSV rows name no principal; only the time window applies.
+		window := cloudtrail.Filter{Start: filter.Start, End: filter.End}
+		for _, a := range read {
+			if a.Time.IsZero() || window.Match(cloudtrail.Event{Time: a.Time}) {
+				accesses = append(accesses, a)
+			}
+		}
+	}
+	if len(logs) > 0 {
+		events, err := cloudtrail.ReadFiles(logs, filter)
+		if err != nil {
+			return err
+		}
+		accesses = append(accesses, usage.FromEvents(events)...)
+	}
+
+	report := usage.Analyze(policies, accesses)
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+	case "text":
+		fmt.Fprint(out, report)
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+	return nil
+}
+
+func readAccessCSV(path string) ([]usage.Access, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open access file %q: %w", path, err)
+	}
+	defer file.Close()
+	accesses, err := usage.ReadCSV(file)
+	if err != nil {
+		return nil, fmt.Errorf("read access file %q: %w", path, err)
+	}
+	return accesses, nil
+}
@@ -0,0 +1,253 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// accountAuthorizationDetails mirrors the output of
+// `aws iam get-account-authorization-details`. Policy documents are kept raw,
+// since the CLI emits JSON objects while the raw API returns URL-encoded strings.
+type accountAuthorizationDetails struct {
+	UserDetailList []struct {
+		Arn                     string           `json:"Arn"`
+		UserName                string           `json:"UserName"`
+		GroupList               []string         `json:"GroupList"`
+		UserPolicyList          []inlinePolicy   `json:"UserPolicyList"`
+		AttachedManagedPolicies []attachedPolicy `json:"AttachedManagedPolicies"`
+		PermissionsBoundary     *boundary        `json:"PermissionsBoundary"`
+	} `json:"UserDetailList"`
+	GroupDetailList []struct {
+		Arn                     string           `json:"Arn"`
+		GroupName               string           `json:"GroupName"`
+		GroupPolicyList         []inlinePolicy   `json:"GroupPolicyList"`
+		AttachedManagedPolicies []attachedPolicy `json:"AttachedManagedPolicies"`
+	} `json:"GroupDetailList"`
+	RoleDetailList []struct {
+		Arn                      string           `json:"Arn"`
+		RoleName                 string           `json:"RoleName"`
+		AssumeRolePolicyDocument json.RawMessage  `json:"AssumeRolePolicyDocument"`
+		RolePolicyList           []inlinePolicy   `json:"RolePolicyList"`
+		AttachedManagedPolicies  []attachedPolicy `json:"AttachedManagedPolicies"`
+		PermissionsBoundary      *boundary        `json:"PermissionsBoundary"`
+	} `json:"RoleDetailList"`
+	Policies []struct {
+		Arn               string `json:"Arn"`
+		PolicyName        string `json:"PolicyName"`
+		DefaultVersionId  string `json:"DefaultVersionId"`
+		AttachmentCount   int    `json:"AttachmentCount"`
+		BoundaryCount     int    `json:"PermissionsBoundaryUsageCount"`
+		PolicyVersionList []struct {
+			Document         json.RawMessage `json:"Document"`
+			VersionId        string          `json:"VersionId"`
+			IsDefaultVersion bool            `json:"IsDefaultVersion"`
+		} `json:"PolicyVersionList"`
+	} `json:"Policies"`
+}
+
+type inlinePolicy struct {
+	PolicyName     string          `json:"PolicyName"`
+	PolicyDocument json.RawMessage `json:"PolicyDocument"`
+}
+
+type attachedPolicy struct {
+	PolicyName string `json:"PolicyName"`
+	PolicyArn  string `json:"PolicyArn"`
+}
+
+type boundary struct {
+	PermissionsBoundaryArn  string `json:"PermissionsBoundaryArn"`
+	PermissionsBoundaryType string `json:"PermissionsBoundaryType"`
+}
+
+// Entity types found in an authorization details dump.
+const (
+	EntityUser   = "user"
+	EntityGroup  = "group"
+	EntityRole   = "role"
+	EntityPolicy = "policy"
+)
+
+// Entity is a user, group or role from an authorization details dump together
+// with what is attached to it.
+type Entity struct {
+	Arn                 string   `json:"arn" yaml:"arn"`
+	Name                string   `json:"name" yaml:"name"`
+	Type                string   `json:"type" yaml:"type"`                                                     // user, group or role
+	Groups              []string `json:"groups,omitempty" yaml:"groups,omitempty"`                             // ARNs of the groups a user belongs to
+	ManagedPolicies     []string `json:"managed-policies,omitempty" yaml:"managed-policies,omitempty"`         // ARNs of attached managed policies
+	PermissionsBoundary string   `json:"permissions-boundary,omitempty" yaml:"permissions-boundary,omitempty"` // ARN of the boundary policy
+	// TrustDiagnostics are the problems found in the trust policy of a role,
+	// which is parsed as one; see NewAwsTrustPolicyParser.
+	TrustDiagnostics []policy.Diagnostic `json:"trust-diagnostics,omitempty" yaml:"trust-diagnostics,omitempty"`
+}
+
+// AuthorizationDetails is the result of importing an authorization details dump.
+// Every policy carries a policy.Source naming the entity it applies to.
+type AuthorizationDetails struct {
+	Policies []*policy.Policy `json:"policies" yaml:"policies"`
+	Entities []Entity         `json:"entities" yaml:"entities"`
+}
+
+// PoliciesFor returns the policies whose source entity is arn.
+func (d *AuthorizationDetails) PoliciesFor(arn string) []*policy.Policy {
+	var out []*policy.Policy
+	for _, p := range d.Policies {
+		if p.Source != nil && p.Source.Entity == arn {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ImportAuthorizationDetails reads the JSON output of GetAccountAuthorizationDetails
+// and parses every embedded policy document. Documents that fail to parse are
+// skipped; their errors are joined into the returned error alongside the
+// documents that did parse.
+func ImportAuthorizationDetails(r io.Reader) (*AuthorizationDetails, error) {
+	var dump accountAuthorizationDetails
+	if err := json.NewDecoder(r).Decode(&dump); err != nil {
+		return nil, fmt.Errorf("error decoding authorization details: %w", err)
+	}
+
+	details := &AuthorizationDetails{}
+	var errs []error
+	add := func(doc json.RawMessage, src policy.Source) []policy.Diagnostic {
+		policies, diagnostics, err := parseEmbeddedDocument(doc, src.Relation == policy.RelationTrust)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s policy %q of %s: %w", src.Relation, src.PolicyName, src.Entity, err))
+			return nil
+		}
+		for _, p := range policies {
+			s := src
+			p.Source = &s
+			details.Policies = append(details.Policies, p)
+		}
+		return diagnostics
+	}
+
+	type managedVersion struct {
+		name     string
+		document json.RawMessage
+		version  string
+	}
+	defaults := map[string]managedVersion{}
+	for _, p := range dump.Policies {
+		for _, v := range p.PolicyVersionList {
+			if v.IsDefaultVersion || v.VersionId == p.DefaultVersionId {
+				defaults[p.Arn] = managedVersion{name: p.PolicyName, document: v.Document, version: v.VersionId}
+				if p.AttachmentCount == 0 && p.BoundaryCount == 0 {
+					add(v.Document, policy.Source{Entity: p.Arn, EntityType: EntityPolicy, Relation: policy.RelationUnattached, PolicyName: p.PolicyName, PolicyArn: p.Arn, PolicyVersion: v.VersionId})
+				}
+				continue
+			}
+			add(v.Document, policy.Source{Entity: p.Arn, EntityType: EntityPolicy, Relation: policy.RelationPolicyVersion, PolicyName: p.PolicyName, PolicyArn: p.Arn, PolicyVersion: v.VersionId})
+		}
+	}
+
+	addManaged := func(entity, entityType, relation, policyArn, policyName string) {
+		v, ok := defaults[policyArn]
+		if !ok {
+			// AWS managed policies are only present when the dump was requested
+			// with the AWSManagedPolicy filter.
+			return
+		}
+		add(v.document, policy.Source{Entity: entity, EntityType: entityType, Relation: relation, PolicyName: policyName, PolicyArn: policyArn, PolicyVersion: v.version})
+	}
+
+	groupArns := map[string]string{}
+	for _, g := range dump.GroupDetailList {
+		groupArns[g.GroupName] = g.Arn
+		entity := Entity{Arn: g.Arn, Name: g.GroupName, Type: EntityGroup}
+		for _, p := range g.GroupPolicyList {
+			add(p.PolicyDocument, policy.Source{Entity: g.Arn, EntityType: EntityGroup, Relation: policy.RelationInline, PolicyName: p.PolicyName})
+		}
+		for _, p := range g.AttachedManagedPolicies {
+			entity.ManagedPolicies = append(entity.ManagedPolicies, p.PolicyArn)
+			addManaged(g.Arn, EntityGroup, policy.RelationManaged, p.PolicyArn, p.PolicyName)
+		}
+		details.Entities = append(details.Entities, entity)
+	}
+
+	for _, u := range dump.UserDetailList {
+		entity := Entity{Arn: u.Arn, Name: u.UserName, Type: EntityUser}
+		for _, g := range u.GroupList {
+			if arn, ok := groupArns[g]; ok {
+				entity.Groups = append(entity.Groups, arn)
+			}
+		}
+		for _, p := range u.UserPolicyList {
+			add(p.PolicyDocument, policy.Source{Entity: u.Arn, EntityType: EntityUser, Relation: policy.RelationInline, PolicyName: p.PolicyName})
+		}
+		for _, p := range u.AttachedManagedPolicies {
+			entity.ManagedPolicies = append(entity.ManagedPolicies, p.PolicyArn)
+			addManaged(u.Arn, EntityUser, policy.RelationManaged, p.PolicyArn, p.PolicyName)
+		}
+		if u.PermissionsBoundary != nil && u.PermissionsBoundary.PermissionsBoundaryArn != "" {
+			entity.PermissionsBoundary = u.PermissionsBoundary.PermissionsBoundaryArn
+			addManaged(u.Arn, EntityUser, policy.RelationPermissionsBoundary, entity.PermissionsBoundary, defaults[entity.PermissionsBoundary].name)
+		}
+		details.Entities = append(details.Entities, entity)
+	}
+
+	for _, r := range dump.RoleDetailList {
+		entity := Entity{Arn: r.Arn, Name: r.RoleName, Type: EntityRole}
+		if len(r.AssumeRolePolicyDocument) > 0 {
+			entity.TrustDiagnostics = add(r.AssumeRolePolicyDocument, policy.Source{Entity: r.Arn, EntityType: EntityRole, Relation: policy.RelationTrust})
+		}
+		for _, p := range r.RolePolicyList {
+			add(p.PolicyDocument, policy.Source{Entity: r.Arn, EntityType: EntityRole, Relation: policy.RelationInline, PolicyName: p.PolicyName})
+		}
+		for _, p := range r.AttachedManagedPolicies {
+			entity.ManagedPolicies = append(entity.ManagedPolicies, p.PolicyArn)
+			addManaged(r.Arn, EntityRole, policy.RelationManaged, p.PolicyArn, p.PolicyName)
+		}
+		if r.PermissionsBoundary != nil && r.PermissionsBoundary.PermissionsBoundaryArn != "" {
+			entity.PermissionsBoundary = r.PermissionsBoundary.PermissionsBoundaryArn
+			addManaged(r.Arn, EntityRole, policy.RelationPermissionsBoundary, entity.PermissionsBoundary, defaults[entity.PermissionsBoundary].name)
+		}
+		details.Entities = append(details.Entities, entity)
+	}
+
+	return details, errors.Join(errs...)
+}
+
+// parseEmbeddedDocument parses a policy document that is either a JSON object or
+// a JSON string holding the URL-encoded document, as a role trust policy when
+// trust is set.
+func parseEmbeddedDocument(doc json.RawMessage, trust bool) ([]*policy.Policy, []policy.Diagnostic, error) {
+	text := string(bytes.TrimSpace(doc))
+	if text == "" || text == "null" {
+		return nil, nil, fmt.Errorf("empty policy document")
+	}
+	if text[0] == '"' {
+		var s string
+		if err := json.Unmarshal(doc, &s); err != nil {
+			return nil, nil, fmt.Errorf("error decoding policy document: %w", err)
+		}
+		unescaped, err := recursiveUnescape(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error unescaping policy text: %w", err)
+		}
+		text = unescaped
+	}
+
+	newParser := NewAwsPolicyParser
+	if trust {
+		newParser = NewAwsTrustPolicyParser
+	}
+	a, err := newParser(text, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := a.Parse(); err != nil {
+		return nil, nil, err
+	}
+	policies, err := a.GetPolicy()
+	return policies, a.Diagnostics(), err
+}
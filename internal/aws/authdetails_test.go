@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportAuthorizationDetails(t *testing.T) {
+	f, err := os.Open("testdata/authorization-details.json")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	details, err := ImportAuthorizationDetails(f)
+	require.NoError(t, err)
+
+	require.Len(t, details.Entities, 3)
+	group, user, role := details.Entities[0], details.Entities[1], details.Entities[2]
+	require.Equal(t, EntityGroup, group.Type)
+	require.Equal(t, EntityUser, user.Type)
+	require.Equal(t, []string{"arn:aws:iam::123456789012:group/admins"}, user.Groups)
+	require.Equal(t, []string{"arn:aws:iam::123456789012:policy/ReadOnlyS3"}, user.ManagedPolicies)
+	require.Equal(t, "arn:aws:iam::123456789012:policy/Boundary", user.PermissionsBoundary)
+	require.Equal(t, EntityRole, role.Type)
+
+	userPolicies := details.PoliciesFor(user.Arn)
+	require.Len(t, userPolicies, 3)
+	relations := map[string]*policy.Policy{}
+	for _, p := range userPolicies {
+		relations[p.Source.Relation] = p
+	}
+	require.Equal(t, []string{"s3:ListAllMyBuckets"}, relations[policy.RelationInline].Actions)
+	require.Equal(t, "alice-inline", relations[policy.RelationInline].Source.PolicyName)
+	require.Equal(t, "Read", relations[policy.RelationManaged].Id)
+	require.Equal(t, "v2", relations[policy.RelationManaged].Source.PolicyVersion)
+	require.Equal(t, "arn:aws:iam::123456789012:policy/ReadOnlyS3", relations[policy.RelationManaged].Source.PolicyArn)
+	require.Equal(t, []string{"s3:<.*>", "sqs:<.*>"}, relations[policy.RelationPermissionsBoundary].Actions)
+
+	rolePolicies := details.PoliciesFor(role.Arn)
+	require.Len(t, rolePolicies, 3)
+	require.Equal(t, policy.RelationTrust, rolePolicies[0].Source.Relation)
+	require.Equal(t, []string{"ec2.amazonaws.com"}, rolePolicies[0].Subjects)
+	require.Equal(t, []string{"sts:AssumeRole"}, rolePolicies[0].Actions)
+	require.Equal(t, policy.RelationInline, rolePolicies[1].Source.Relation)
+	require.Equal(t, []string{"sqs:SendMessage", "sqs:ReceiveMessage"}, rolePolicies[1].Actions)
+
+	versions := details.PoliciesFor("arn:aws:iam::123456789012:policy/ReadOnlyS3")
+	require.Len(t, versions, 1)
+	require.Equal(t, policy.RelationPolicyVersion, versions[0].Source.Relation)
+	require.Equal(t, "v1", versions[0].Source.PolicyVersion)
+
+	require.Empty(t, details.PoliciesFor("arn:aws:iam::123456789012:policy/Boundary"))
+	require.Empty(t, role.TrustDiagnostics)
+}
+
+func TestImportAuthorizationDetailsTrustDiagnostics(t *testing.T) {
+	dump := `{"RoleDetailList": [{
+		"Arn": "arn:aws:iam::123456789012:role/app",
+		"RoleName": "app",
+		"AssumeRolePolicyDocument": {"Statement": [{"Effect": "Allow", "Principal": {"Service": "ec2.amazonaws.com"}, "Action": "sts:AssumeRole", "Resource": "*"}]},
+		"RolePolicyList": [{"PolicyName": "ok", "PolicyDocument": {"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}}]
+	}]}`
+	details, err := ImportAuthorizationDetails(strings.NewReader(dump))
+	require.NoError(t, err)
+	require.Len(t, details.Entities, 1)
+	require.Len(t, details.Entities[0].TrustDiagnostics, 1)
+	require.Contains(t, details.Entities[0].TrustDiagnostics[0].Message, "Resource")
+}
+
+func TestImportAuthorizationDetailsErrors(t *testing.T) {
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, err := ImportAuthorizationDetails(strings.NewReader("{"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "error decoding authorization details")
+	})
+
+	t.Run("Unparseable Document", func(t *testing.T) {
+		dump := `{"RoleDetailList": [{
+			"Arn": "arn:aws:iam::123456789012:role/broken",
+			"RoleName": "broken",
+			"AssumeRolePolicyDocument": {"Version": "2012-10-17"},
+			"RolePolicyList": [{"PolicyName": "ok", "PolicyDocument": {"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]}}]
+		}]}`
+		details, err := ImportAuthorizationDetails(strings.NewReader(dump))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "trust policy")
+		require.Contains(t, err.Error(), "arn:aws:iam::123456789012:role/broken")
+		require.Len(t, details.Policies, 1)
+		require.Len(t, details.Entities, 1)
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
@@ -33,12 +35,15 @@ func getParser() (*participle.Parser[AwsPolicy], error) {
 }
 
 type AwsParser struct {
-	policyText string
-	awsPolicy  *AwsPolicy
-	policies   []*policy.Policy
-	parsed     bool
-	error      error
-	Trace      bool
+	policyText  string
+	awsPolicy   *AwsPolicy
+	policies    []*policy.Policy
+	positions   []policy.Position
+	diagnostics []policy.Diagnostic
+	parsed      bool
+	error       error
+	trust       bool
+	Trace       bool
 }
 
 // recursiveUnescape will repeatedly attempt to unescape the string until the input is equal to the unescaped output.
@@ -72,6 +77,18 @@ func NewAwsPolicyParser(policyText string, escaped bool) (*AwsParser, error) {
 	}, nil
 }
 
+// NewAwsTrustPolicyParser returns a parser for a role trust policy. It
+// reports statements a trust policy does not allow, such as ones without a
+// Principal or with a Resource, as diagnostics.
+func NewAwsTrustPolicyParser(policyText string, escaped bool) (*AwsParser, error) {
+	a, err := NewAwsPolicyParser(policyText, escaped)
+	if err != nil {
+		return nil, err
+	}
+	a.trust = true
+	return a, nil
+}
+
 func (a *AwsParser) Parse() error {
 	parser, err := getParser()
 	if err != nil {
@@ -110,6 +127,18 @@ func (a *AwsParser) GetPolicy() ([]*policy.Policy, error) {
 	return nil, fmt.Errorf("did not parse")
 }
 
+// Positions returns where each statement of GetPolicy starts in the policy
+// text.
+func (a *AwsParser) Positions() []policy.Position {
+	return a.positions
+}
+
+// Diagnostics returns the problems found while constructing the policies, such
+// as condition values that are invalid for their operator.
+func (a *AwsParser) Diagnostics() []policy.Diagnostic {
+	return a.diagnostics
+}
+
 func (a *AwsParser) Json() ([]byte, error) {
 	if a.parsed && a.policies != nil {
 		return json.Marshal(a.policies)
@@ -144,13 +173,23 @@ func (a *AwsParser) constructPolicy(ast *AwsPolicy) error {
 	}
 
 	a.policies = []*policy.Policy{}
+	a.positions = nil
+	a.diagnostics = nil
 
 	var id, version string
 	if x := ast.Block.GetProperty("Id"); x != nil {
-		id = x.Value.(BlockString).String
+		s, ok := x.Value.(BlockString)
+		if !ok {
+			return fmt.Errorf("policy Id is not a string")
+		}
+		id = s.String
 	}
 	if x := ast.Block.GetProperty("Version"); x != nil {
-		version = x.Value.(BlockString).String
+		s, ok := x.Value.(BlockString)
+		if !ok {
+			return fmt.Errorf("policy Version is not a string")
+		}
+		version = s.String
 	}
 
 	if x := ast.Block.GetProperty("Statement"); x != nil {
@@ -191,16 +230,20 @@ func (a *AwsParser) constructPolicy(ast *AwsPolicy) error {
 					pol.NotResources = a.getAnyOrList(element.NotResource)
 				}
 				if element.Principal != nil {
-					pol.Subjects = a.getSubjects(element.Principal)
+					pol.Subjects, pol.SubjectTypes = a.getSubjects(element.Principal)
 				}
 				if element.NotPrincipal != nil {
-					pol.NotSubjects = a.getSubjects(element.NotPrincipal)
+					pol.NotSubjects, pol.NotSubjectTypes = a.getSubjects(element.NotPrincipal)
 				}
 				if element.Condition != nil {
 					pol.Condition = a.getCondition(element.Condition)
 				}
 			}
+			if a.trust {
+				a.validateTrustStatement(pol)
+			}
 			a.policies = append(a.policies, pol)
+			a.positions = append(a.positions, policy.Position{Line: statement.Pos.Line, Column: statement.Pos.Column})
 		}
 	} else {
 		return fmt.Errorf("no statements found in policy")
@@ -237,49 +280,95 @@ func (a *AwsParser) getAnyOrList(l *AnyOrList) []string {
 	return []string{}
 }
 
-func (a *AwsParser) getSubjects(p *Principal) []string {
+// getSubjects flattens a Principal element and returns the principal type of
+// each subject alongside, so that AWS, Federated, Service and CanonicalUser
+// principals stay distinguishable. Principal "*" has no type.
+func (a *AwsParser) getSubjects(p *Principal) ([]string, []string) {
 	if p == nil {
-		return []string{}
+		return []string{}, nil
 	}
 	if p.Any {
-		return []string{"<.*>"}
+		return []string{"<.*>"}, []string{""}
 	}
 	x := []string{}
-	if p.List != nil {
-		for _, item := range p.List {
-			if item.Aws != nil {
-				x = append(x, a.getAnyOrList(item.Aws)...)
-			}
-			if item.Federated != nil {
-				x = append(x, a.getAnyOrList(item.Federated)...)
-			}
-			if item.Canonical != nil {
-				x = append(x, a.getAnyOrList(item.Canonical)...)
-			}
-			if item.Service != nil {
-				x = append(x, a.getAnyOrList(item.Service)...)
-			}
+	var types []string
+	add := func(l *AnyOrList, t string) {
+		if l == nil {
+			return
+		}
+		for _, s := range a.getAnyOrList(l) {
+			x = append(x, s)
+			types = append(types, t)
+		}
+	}
+	for _, item := range p.List {
+		add(item.Aws, policy.SubjectAws)
+		add(item.Federated, policy.SubjectFederated)
+		add(item.Canonical, policy.SubjectCanonical)
+		add(item.Service, policy.SubjectService)
+	}
+
+	return x, types
+}
+
+// trustActions are the actions a role trust policy can grant.
+var trustActions = []string{
+	"sts:AssumeRole", "sts:AssumeRoleWithSAML", "sts:AssumeRoleWithWebIdentity",
+	"sts:TagSession", "sts:SetSourceIdentity", "sts:SetContext",
+}
+
+// validateTrustStatement records a diagnostic for every element of pol that
+// IAM rejects in a role trust policy. pol is the next statement to be
+// appended to a.policies.
+func (a *AwsParser) validateTrustStatement(pol *policy.Policy) {
+	report := func(message string) {
+		a.diagnostics = append(a.diagnostics, policy.Diagnostic{Statement: len(a.policies), Message: message})
+	}
+	if len(pol.Subjects) == 0 && len(pol.NotSubjects) == 0 {
+		report("trust policy statement has no Principal")
+	}
+	if len(pol.NotSubjects) > 0 {
+		report("trust policy statement uses NotPrincipal")
+	}
+	if len(pol.Resources) > 0 || len(pol.NotResources) > 0 {
+		report("trust policy statement has a Resource element")
+	}
+	for _, action := range append(slices.Clone(pol.Actions), pol.NotActions...) {
+		if trustAction(action) {
+			continue
 		}
+		report(fmt.Sprintf("action %s cannot be granted by a trust policy", strings.ReplaceAll(action, "<.*>", "*")))
 	}
+}
 
-	return x
+// trustAction reports whether an action pattern matches a trust action.
+func trustAction(pattern string) bool {
+	quoted := regexp.QuoteMeta(strings.ReplaceAll(pattern, "<.*>", "*"))
+	quoted = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(quoted)
+	re, err := regexp.Compile("(?i)^" + quoted + "$")
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(trustActions, re.MatchString)
 }
 
+// getCondition converts a Condition block. Keys are kept as written; the
+// condition-key lint rule checks them against the action catalog.
 func (a *AwsParser) getCondition(c *Condition) []policy.Condition {
 	if c == nil {
 		return nil
 	}
 
-	var cm []policy.Condition
+	// An empty Condition block gives an empty, rather than a nil, list and an
+	// operator without keys gives a condition without keys, so that they can
+	// be told apart from a statement without conditions.
+	cm := []policy.Condition{}
 
 	for _, cc := range c.ConditionList {
 		op := StringValue(cc.Operation)
 		if op == "" {
 			continue
 		}
-		if cc.KeyValueList == nil {
-			continue
-		}
 		var values []any
 		var keys []string
 		var valTypes []string
@@ -358,6 +447,7 @@ func (a *AwsParser) getCondition(c *Condition) []policy.Condition {
 			values = append(values, val)
 			keys = append(keys, ck)
 			valTypes = append(valTypes, valType)
+			a.validateConditionValues(op, ck, val)
 		}
 		cp := policy.Condition{
 			Operation: op,
@@ -371,3 +461,30 @@ func (a *AwsParser) getCondition(c *Condition) []policy.Condition {
 
 	return cm
 }
+
+// validateConditionValues records a diagnostic for every value that cannot be
+// parsed for the family of op, such as an invalid CIDR for IpAddress. The
+// statement being constructed is the next one to be appended to a.policies.
+func (a *AwsParser) validateConditionValues(op, key string, val any) {
+	family := policy.OperatorFamily(op)
+	if family == policy.FamilyUnknown {
+		a.diagnostics = append(a.diagnostics, policy.Diagnostic{
+			Statement: len(a.policies),
+			Operator:  op,
+			Key:       key,
+			Message:   "unknown condition operator",
+		})
+		return
+	}
+	for _, raw := range policy.ValueStrings(val) {
+		if _, err := policy.ParseTypedValue(family, raw); err != nil {
+			a.diagnostics = append(a.diagnostics, policy.Diagnostic{
+				Statement: len(a.policies),
+				Operator:  op,
+				Key:       key,
+				Value:     raw,
+				Message:   err.Error(),
+			})
+		}
+	}
+}
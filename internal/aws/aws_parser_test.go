@@ -1188,8 +1188,9 @@ func TestAwsParser_GetSubjectsNilCases(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := a.getSubjects(tt.input)
+			result, types := a.getSubjects(tt.input)
 			require.Empty(t, result)
+			require.Empty(t, types)
 		})
 	}
 }
@@ -1433,6 +1434,121 @@ func TestAwsParser_GetConditionCases(t *testing.T) {
 	})
 }
 
+func TestAwsParser_Diagnostics(t *testing.T) {
+	a := newParsedAwsParser(t, `{
+		"Statement": [
+			{
+				"Effect": "Allow", "Action": "*", "Resource": "*",
+				"Condition": {"IpAddress": {"aws:SourceIp": ["192.0.2.0/24", "10.0.0.0/33"]}}
+			},
+			{
+				"Effect": "Allow", "Action": "*", "Resource": "*",
+				"Condition": {
+					"DateGreaterThan": {"aws:CurrentTime": "last tuesday"},
+					"ArnLike": {"aws:SourceArn": "arn:aws:sns:*:123456789012:*"},
+					"StringEqualz": {"aws:username": "bob"}
+				}
+			}
+		]
+	}`)
+
+	diagnostics := a.Diagnostics()
+	require.Len(t, diagnostics, 3)
+
+	require.Equal(t, 0, diagnostics[0].Statement)
+	require.Equal(t, "IpAddress", diagnostics[0].Operator)
+	require.Equal(t, "10.0.0.0/33", diagnostics[0].Value)
+	require.Contains(t, diagnostics[0].Message, "is not an IP address or CIDR block")
+
+	require.Equal(t, 1, diagnostics[1].Statement)
+	require.Equal(t, "aws:CurrentTime", diagnostics[1].Key)
+	require.Contains(t, diagnostics[1].String(), "statement 1: DateGreaterThan aws:CurrentTime")
+
+	require.Equal(t, "StringEqualz", diagnostics[2].Operator)
+	require.Equal(t, "unknown condition operator", diagnostics[2].Message)
+
+	clean := newParsedAwsParser(t, `{"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*",
+		"Condition": {"Bool": {"aws:SecureTransport": true}}}]}`)
+	require.Empty(t, clean.Diagnostics())
+}
+
+func TestAwsParser_SubjectTypes(t *testing.T) {
+	a := newParsedAwsParser(t, `{"Statement": [
+		{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity", "Principal": {
+			"AWS": ["111122223333", "arn:aws:iam::444455556666:root"],
+			"Federated": "arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com",
+			"Service": "ec2.amazonaws.com",
+			"CanonicalUser": "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be"}},
+		{"Effect": "Deny", "Action": "sts:AssumeRole", "NotPrincipal": "*"}
+	]}`)
+	policies, err := a.GetPolicy()
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"111122223333", "arn:aws:iam::444455556666:root",
+		"arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com",
+		"ec2.amazonaws.com",
+		"79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be",
+	}, policies[0].Subjects)
+	require.Equal(t, []string{
+		policy.SubjectAws, policy.SubjectAws, policy.SubjectFederated, policy.SubjectService, policy.SubjectCanonical,
+	}, policies[0].SubjectTypes)
+	require.Equal(t, policy.SubjectFederated, policies[0].SubjectType(2))
+	require.Equal(t, []string{"<.*>"}, policies[1].NotSubjects)
+	require.Equal(t, []string{""}, policies[1].NotSubjectTypes)
+	require.Empty(t, policies[1].SubjectType(0))
+}
+
+func TestAwsTrustPolicyParser(t *testing.T) {
+	a, err := NewAwsTrustPolicyParser(`{"Statement": [
+		{"Effect": "Allow", "Action": ["sts:AssumeRole", "sts:TagSession"], "Principal": {"Service": "ec2.amazonaws.com"}},
+		{"Effect": "Allow", "Action": "sts:AssumeRoleWith*", "Principal": {"Federated": "cognito-identity.amazonaws.com"}},
+		{"Effect": "Allow", "Action": ["s3:GetObject", "sts:GetCallerIdentity"], "Resource": "*"},
+		{"Effect": "Deny", "Action": "sts:*", "NotPrincipal": {"AWS": "111122223333"}}
+	]}`, false)
+	require.NoError(t, err)
+	require.NoError(t, a.Parse())
+
+	var messages []string
+	for _, d := range a.Diagnostics() {
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"statement 2: trust policy statement has no Principal",
+		"statement 2: trust policy statement has a Resource element",
+		"statement 2: action s3:GetObject cannot be granted by a trust policy",
+		"statement 2: action sts:GetCallerIdentity cannot be granted by a trust policy",
+		"statement 3: trust policy statement uses NotPrincipal",
+	}, messages)
+
+	// The same document parsed as an ordinary policy has no diagnostics.
+	require.Empty(t, newParsedAwsParser(t, `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`).Diagnostics())
+}
+
+func TestAwsParser_PositionsAndEmptyConditions(t *testing.T) {
+	a := newParsedAwsParser(t, `{
+  "Statement": [
+    {"Effect": "Allow", "Action": "*", "Resource": "*", "Condition": {}},
+    {
+      "Effect": "Allow", "Action": "*", "Resource": "*",
+      "Condition": {"StringEquals": {}}
+    },
+    {"Effect": "Allow", "Action": "*", "Resource": "*"}
+  ]
+}`)
+	policies, err := a.GetPolicy()
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+
+	require.NotNil(t, policies[0].Condition)
+	require.Empty(t, policies[0].Condition)
+	require.Len(t, policies[1].Condition, 1)
+	require.Equal(t, "StringEquals", policies[1].Condition[0].Operation)
+	require.Empty(t, policies[1].Condition[0].Key)
+	require.Nil(t, policies[2].Condition)
+
+	require.Equal(t, []policy.Position{{Line: 3, Column: 6}, {Line: 5, Column: 7}, {Line: 8, Column: 6}}, a.Positions())
+}
+
 // FuzzParsePolicyText is a fuzzing test for the AWS policy parser
 func FuzzParsePolicyText(f *testing.F) {
 	// Add seed corpus
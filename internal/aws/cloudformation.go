@@ -0,0 +1,317 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// cloudFormationDocuments lists, per resource type, the properties that hold a
+// policy document and how the document relates to the resource.
+var cloudFormationDocuments = map[string][]struct {
+	property string
+	relation string
+}{
+	"AWS::IAM::Policy":        {{"PolicyDocument", policy.RelationInline}},
+	"AWS::IAM::ManagedPolicy": {{"PolicyDocument", policy.RelationManaged}},
+	"AWS::IAM::Role":          {{"AssumeRolePolicyDocument", policy.RelationTrust}},
+	"AWS::S3::BucketPolicy":   {{"PolicyDocument", policy.RelationResource}},
+	"AWS::SQS::QueuePolicy":   {{"PolicyDocument", policy.RelationResource}},
+	"AWS::SNS::TopicPolicy":   {{"PolicyDocument", policy.RelationResource}},
+	"AWS::KMS::Key":           {{"KeyPolicy", policy.RelationResource}},
+}
+
+// resource types that embed a list of inline policies under "Policies".
+var cloudFormationInlinePolicies = map[string]bool{
+	"AWS::IAM::Role":  true,
+	"AWS::IAM::User":  true,
+	"AWS::IAM::Group": true,
+}
+
+// ExtractCloudFormation finds the IAM policy documents in a CloudFormation
+// template, in JSON or YAML, and parses them. Intrinsic functions are replaced
+// by symbolic placeholders such as "{{Ref:Bucket}}" or "{{Fn::GetAtt:Role.Arn}}",
+// which also stand for the references of Fn::Sub templates.
+// Documents that fail to parse are skipped and their errors joined into the
+// returned error.
+func ExtractCloudFormation(template []byte) ([]*policy.Policy, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(template, &root); err != nil {
+		return nil, fmt.Errorf("error decoding template: %w", err)
+	}
+	doc, ok := fromYamlNode(&root).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("template is not a mapping")
+	}
+	resources, ok := doc["Resources"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("no resources found in template")
+	}
+
+	logicalIds := make([]string, 0, len(resources))
+	for id := range resources {
+		logicalIds = append(logicalIds, id)
+	}
+	sort.Strings(logicalIds)
+
+	var policies []*policy.Policy
+	var errs []error
+	add := func(document any, src policy.Source) {
+		ps, err := parseTemplateDocument(document)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s policy of %s: %w", src.Relation, src.Entity, err))
+			return
+		}
+		for _, p := range ps {
+			s := src
+			p.Source = &s
+			policies = append(policies, p)
+		}
+	}
+
+	for _, id := range logicalIds {
+		resource, ok := resources[id].(map[string]any)
+		if !ok {
+			continue
+		}
+		resourceType, _ := resource["Type"].(string)
+		properties, _ := resource["Properties"].(map[string]any)
+		if properties == nil {
+			continue
+		}
+		policyName := symbolicString(properties["PolicyName"])
+		if policyName == "" {
+			policyName = symbolicString(properties["ManagedPolicyName"])
+		}
+
+		for _, d := range cloudFormationDocuments[resourceType] {
+			if document, ok := properties[d.property]; ok {
+				add(document, policy.Source{Entity: id, EntityType: resourceType, Relation: d.relation, PolicyName: policyName})
+			}
+		}
+		if cloudFormationInlinePolicies[resourceType] {
+			inline, _ := properties["Policies"].([]any)
+			for _, item := range inline {
+				p, ok := item.(map[string]any)
+				if !ok {
+					continue
+				}
+				add(p["PolicyDocument"], policy.Source{Entity: id, EntityType: resourceType, Relation: policy.RelationInline, PolicyName: symbolicString(p["PolicyName"])})
+			}
+		}
+	}
+
+	return policies, errors.Join(errs...)
+}
+
+func parseTemplateDocument(document any) ([]*policy.Policy, error) {
+	if document == nil {
+		return nil, fmt.Errorf("empty policy document")
+	}
+	symbolic := symbolize(document)
+	if s, ok := symbolic.(string); ok {
+		// Documents are sometimes given as a JSON string.
+		policies, _, err := parseEmbeddedDocument(json.RawMessage(strings.TrimSpace(s)), false)
+		return policies, err
+	}
+	if m, ok := symbolic.(map[string]any); ok {
+		if s, ok := m["Statement"].(map[string]any); ok {
+			m["Statement"] = []any{s}
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(symbolic); err != nil {
+		return nil, fmt.Errorf("error encoding policy document: %w", err)
+	}
+	policies, _, err := parseEmbeddedDocument(buf.Bytes(), false)
+	return policies, err
+}
+
+// symbolize replaces CloudFormation intrinsic functions with placeholder strings,
+// so that the document can be parsed without knowing the stack parameters.
+func symbolize(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 1 {
+			for name, arg := range x {
+				if name == "Ref" || strings.HasPrefix(name, "Fn::") {
+					return intrinsic(name, arg)
+				}
+			}
+		}
+		out := make(map[string]any, len(x))
+		for k, item := range x {
+			out[k] = symbolize(item)
+		}
+		return out
+	case []any:
+		out := make([]any, 0, len(x))
+		for _, item := range x {
+			s := symbolize(item)
+			if s == noValue {
+				continue
+			}
+			out = append(out, s)
+		}
+		return out
+	}
+	return v
+}
+
+const noValue = "{{Ref:AWS::NoValue}}"
+
+func intrinsic(name string, arg any) any {
+	switch name {
+	case "Ref":
+		return fmt.Sprintf("{{Ref:%s}}", symbolicString(arg))
+	case "Fn::Sub":
+		if list, ok := arg.([]any); ok && len(list) > 0 {
+			vars, _ := list[len(list)-1].(map[string]any)
+			return substitute(symbolicString(list[0]), vars)
+		}
+		return substitute(symbolicString(arg), nil)
+	case "Fn::GetAtt":
+		if list, ok := arg.([]any); ok {
+			parts := make([]string, 0, len(list))
+			for _, p := range list {
+				parts = append(parts, symbolicString(p))
+			}
+			return fmt.Sprintf("{{Fn::GetAtt:%s}}", strings.Join(parts, "."))
+		}
+		return fmt.Sprintf("{{Fn::GetAtt:%s}}", symbolicString(arg))
+	case "Fn::Join":
+		if list, ok := arg.([]any); ok && len(list) == 2 {
+			if parts, ok := list[1].([]any); ok {
+				strs := make([]string, 0, len(parts))
+				for _, p := range parts {
+					strs = append(strs, symbolicString(p))
+				}
+				return strings.Join(strs, symbolicString(list[0]))
+			}
+		}
+	case "Fn::If":
+		// Structural branches, such as a whole statement, assume the condition
+		// holds so that the document keeps its shape.
+		if list, ok := arg.([]any); ok && len(list) == 3 {
+			switch list[1].(type) {
+			case map[string]any, []any:
+				branch := symbolize(list[1])
+				if branch == noValue {
+					branch = symbolize(list[2])
+				}
+				return branch
+			}
+			return fmt.Sprintf("{{Fn::If:%s}}", symbolicString(list[0]))
+		}
+	}
+	if s, ok := arg.(string); ok {
+		return fmt.Sprintf("{{%s:%s}}", name, s)
+	}
+	return fmt.Sprintf("{{%s}}", name)
+}
+
+var subReference = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// substitute replaces the ${Name} references of an Fn::Sub template by the
+// placeholders of Ref and Fn::GetAtt, or the symbolized value of vars, so that
+// they cannot be mistaken for policy variables. ${!Name} is the escaped form
+// of a literal ${Name}, such as the policy variable ${aws:username}.
+func substitute(template string, vars map[string]any) string {
+	return subReference.ReplaceAllStringFunc(template, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if literal, ok := strings.CutPrefix(name, "!"); ok {
+			return "${" + literal + "}"
+		}
+		if v, ok := vars[name]; ok {
+			return symbolicString(v)
+		}
+		if strings.Contains(name, ".") {
+			return fmt.Sprintf("{{Fn::GetAtt:%s}}", name)
+		}
+		return fmt.Sprintf("{{Ref:%s}}", name)
+	})
+}
+
+// symbolicString renders v as a string, symbolizing it first if it is an
+// intrinsic function.
+func symbolicString(v any) string {
+	switch x := symbolize(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// fromYamlNode converts a YAML node into plain Go values, expanding the short
+// form intrinsic tags such as !Ref and !GetAtt into their long form.
+func fromYamlNode(n *yaml.Node) any {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil
+		}
+		return fromYamlNode(n.Content[0])
+	case yaml.AliasNode:
+		return fromYamlNode(n.Alias)
+	}
+
+	var v any
+	switch n.Kind {
+	case yaml.MappingNode:
+		m := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			m[n.Content[i].Value] = fromYamlNode(n.Content[i+1])
+		}
+		v = m
+	case yaml.SequenceNode:
+		l := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			l = append(l, fromYamlNode(c))
+		}
+		v = l
+	case yaml.ScalarNode:
+		switch n.Tag {
+		case "!!int", "!!float", "!!bool":
+			if err := n.Decode(&v); err != nil {
+				v = n.Value
+			}
+		case "!!null":
+			v = nil
+		default:
+			v = n.Value
+		}
+	}
+
+	if !strings.HasPrefix(n.Tag, "!") || strings.HasPrefix(n.Tag, "!!") {
+		return v
+	}
+	name := strings.TrimPrefix(n.Tag, "!")
+	switch name {
+	case "Ref", "Condition":
+		return map[string]any{name: v}
+	case "GetAtt":
+		if s, ok := v.(string); ok {
+			parts := strings.SplitN(s, ".", 2)
+			l := make([]any, 0, len(parts))
+			for _, p := range parts {
+				l = append(l, p)
+			}
+			v = l
+		}
+	}
+	return map[string]any{"Fn::" + name: v}
+}
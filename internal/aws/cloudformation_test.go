@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"os"
+	"testing"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractCloudFormation(t *testing.T) {
+	template, err := os.ReadFile("testdata/cloudformation.yaml")
+	require.NoError(t, err)
+
+	policies, err := ExtractCloudFormation(template)
+	require.NoError(t, err)
+	require.Len(t, policies, 5)
+
+	trust := policies[0]
+	require.Equal(t, "AppRole", trust.Source.Entity)
+	require.Equal(t, "AWS::IAM::Role", trust.Source.EntityType)
+	require.Equal(t, policy.RelationTrust, trust.Source.Relation)
+	require.Equal(t, []string{"lambda.amazonaws.com"}, trust.Subjects)
+
+	queue := policies[1]
+	require.Equal(t, policy.RelationInline, queue.Source.Relation)
+	require.Equal(t, "{{Ref:Environment}}-queue", queue.Source.PolicyName)
+	require.Equal(t, []string{"arn:aws:sqs:{{Ref:AWS::Region}}:{{Ref:AWS::AccountId}}:{{Fn::ImportValue:QueueName}}"}, queue.Resources)
+
+	logs := policies[2]
+	require.Equal(t, []string{"logs:PutLogEvents"}, logs.Actions)
+
+	bucket := policies[3]
+	require.Equal(t, "BucketPolicy", bucket.Source.Entity)
+	require.Equal(t, policy.RelationResource, bucket.Source.Relation)
+	require.Equal(t, "DenyInsecure", bucket.Id)
+	require.Equal(t, "2012-10-17", bucket.Version)
+	require.False(t, bucket.Allowed)
+	require.Equal(t, []string{"<.*>"}, bucket.Subjects)
+	require.Equal(t, []string{"{{Fn::GetAtt:Bucket.Arn}}", "{{Fn::GetAtt:Bucket.Arn}}/<.*>"}, bucket.Resources)
+	require.Len(t, bucket.Condition, 1)
+	require.Equal(t, []any{[]bool{false}}, bucket.Condition[0].Value)
+
+	key := policies[4]
+	require.Equal(t, "AWS::KMS::Key", key.Source.EntityType)
+	require.Equal(t, []string{"arn:aws:iam::{{Ref:AWS::AccountId}}:root"}, key.Subjects)
+}
+
+func TestExtractCloudFormationJson(t *testing.T) {
+	template := `{
+		"Resources": {
+			"Managed": {
+				"Type": "AWS::IAM::ManagedPolicy",
+				"Properties": {
+					"ManagedPolicyName": "reader",
+					"PolicyDocument": {
+						"Statement": [{
+							"Effect": "Allow",
+							"Action": "dynamodb:GetItem",
+							"Resource": {"Fn::GetAtt": ["Table", "Arn"]}
+						}]
+					}
+				}
+			},
+			"Queue": {"Type": "AWS::SQS::Queue", "Properties": {}}
+		}
+	}`
+
+	policies, err := ExtractCloudFormation([]byte(template))
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	require.Equal(t, policy.RelationManaged, policies[0].Source.Relation)
+	require.Equal(t, "reader", policies[0].Source.PolicyName)
+	require.Equal(t, []string{"{{Fn::GetAtt:Table.Arn}}"}, policies[0].Resources)
+}
+
+func TestExtractCloudFormationSub(t *testing.T) {
+	template := `{
+		"Resources": {
+			"Managed": {
+				"Type": "AWS::IAM::ManagedPolicy",
+				"Properties": {
+					"PolicyDocument": {
+						"Statement": [{
+							"Effect": "Allow",
+							"Action": "s3:GetObject",
+							"Resource": [
+								{"Fn::Sub": "arn:aws:s3:::${Bucket}/home/${!aws:username}/*"},
+								{"Fn::Sub": ["arn:aws:s3:::${Name}/${Table.Arn}", {"Name": {"Ref": "BucketName"}}]}
+							]
+						}]
+					}
+				}
+			}
+		}
+	}`
+
+	policies, err := ExtractCloudFormation([]byte(template))
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	require.Equal(t, []string{
+		"arn:aws:s3:::{{Ref:Bucket}}/home/${aws:username}/<.*>",
+		"arn:aws:s3:::{{Ref:BucketName}}/{{Fn::GetAtt:Table.Arn}}",
+	}, policies[0].Resources)
+}
+
+func TestExtractCloudFormationErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		errorMsg string
+	}{
+		{name: "Invalid YAML", template: "Resources: [", errorMsg: "error decoding template"},
+		{name: "Not A Mapping", template: "- a", errorMsg: "template is not a mapping"},
+		{name: "No Resources", template: "Parameters: {}", errorMsg: "no resources found in template"},
+		{
+			name: "Broken Document",
+			template: `Resources:
+  Policy:
+    Type: AWS::IAM::Policy
+    Properties:
+      PolicyDocument:
+        Version: "2012-10-17"`,
+			errorMsg: "inline policy of Policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractCloudFormation([]byte(tt.template))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.errorMsg)
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package aws
 
+import "github.com/alecthomas/participle/v2/lexer"
+
 /*
 	Policy Grammar for AWS: https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_grammar.html
 
@@ -93,6 +95,7 @@ func (b *Block) GetProperty(key string) *BlockProperty {
 }
 
 type Statement struct {
+	Pos      lexer.Position
 	Elements []*Elements `parser:"@@ (',' @@)*"`
 }
 
@@ -131,12 +134,12 @@ type PrincipalList struct {
 }
 
 type Condition struct {
-	ConditionList []*ConditionList `parser:"'{' @@ ((',' @@)*)? '}'"`
+	ConditionList []*ConditionList `parser:"'{' (@@ ((',' @@)*)?)? '}'"`
 }
 
 type ConditionList struct {
 	Operation    *string         `parser:"@String ':'"`
-	KeyValueList []*KeyValueList `parser:"'{' @@ ((',' @@)*)? '}'"`
+	KeyValueList []*KeyValueList `parser:"'{' (@@ ((',' @@)*)?)? '}'"`
 }
 
 type KeyValueList struct {
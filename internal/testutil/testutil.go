@@ -0,0 +1,29 @@
+// Package testutil holds the fixtures the tests of several packages share.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/pkg/parser"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Parse parses an AWS policy document, failing t if it does not parse.
+func Parse(t testing.TB, text string) []*policy.Policy {
+	t.Helper()
+	return ParseAs(t, parser.Aws, text)
+}
+
+// ParseAs parses a policy document with the parser of provider, such as
+// parser.AwsTrust, failing t if it does not parse.
+func ParseAs(t testing.TB, provider, text string) []*policy.Policy {
+	t.Helper()
+	p, err := parser.NewParser(provider, text, false)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	policies, err := p.GetPolicy()
+	require.NoError(t, err)
+	return policies
+}
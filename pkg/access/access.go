@@ -0,0 +1,383 @@
+// Package access finds resource-based policies, such as S3 bucket policies,
+// SQS and SNS policies, KMS key policies, Lambda permissions and role trust
+// policies, that grant access to the public or to accounts outside a trusted
+// set. It is an offline counterpart of the external access findings of IAM
+// Access Analyzer.
+package access
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/arn"
+	"github.com/paullesiak/policyparser/pkg/eval"
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/paullesiak/policyparser/pkg/render"
+)
+
+// Config lists the accounts and organizations whose principals are not
+// external. Include the account that owns the resource.
+type Config struct {
+	TrustedAccounts      []string `json:"trusted-accounts" yaml:"trusted-accounts"`
+	TrustedOrganizations []string `json:"trusted-organizations" yaml:"trusted-organizations"` // organization ids, such as o-a1b2c3d4e5
+}
+
+type Kind string
+
+const (
+	Public       Kind = "public"        // any principal, including anonymous users
+	CrossAccount Kind = "cross-account" // principals of accounts or organizations that are not trusted
+	Federated    Kind = "federated"     // users of an identity provider
+)
+
+type Finding struct {
+	Statement  int      `json:"statement" yaml:"statement"` // index of the statement
+	Id         string   `json:"id" yaml:"id"`
+	Kind       Kind     `json:"kind" yaml:"kind"`
+	Principals []string `json:"principals" yaml:"principals"` // external principals, accounts or organizations
+	Actions    []string `json:"actions" yaml:"actions"`
+	Resources  []string `json:"resources" yaml:"resources"`
+	Reason     string   `json:"reason" yaml:"reason"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("statement %d (%s): %s access for %s: %s", f.Statement, f.Id, f.Kind, strings.Join(f.Principals, ", "), f.Reason)
+}
+
+// Analyze reports the Allow statements of a resource-based policy that grant
+// access to principals outside cfg. Conditions that limit the callers to
+// trusted accounts or organizations, to a VPC or VPC endpoint, or to AWS
+// services, and Deny statements that block every untrusted caller, mitigate
+// the access.
+func Analyze(policies []*policy.Policy, cfg Config) []Finding {
+	var guards []restriction
+	for _, p := range policies {
+		if p != nil && !p.Allowed {
+			if r, ok := denyGuard(p); ok {
+				guards = append(guards, r)
+			}
+		}
+	}
+
+	var out []Finding
+	for i, p := range policies {
+		if p == nil || !p.Allowed {
+			continue
+		}
+		if len(p.Subjects) == 0 && len(p.NotSubjects) == 0 {
+			// Identity policies have no principal.
+			continue
+		}
+		r := restrictionOf(p.Condition, true)
+		for _, g := range guards {
+			if g.covers(p) {
+				r = r.and(g)
+			}
+		}
+		for _, f := range analyzeStatement(p, r, cfg) {
+			f.Statement = i
+			f.Id = p.Id
+			f.Actions = p.Actions
+			if len(f.Actions) == 0 {
+				f.Actions = p.NotActions
+			}
+			f.Resources = p.Resources
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func analyzeStatement(p *policy.Policy, r restriction, cfg Config) []Finding {
+	if r.network || r.services {
+		return nil
+	}
+
+	var out []Finding
+	public := len(p.NotSubjects) > 0
+	var external, federated []string
+	for i, s := range p.Subjects {
+		switch principal := classify(p, i); principal.kind {
+		case principalAny:
+			public = true
+		case principalAccount:
+			if !slices.Contains(cfg.TrustedAccounts, principal.account) {
+				external = append(external, s)
+			}
+		case principalCanonical:
+			external = append(external, s)
+		case principalFederated:
+			federated = append(federated, s)
+		}
+	}
+
+	if public {
+		if untrusted, restricted := r.untrusted(cfg); !restricted {
+			reason := "Principal is * and no condition limits the callers"
+			if len(p.NotSubjects) > 0 {
+				reason = "Allow with NotPrincipal grants access to every other principal"
+			}
+			out = append(out, Finding{Kind: Public, Principals: []string{"*"}, Reason: reason})
+		} else if len(untrusted) > 0 {
+			out = append(out, Finding{Kind: CrossAccount, Principals: untrusted,
+				Reason: "Principal is * and the conditions allow callers of untrusted accounts or organizations"})
+		}
+	}
+	if len(external) > 0 {
+		if untrusted, restricted := r.untrusted(cfg); !restricted || len(untrusted) > 0 {
+			out = append(out, Finding{Kind: CrossAccount, Principals: external, Reason: "principals belong to accounts that are not trusted"})
+		}
+	}
+	if len(federated) > 0 {
+		out = append(out, Finding{Kind: Federated, Principals: federated, Reason: "users of the identity provider can assume the role"})
+	}
+	return out
+}
+
+type principalKind int
+
+const (
+	principalAny principalKind = iota
+	principalAccount
+	principalService
+	principalFederated
+	principalCanonical
+)
+
+type principal struct {
+	kind    principalKind
+	account string
+}
+
+// classify works out the kind of subject i of p from the principal type the
+// parser recorded. Statements built without types fall back to the form of
+// the subject.
+func classify(p *policy.Policy, i int) principal {
+	s := strings.ReplaceAll(p.Subjects[i], arn.Wildcard, "*")
+	if s == "*" {
+		return principal{kind: principalAny}
+	}
+	t := p.SubjectType(i)
+	if t == "" {
+		t = render.PrincipalType(p.Subjects[i])
+	}
+	switch t {
+	case policy.SubjectService:
+		return principal{kind: principalService}
+	case policy.SubjectCanonical:
+		return principal{kind: principalCanonical}
+	case policy.SubjectFederated:
+		if a, err := arn.Parse(s); err == nil {
+			return principal{kind: principalFederated, account: a.Account}
+		}
+		return principal{kind: principalFederated}
+	}
+	if !strings.HasPrefix(s, "arn:") {
+		return principal{kind: principalAccount, account: s}
+	}
+	a, err := arn.Parse(s)
+	if err != nil || a.Account == "" || strings.ContainsAny(a.Account, "*?") {
+		return principal{kind: principalAny}
+	}
+	return principal{kind: principalAccount, account: a.Account}
+}
+
+// restriction is what the conditions of a statement say about its callers.
+type restriction struct {
+	accounts []string // callers must belong to one of these accounts
+	orgs     []string // callers must belong to one of these organizations
+	network  bool     // callers must come from a VPC or VPC endpoint
+	services bool     // callers must be AWS services
+	actions  []string // for Deny guards, the actions the guard applies to
+}
+
+// untrusted returns the accounts and organizations the restriction lets in
+// that are not trusted, and whether the restriction limits the callers at all.
+// When both accounts and organizations are limited, a caller must satisfy both,
+// so either list being fully trusted is enough.
+func (r restriction) untrusted(cfg Config) ([]string, bool) {
+	if len(r.accounts) == 0 && len(r.orgs) == 0 {
+		return nil, false
+	}
+	var accounts, orgs []string
+	for _, a := range r.accounts {
+		if a != nobody && !slices.Contains(cfg.TrustedAccounts, a) {
+			accounts = append(accounts, a)
+		}
+	}
+	for _, o := range r.orgs {
+		if o != nobody && !slices.Contains(cfg.TrustedOrganizations, o) {
+			orgs = append(orgs, o)
+		}
+	}
+	if (len(r.accounts) > 0 && len(accounts) == 0) || (len(r.orgs) > 0 && len(orgs) == 0) {
+		return nil, true
+	}
+	return append(accounts, orgs...), true
+}
+
+func (r restriction) and(o restriction) restriction {
+	out := restriction{network: r.network || o.network, services: r.services || o.services}
+	out.accounts = intersect(r.accounts, o.accounts)
+	out.orgs = intersect(r.orgs, o.orgs)
+	return out
+}
+
+// nobody stands for the empty intersection of two allow lists.
+const nobody = "<none>"
+
+// intersect combines two allow lists, where an empty list allows everything.
+func intersect(a, b []string) []string {
+	switch {
+	case len(a) == 0:
+		return b
+	case len(b) == 0:
+		return a
+	}
+	var out []string
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			out = append(out, v)
+		}
+	}
+	if out == nil {
+		return []string{nobody}
+	}
+	return out
+}
+
+// covers reports whether the Deny guard applies to every action of p.
+func (r restriction) covers(p *policy.Policy) bool {
+	if len(p.Actions) == 0 {
+		return slices.ContainsFunc(r.actions, func(a string) bool { return a == arn.Wildcard || a == "*" })
+	}
+	for _, action := range p.Actions {
+		if !slices.ContainsFunc(r.actions, func(pattern string) bool { return eval.Match(pattern, action, true, nil) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// accountKeys and orgKeys are the condition keys that name the caller's
+// account or organization.
+var (
+	accountKeys = []string{"aws:PrincipalAccount", "aws:SourceAccount", "aws:SourceOwner", "kms:CallerAccount"}
+	arnKeys     = []string{"aws:PrincipalArn", "aws:SourceArn"}
+	orgKeys     = []string{"aws:PrincipalOrgID", "aws:SourceOrgID"}
+	pathKeys    = []string{"aws:PrincipalOrgPaths", "aws:SourceOrgPaths"}
+	networkKeys = []string{"aws:SourceVpce", "aws:SourceVpc"}
+)
+
+// restrictionOf reads the conditions that limit the callers. With positive set
+// the operators that require a match, such as StringEquals, are read; without
+// it their negated forms are, as used by Deny guards.
+func restrictionOf(conditions []policy.Condition, positive bool) restriction {
+	var r restriction
+	for _, c := range conditions {
+		op, ok := restrictingOperator(c.Operation, positive)
+		if !ok {
+			continue
+		}
+		for i, key := range c.Key {
+			var values []string
+			if i < len(c.Value) {
+				values = policy.ValueStrings(c.Value[i])
+			}
+			if slices.ContainsFunc(values, func(v string) bool { return strings.Contains(v, "${") }) {
+				continue
+			}
+			switch {
+			case containsFold(accountKeys, key):
+				r.accounts = append(r.accounts, values...)
+			case containsFold(arnKeys, key):
+				r.accounts = append(r.accounts, arnAccounts(values)...)
+			case containsFold(orgKeys, key):
+				r.orgs = append(r.orgs, values...)
+			case containsFold(pathKeys, key):
+				for _, v := range values {
+					org, _, _ := strings.Cut(v, "/")
+					r.orgs = append(r.orgs, org)
+				}
+			case containsFold(networkKeys, key):
+				r.network = true
+			case strings.EqualFold(key, "aws:PrincipalIsAWSService") && op == "Bool":
+				r.services = positive == slices.Contains(values, "true")
+			}
+		}
+	}
+	return r
+}
+
+// restrictingOperator reports whether op requires the key to be present and
+// match, for positive, or not to match, for Deny guards. IfExists operators do
+// not restrict callers that lack the key.
+func restrictingOperator(op string, positive bool) (string, bool) {
+	base := op
+	if set, rest, ok := strings.Cut(op, ":"); ok {
+		if set != "ForAnyValue" {
+			return "", false
+		}
+		base = rest
+	}
+	switch base {
+	case "Bool":
+		return base, true
+	case "StringEquals", "StringEqualsIgnoreCase", "StringLike", "ArnEquals", "ArnLike":
+		return base, positive
+	case "StringNotEquals", "StringNotEqualsIgnoreCase", "StringNotLike", "ArnNotEquals", "ArnNotLike":
+		return base, !positive
+	}
+	return "", false
+}
+
+// denyGuard recognizes a Deny statement for every principal that applies
+// unless the caller is trusted, such as a Deny with StringNotEquals on
+// aws:PrincipalOrgID.
+func denyGuard(p *policy.Policy) (restriction, bool) {
+	everyone := len(p.Subjects) == 0
+	for i := range p.Subjects {
+		everyone = everyone || classify(p, i).kind == principalAny
+	}
+	if !everyone || len(p.NotSubjects) > 0 || len(p.Actions) == 0 {
+		return restriction{}, false
+	}
+	// A Deny applies only when all its conditions match, so a guard with
+	// several keys lets through callers that fail any one of them.
+	keys := 0
+	for _, c := range p.Condition {
+		keys += len(c.Key)
+	}
+	if keys != 1 {
+		return restriction{}, false
+	}
+	r := restrictionOf(p.Condition, false)
+	if len(r.accounts) == 0 && len(r.orgs) == 0 && !r.network && !r.services {
+		return restriction{}, false
+	}
+	r.actions = p.Actions
+	return r, true
+}
+
+// arnAccounts returns the accounts of ARN condition values. ARNs without an
+// account, such as those of S3 buckets, do not tell which account the caller
+// belongs to, so values that include one do not limit the accounts at all.
+func arnAccounts(values []string) []string {
+	var out []string
+	for _, v := range values {
+		a, err := arn.Parse(v)
+		if err != nil {
+			continue
+		}
+		if a.Account == "" {
+			return nil
+		}
+		out = append(out, a.Account)
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(v string) bool { return strings.EqualFold(v, s) })
+}
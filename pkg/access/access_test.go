@@ -0,0 +1,210 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+var trusted = Config{
+	TrustedAccounts:      []string{"111122223333"},
+	TrustedOrganizations: []string{"o-trusted"},
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		kinds    []Kind
+		accounts [][]string
+	}{
+		{
+			name: "Public Bucket",
+			policy: `{"Statement": [{"Sid": "Public", "Effect": "Allow", "Principal": "*",
+				"Action": "s3:GetObject", "Resource": "arn:aws:s3:::website/*"}]}`,
+			kinds:    []Kind{Public},
+			accounts: [][]string{{"*"}},
+		},
+		{
+			name: "Anonymous AWS Principal",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": {"AWS": "*"},
+				"Action": "sqs:SendMessage", "Resource": "arn:aws:sqs:us-east-1:111122223333:queue"}]}`,
+			kinds:    []Kind{Public},
+			accounts: [][]string{{"*"}},
+		},
+		{
+			name: "Wildcard Account",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::*:root"},
+				"Action": "sns:Publish", "Resource": "arn:aws:sns:us-east-1:111122223333:topic"}]}`,
+			kinds:    []Kind{Public},
+			accounts: [][]string{{"*"}},
+		},
+		{
+			name: "Allow With NotPrincipal",
+			policy: `{"Statement": [{"Effect": "Allow", "NotPrincipal": {"AWS": "arn:aws:iam::111122223333:root"},
+				"Action": "s3:GetObject", "Resource": "*"}]}`,
+			kinds:    []Kind{Public},
+			accounts: [][]string{{"*"}},
+		},
+		{
+			name: "Organization Condition",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*",
+				"Condition": {"StringEquals": {"aws:PrincipalOrgID": "o-trusted"}}}]}`,
+		},
+		{
+			name: "Untrusted Organization",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*",
+				"Condition": {"StringEquals": {"aws:PrincipalOrgID": ["o-trusted", "o-partner"]}}}]}`,
+			kinds:    []Kind{CrossAccount},
+			accounts: [][]string{{"o-partner"}},
+		},
+		{
+			name: "Organization Paths",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*",
+				"Condition": {"ForAnyValue:StringLike": {"aws:PrincipalOrgPaths": "o-trusted/r-ab12/ou-ab12-11111111/*"}}}]}`,
+		},
+		{
+			name: "IfExists Does Not Restrict",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*",
+				"Condition": {"StringEqualsIfExists": {"aws:PrincipalOrgID": "o-trusted"}}}]}`,
+			kinds:    []Kind{Public},
+			accounts: [][]string{{"*"}},
+		},
+		{
+			name: "Source Account",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "sns:Publish", "Resource": "*",
+				"Condition": {"StringEquals": {"aws:SourceAccount": "111122223333"}}}]}`,
+		},
+		{
+			name: "Source Arn Of Another Account",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "sqs:SendMessage", "Resource": "*",
+				"Condition": {"ArnLike": {"aws:SourceArn": "arn:aws:sns:us-east-1:444455556666:topic"}}}]}`,
+			kinds:    []Kind{CrossAccount},
+			accounts: [][]string{{"444455556666"}},
+		},
+		{
+			// A bucket ARN does not name the account of the bucket.
+			name: "Source Arn Without Account",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "sns:Publish", "Resource": "*",
+				"Condition": {"ArnLike": {"aws:SourceArn": ["arn:aws:s3:::uploads", "arn:aws:sns:us-east-1:111122223333:topic"]}}}]}`,
+			kinds:    []Kind{Public},
+			accounts: [][]string{{"*"}},
+		},
+		{
+			name: "VPC Endpoint",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*",
+				"Condition": {"StringEquals": {"aws:SourceVpce": "vpce-1a2b3c4d"}}}]}`,
+		},
+		{
+			name: "Cross Account Key Policy",
+			policy: `{"Statement": [
+				{"Sid": "Owner", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111122223333:root"}, "Action": "kms:*", "Resource": "*"},
+				{"Sid": "Partner", "Effect": "Allow", "Principal": {"AWS": ["arn:aws:iam::444455556666:role/app", "777788889999"]},
+					"Action": "kms:Decrypt", "Resource": "*"}]}`,
+			kinds:    []Kind{CrossAccount},
+			accounts: [][]string{{"arn:aws:iam::444455556666:role/app", "777788889999"}},
+		},
+		{
+			name: "Service Principal",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": {"Service": "lambda.amazonaws.com"},
+				"Action": "sts:AssumeRole"}]}`,
+		},
+		{
+			name: "Web Identity Trust",
+			policy: `{"Statement": [{"Effect": "Allow", "Principal": {"Federated": "cognito-identity.amazonaws.com"},
+				"Action": "sts:AssumeRoleWithWebIdentity",
+				"Condition": {"StringEquals": {"cognito-identity.amazonaws.com:aud": "us-east-1:1234"}}}]}`,
+			kinds:    []Kind{Federated},
+			accounts: [][]string{{"cognito-identity.amazonaws.com"}},
+		},
+		{
+			name: "Deny Guard",
+			policy: `{"Statement": [
+				{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*"},
+				{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "*",
+					"Condition": {"StringNotEquals": {"aws:PrincipalOrgID": "o-trusted"}}}]}`,
+		},
+		{
+			name: "Deny Guard For Other Actions",
+			policy: `{"Statement": [
+				{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "*"},
+				{"Effect": "Deny", "Principal": "*", "Action": "s3:PutObject", "Resource": "*",
+					"Condition": {"StringNotEquals": {"aws:PrincipalOrgID": "o-trusted"}}}]}`,
+			kinds:    []Kind{Public},
+			accounts: [][]string{{"*"}},
+		},
+		{
+			name:   "Identity Policy",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Analyze(testutil.Parse(t, tt.policy), trusted)
+			var kinds []Kind
+			var accounts [][]string
+			for _, f := range findings {
+				kinds = append(kinds, f.Kind)
+				accounts = append(accounts, f.Principals)
+			}
+			require.Equal(t, tt.kinds, kinds)
+			require.Equal(t, tt.accounts, accounts)
+		})
+	}
+}
+
+func TestAnalyze_Finding(t *testing.T) {
+	findings := Analyze(testutil.Parse(t, `{"Statement": [
+		{"Sid": "Internal", "Effect": "Allow", "Principal": {"AWS": "111122223333"}, "Action": "s3:*", "Resource": "*"},
+		{"Sid": "Public", "Effect": "Allow", "Principal": "*", "Action": ["s3:GetObject"], "Resource": "arn:aws:s3:::website/*"}
+	]}`), trusted)
+	require.Len(t, findings, 1)
+	f := findings[0]
+	require.Equal(t, 1, f.Statement)
+	require.Equal(t, "Public", f.Id)
+	require.Equal(t, []string{"s3:GetObject"}, f.Actions)
+	require.Equal(t, []string{"arn:aws:s3:::website/<.*>"}, f.Resources)
+	require.Equal(t, "statement 1 (Public): public access for *: Principal is * and no condition limits the callers", f.String())
+
+	// Without trusted accounts, every account is external.
+	findings = Analyze(testutil.Parse(t, `{"Statement": [
+		{"Effect": "Allow", "Principal": {"AWS": "111122223333"}, "Action": "s3:*", "Resource": "*"}]}`), Config{})
+	require.Len(t, findings, 1)
+	require.Equal(t, CrossAccount, findings[0].Kind)
+}
+
+func TestClassify(t *testing.T) {
+	p := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole", "Principal": {
+		"AWS": ["*", "444455556666", "arn:aws:iam::444455556666:role/app", "arn:aws:iam::*:root"],
+		"Service": "lambda.amazonaws.com",
+		"Federated": ["accounts.google.com", "arn:aws:iam::444455556666:saml-provider/idp"],
+		"CanonicalUser": "79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be"}}]}`)[0]
+	want := map[string]principal{
+		"<.*>":                               {kind: principalAny},
+		"444455556666":                       {kind: principalAccount, account: "444455556666"},
+		"arn:aws:iam::444455556666:role/app": {kind: principalAccount, account: "444455556666"},
+		"arn:aws:iam::<.*>:root":             {kind: principalAny},
+		"lambda.amazonaws.com":               {kind: principalService},
+		"accounts.google.com":                {kind: principalFederated},
+		"arn:aws:iam::444455556666:saml-provider/idp":                      {kind: principalFederated, account: "444455556666"},
+		"79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be": {kind: principalCanonical},
+	}
+	require.Len(t, p.Subjects, len(want))
+	require.Len(t, p.SubjectTypes, len(p.Subjects))
+	for i, s := range p.Subjects {
+		require.Equal(t, want[s], classify(p, i), s)
+	}
+
+	// The recorded type wins over the form of the subject.
+	typed := &policy.Policy{Subjects: []string{"oidc.example.com"}, SubjectTypes: []string{policy.SubjectFederated}}
+	require.Equal(t, principal{kind: principalFederated}, classify(typed, 0))
+	typed.SubjectTypes = nil
+	require.Equal(t, principal{kind: principalAccount, account: "oidc.example.com"}, classify(typed, 0))
+	untyped := &policy.Policy{Subjects: []string{"accounts.google.com", "ec2.amazonaws.com"}}
+	require.Equal(t, principal{kind: principalFederated}, classify(untyped, 0))
+	require.Equal(t, principal{kind: principalService}, classify(untyped, 1))
+}
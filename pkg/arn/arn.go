@@ -0,0 +1,144 @@
+// Package arn parses Amazon Resource Names as they appear in policies, where
+// any segment may be a wildcard or contain policy variables such as
+// ${aws:username}.
+package arn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Wildcard is the form the parsers give to the * wildcard in resources.
+const Wildcard = "<.*>"
+
+type ARN struct {
+	Raw          string `json:"raw" yaml:"raw"`                     // resource as written in the policy
+	Partition    string `json:"partition" yaml:"partition"`         // such as aws or aws-cn
+	Service      string `json:"service" yaml:"service"`             // such as s3 or iam
+	Region       string `json:"region" yaml:"region"`               // empty for global services
+	Account      string `json:"account" yaml:"account"`             // empty for S3 buckets
+	Resource     string `json:"resource" yaml:"resource"`           // everything after the account
+	ResourceType string `json:"resource-type" yaml:"resource-type"` // such as role, function or bucket
+	ResourceId   string `json:"resource-id" yaml:"resource-id"`     // the resource part after the type
+}
+
+// Parse parses an ARN. Wildcards, in either the * or the <.*> form, are
+// returned as *. A bare * parses to an ARN whose segments are all *.
+func Parse(s string) (ARN, error) {
+	a := ARN{Raw: s}
+	text := strings.ReplaceAll(s, Wildcard, "*")
+	if text == "*" {
+		a.Partition, a.Service, a.Region, a.Account, a.Resource, a.ResourceType, a.ResourceId = "*", "*", "*", "*", "*", "*", "*"
+		return a, nil
+	}
+
+	parts := strings.SplitN(text, ":", 6)
+	if len(parts) != 6 {
+		return a, fmt.Errorf("%q is not an ARN: expected 6 segments, found %d", s, len(parts))
+	}
+	if parts[0] != "arn" && parts[0] != "*" {
+		return a, fmt.Errorf("%q is not an ARN: it does not start with arn:", s)
+	}
+	if parts[1] == "" || parts[2] == "" || parts[5] == "" {
+		return a, fmt.Errorf("%q is not an ARN: partition, service and resource are required", s)
+	}
+	a.Partition, a.Service, a.Region, a.Account, a.Resource = parts[1], parts[2], parts[3], parts[4], parts[5]
+	a.ResourceType, a.ResourceId = splitResource(a.Service, a.Resource)
+	return a, nil
+}
+
+// MustParse is like Parse but panics on error. It is meant for tests and
+// constants.
+func MustParse(s string) ARN {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// splitResource splits the resource part into its type and id. Resources are
+// written type/id or type:id; S3 is the exception, where the resource is a
+// bucket or a bucket/key object.
+func splitResource(service, resource string) (string, string) {
+	if resource == "*" {
+		return "*", "*"
+	}
+	if service == "s3" {
+		if strings.Contains(resource, "/") {
+			return "object", resource
+		}
+		return "bucket", resource
+	}
+	if i := strings.IndexAny(resource, "/:"); i >= 0 {
+		return resource[:i], resource[i+1:]
+	}
+	return "", resource
+}
+
+func (a ARN) String() string {
+	return "arn:" + a.Partition + ":" + a.Service + ":" + a.Region + ":" + a.Account + ":" + a.Resource
+}
+
+// HasWildcards reports whether any segment contains * or ?.
+func (a ARN) HasWildcards() bool {
+	return strings.ContainsAny(a.String(), "*?")
+}
+
+// HasVariables reports whether any segment contains a policy variable.
+func (a ARN) HasVariables() bool {
+	return strings.Contains(a.Raw, "${")
+}
+
+// MatchesAccount reports whether the ARN can refer to a resource of account.
+// A wildcard account matches every account and an empty one, as used by S3
+// buckets, matches none.
+func (a ARN) MatchesAccount(account string) bool {
+	return a.Account != "" && segmentMatch(a.Account, account)
+}
+
+// MatchesService reports whether the ARN can refer to a resource of service
+// and, when resourceType is not empty, of that resource type.
+func (a ARN) MatchesService(service, resourceType string) bool {
+	if !segmentMatch(a.Service, service) {
+		return false
+	}
+	if resourceType == "" || a.Resource == "*" {
+		return true
+	}
+	return segmentMatch(a.ResourceType, resourceType)
+}
+
+// segmentMatch matches value against pattern, where * matches any run of
+// characters and ? a single character. Patterns containing policy variables
+// match anything, since their value is only known at request time.
+func segmentMatch(pattern, value string) bool {
+	if strings.Contains(pattern, "${") {
+		return true
+	}
+	return glob(pattern, value)
+}
+
+func glob(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if glob(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || pattern[0] != value[0] {
+				return false
+			}
+		}
+		pattern, value = pattern[1:], value[1:]
+	}
+	return value == ""
+}
@@ -0,0 +1,104 @@
+package arn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   ARN
+		errMsg string
+	}{
+		{
+			name:  "IAM Role",
+			input: "arn:aws:iam::123456789012:role/aws-reserved/sso.amazonaws.com/Admin",
+			want: ARN{Partition: "aws", Service: "iam", Account: "123456789012",
+				Resource: "role/aws-reserved/sso.amazonaws.com/Admin", ResourceType: "role", ResourceId: "aws-reserved/sso.amazonaws.com/Admin"},
+		},
+		{
+			name:  "Lambda Function",
+			input: "arn:aws-cn:lambda:cn-north-1:123456789012:function:my-function:1",
+			want: ARN{Partition: "aws-cn", Service: "lambda", Region: "cn-north-1", Account: "123456789012",
+				Resource: "function:my-function:1", ResourceType: "function", ResourceId: "my-function:1"},
+		},
+		{
+			name:  "S3 Bucket",
+			input: "arn:aws:s3:::my-bucket",
+			want:  ARN{Partition: "aws", Service: "s3", Resource: "my-bucket", ResourceType: "bucket", ResourceId: "my-bucket"},
+		},
+		{
+			name:  "S3 Object",
+			input: "arn:aws:s3:::my-bucket/${aws:username}/*",
+			want: ARN{Partition: "aws", Service: "s3", Resource: "my-bucket/${aws:username}/*",
+				ResourceType: "object", ResourceId: "my-bucket/${aws:username}/*"},
+		},
+		{
+			name:  "Parsed Wildcards",
+			input: "arn:aws:iam::<.*>:role/<.*>",
+			want: ARN{Partition: "aws", Service: "iam", Account: "*",
+				Resource: "role/*", ResourceType: "role", ResourceId: "*"},
+		},
+		{
+			name:  "Any Resource",
+			input: "<.*>",
+			want: ARN{Partition: "*", Service: "*", Region: "*", Account: "*",
+				Resource: "*", ResourceType: "*", ResourceId: "*"},
+		},
+		{name: "Too Few Segments", input: "arn:aws:s3", errMsg: "expected 6 segments"},
+		{name: "Not An ARN", input: "urn:aws:s3:::bucket:x", errMsg: "does not start with arn:"},
+		{name: "Missing Service", input: "arn:aws::::x", errMsg: "are required"},
+		{name: "GCP Resource", input: "projects/my-project", errMsg: "expected 6 segments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			tt.want.Raw = tt.input
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestARN_Queries(t *testing.T) {
+	role := MustParse("arn:aws:iam::123456789012:role/Admin")
+	require.False(t, role.HasWildcards())
+	require.False(t, role.HasVariables())
+	require.True(t, role.MatchesAccount("123456789012"))
+	require.False(t, role.MatchesAccount("210987654321"))
+	require.True(t, role.MatchesService("iam", "role"))
+	require.True(t, role.MatchesService("iam", ""))
+	require.False(t, role.MatchesService("iam", "user"))
+	require.Equal(t, "arn:aws:iam::123456789012:role/Admin", role.String())
+
+	anyAccount := MustParse("arn:aws:iam::<.*>:role/<.*>")
+	require.True(t, anyAccount.HasWildcards())
+	require.True(t, anyAccount.MatchesAccount("123456789012"))
+
+	prefixed := MustParse("arn:aws:dynamodb:us-east-1:1234567890??:table/*")
+	require.True(t, prefixed.MatchesAccount("123456789012"))
+	require.False(t, prefixed.MatchesAccount("223456789012"))
+
+	bucket := MustParse("arn:aws:s3:::my-bucket")
+	require.False(t, bucket.MatchesAccount("123456789012"))
+	require.True(t, bucket.MatchesService("s3", "bucket"))
+	require.False(t, bucket.MatchesService("s3", "object"))
+
+	anyService := MustParse("arn:aws:*:*:*:*")
+	require.True(t, anyService.MatchesService("s3", "bucket"))
+
+	variable := MustParse("arn:aws:iam::${aws:PrincipalAccount}:user/${aws:username}")
+	require.True(t, variable.HasVariables())
+	require.True(t, variable.MatchesAccount("123456789012"))
+
+	require.Panics(t, func() { MustParse("bucket") })
+}
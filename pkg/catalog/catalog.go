@@ -0,0 +1,378 @@
+// Package catalog is an offline copy of the AWS Service Authorization
+// Reference: the actions of each service, the resource types they act on and
+// the condition keys they support. The bundled data is partial: it covers the
+// services most often found in IAM policies, and not every action of them.
+// Load reads a complete or newer catalog in the same format, such as the one
+// internal/gen builds from the service reference AWS publishes.
+package catalog
+
+//go:generate go run ./internal/gen -o data/aws.json
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed data/aws.json
+var bundled []byte
+
+type Catalog struct {
+	Version string `json:"version" yaml:"version"` // date of the Service Authorization Reference snapshot
+	// Complete is set when the catalog lists every action and condition key
+	// of every service, so that what it does not list does not exist.
+	Complete            bool     `json:"complete" yaml:"complete"`
+	GlobalConditionKeys []string `json:"global-condition-keys" yaml:"global-condition-keys"`
+	// ConditionKeyTypes holds the type of every global or service condition
+	// key that is not a String, keyed by the key as listed.
+	ConditionKeyTypes map[string]KeyType `json:"condition-key-types" yaml:"condition-key-types"`
+	Services          []*Service         `json:"services" yaml:"services"`
+
+	services map[string]*Service
+	global   keySet
+	known    keySet // global keys and the keys of every service
+}
+
+// KeyType is the type of a condition key, as the Service Authorization
+// Reference names it.
+type KeyType string
+
+const (
+	KeyString        KeyType = "String"
+	KeyArn           KeyType = "ARN"
+	KeyBool          KeyType = "Bool"
+	KeyNumeric       KeyType = "Numeric"
+	KeyDate          KeyType = "Date"
+	KeyIpAddress     KeyType = "IPAddress"
+	KeyArrayOfString KeyType = "ArrayOfString"
+	KeyArrayOfArn    KeyType = "ArrayOfARN"
+)
+
+type Service struct {
+	Prefix        string          `json:"prefix" yaml:"prefix"` // such as s3, the part of an action before the colon
+	Name          string          `json:"name" yaml:"name"`
+	ResourceTypes []*ResourceType `json:"resource-types" yaml:"resource-types"`
+	ConditionKeys []string        `json:"condition-keys" yaml:"condition-keys"`
+	Actions       []*Action       `json:"actions" yaml:"actions"`
+
+	actions map[string]*Action
+}
+
+type ResourceType struct {
+	Name string `json:"name" yaml:"name"`
+	Arn  string `json:"arn" yaml:"arn"` // ARN template, such as arn:${Partition}:s3:::${BucketName}
+
+	re      *regexp.Regexp
+	literal int // length of the template without its variables
+}
+
+// AccessLevel classifies actions the way the IAM console's policy summaries do.
+type AccessLevel string
+
+const (
+	List                  AccessLevel = "List"
+	Read                  AccessLevel = "Read"
+	Write                 AccessLevel = "Write"
+	PermissionsManagement AccessLevel = "Permissions management"
+	Tagging               AccessLevel = "Tagging"
+)
+
+// AccessLevels lists the access levels in the order summaries report them.
+var AccessLevels = []AccessLevel{List, Read, Write, PermissionsManagement, Tagging}
+
+type Action struct {
+	Name          string      `json:"name" yaml:"name"` // action name without the service prefix
+	AccessLevel   AccessLevel `json:"access-level" yaml:"access-level"`
+	ResourceTypes []string    `json:"resource-types" yaml:"resource-types"` // empty when the action only supports *
+	ConditionKeys []string    `json:"condition-keys" yaml:"condition-keys"` // service specific keys, global keys are not listed
+
+	service *Service
+	keys    keySet
+}
+
+// FullName returns the action with its service prefix, such as s3:GetObject.
+func (a *Action) FullName() string {
+	return a.service.Prefix + ":" + a.Name
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultCatalog *Catalog
+)
+
+// Default returns the catalog bundled with the module.
+func Default() *Catalog {
+	defaultOnce.Do(func() {
+		c, err := parse(bundled)
+		if err != nil {
+			panic(fmt.Sprintf("bundled catalog is invalid: %v", err))
+		}
+		defaultCatalog = c
+	})
+	return defaultCatalog
+}
+
+// Load reads a catalog in the format of the bundled data/aws.json.
+func Load(r io.Reader) (*Catalog, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+func parse(data []byte) (*Catalog, error) {
+	c := &Catalog{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error reading catalog: %w", err)
+	}
+	c.services = map[string]*Service{}
+	c.global = newKeySet(c.GlobalConditionKeys)
+	known := slices.Clone(c.GlobalConditionKeys)
+	for _, s := range c.Services {
+		known = append(known, s.ConditionKeys...)
+		if s.Prefix == "" {
+			return nil, fmt.Errorf("catalog service %q has no prefix", s.Name)
+		}
+		for _, t := range s.ResourceTypes {
+			t.compile()
+		}
+		s.actions = map[string]*Action{}
+		for _, a := range s.Actions {
+			a.service = s
+			a.keys = newKeySet(a.ConditionKeys)
+			s.actions[strings.ToLower(a.Name)] = a
+		}
+		c.services[strings.ToLower(s.Prefix)] = s
+	}
+	c.known = newKeySet(known)
+	return c, nil
+}
+
+// Service returns the service with the given prefix.
+func (c *Catalog) Service(prefix string) (*Service, bool) {
+	s, ok := c.services[strings.ToLower(prefix)]
+	return s, ok
+}
+
+// Action looks up an action such as s3:GetObject. Like AWS, the lookup ignores
+// case.
+func (c *Catalog) Action(name string) (*Action, bool) {
+	prefix, action, ok := strings.Cut(name, ":")
+	if !ok {
+		return nil, false
+	}
+	s, ok := c.Service(prefix)
+	if !ok {
+		return nil, false
+	}
+	a, ok := s.actions[strings.ToLower(action)]
+	return a, ok
+}
+
+// AppliesTo reports whether action may be granted on the resource arn. It is
+// false when the catalog lists the action and arn is of a resource type of
+// its service the action does not act on, such as the bucket of s3:GetObject,
+// or the action only supports *. Actions and ARNs the catalog cannot tell
+// apart apply to any resource.
+func (c *Catalog) AppliesTo(action, arn string) bool {
+	a, ok := c.Action(action)
+	if !ok {
+		return true
+	}
+	if len(a.ResourceTypes) == 0 {
+		return false
+	}
+	t, ok := a.service.ResourceType(arn)
+	return !ok || slices.Contains(a.ResourceTypes, t.Name)
+}
+
+// ResourceType returns the resource type of s that arn is of. When several
+// ARN templates match, as that of buckets matches objects, the one with the
+// most literal text wins.
+func (s *Service) ResourceType(arn string) (*ResourceType, bool) {
+	var best *ResourceType
+	for _, t := range s.ResourceTypes {
+		if t.re != nil && t.re.MatchString(arn) && (best == nil || t.literal > best.literal) {
+			best = t
+		}
+	}
+	return best, best != nil
+}
+
+// compile builds the regular expression that matches the ARNs of t, in which
+// every variable of the template matches any non-empty text.
+func (t *ResourceType) compile() {
+	if t.Arn == "" {
+		return
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range templateVariable.FindAllStringIndex(t.Arn, -1) {
+		expr.WriteString(regexp.QuoteMeta(t.Arn[last:loc[0]]))
+		expr.WriteString(".+")
+		t.literal += loc[0] - last
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(t.Arn[last:]))
+	expr.WriteString("$")
+	t.literal += len(t.Arn) - last
+	t.re = regexp.MustCompile(expr.String())
+}
+
+// Expand returns the actions matched by pattern, which may contain the * and ?
+// wildcards or the <.*> the parsers produce, such as s3:Get<.*>. The result is
+// sorted and contains only actions known to the catalog.
+func (c *Catalog) Expand(pattern string) []string {
+	pattern = strings.ToLower(strings.ReplaceAll(pattern, "<.*>", "*"))
+	servicePattern, actionPattern, ok := strings.Cut(pattern, ":")
+	if !ok {
+		if pattern != "*" {
+			return nil
+		}
+		servicePattern, actionPattern = "*", "*"
+	}
+
+	var out []string
+	for prefix, s := range c.services {
+		if !wildcardMatch(servicePattern, prefix) {
+			continue
+		}
+		for name, a := range s.actions {
+			if wildcardMatch(actionPattern, name) {
+				out = append(out, a.FullName())
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SupportsConditionKey reports whether key can be used with action: it is a
+// global condition key or one the action lists. Keys written with a variable
+// part, such as aws:RequestTag/${TagKey}, match any value of that part.
+func (c *Catalog) SupportsConditionKey(action, key string) bool {
+	if c.global.contains(key) {
+		return true
+	}
+	a, ok := c.Action(action)
+	return ok && a.keys.contains(key)
+}
+
+// ConditionKey looks up a global or service condition key, ignoring case. It
+// returns the key as the catalog spells it, with the variable part of keys
+// such as aws:PrincipalTag/${TagKey} kept as given, and the key's type.
+func (c *Catalog) ConditionKey(key string) (spelling string, t KeyType, ok bool) {
+	spelling, listed, ok := c.known.lookup(key)
+	if !ok {
+		return "", "", false
+	}
+	for k, kt := range c.ConditionKeyTypes {
+		if strings.EqualFold(k, listed) {
+			return spelling, kt, true
+		}
+	}
+	return spelling, KeyString, true
+}
+
+// keySet matches condition keys case-insensitively against a list of keys and
+// key templates.
+type keySet struct {
+	exact     map[string]string // lowercased key to the key as listed
+	templates []keyTemplate
+}
+
+type keyTemplate struct {
+	key   string
+	parts []string // the text around the variables
+	re    *regexp.Regexp
+}
+
+var templateVariable = regexp.MustCompile(`\$\{[^}]*\}`)
+
+func newKeySet(keys []string) keySet {
+	s := keySet{exact: map[string]string{}}
+	for _, k := range keys {
+		if !strings.Contains(k, "${") {
+			s.exact[strings.ToLower(k)] = k
+			continue
+		}
+		t := keyTemplate{key: k}
+		var expr strings.Builder
+		expr.WriteString("(?i)^")
+		last := 0
+		for _, loc := range templateVariable.FindAllStringIndex(k, -1) {
+			t.parts = append(t.parts, k[last:loc[0]])
+			expr.WriteString(regexp.QuoteMeta(k[last:loc[0]]))
+			expr.WriteString("(.+)")
+			last = loc[1]
+		}
+		t.parts = append(t.parts, k[last:])
+		expr.WriteString(regexp.QuoteMeta(k[last:]))
+		expr.WriteString("$")
+		t.re = regexp.MustCompile(expr.String())
+		s.templates = append(s.templates, t)
+	}
+	return s
+}
+
+func (s keySet) contains(key string) bool {
+	_, _, ok := s.lookup(key)
+	return ok
+}
+
+// lookup returns the spelling of key in the set and the key or template as
+// listed.
+func (s keySet) lookup(key string) (spelling, listed string, ok bool) {
+	if k, ok := s.exact[strings.ToLower(key)]; ok {
+		return k, k, true
+	}
+	for _, t := range s.templates {
+		m := t.re.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		var b strings.Builder
+		for i, part := range t.parts {
+			b.WriteString(part)
+			if i+1 < len(m) {
+				b.WriteString(m[i+1])
+			}
+		}
+		return b.String(), t.key, true
+	}
+	return "", "", false
+}
+
+// wildcardMatch matches value against pattern, where * matches any run of
+// characters and ? a single one.
+func wildcardMatch(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || pattern[0] != value[0] {
+				return false
+			}
+		}
+		pattern, value = pattern[1:], value[1:]
+	}
+	return value == ""
+}
@@ -0,0 +1,138 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	c := Default()
+	require.NotEmpty(t, c.Version)
+	require.Same(t, c, Default())
+
+	s, ok := c.Service("S3")
+	require.True(t, ok)
+	require.Equal(t, "s3", s.Prefix)
+
+	a, ok := c.Action("s3:getobject")
+	require.True(t, ok)
+	require.Equal(t, "s3:GetObject", a.FullName())
+	require.Equal(t, []string{"object"}, a.ResourceTypes)
+
+	_, ok = c.Action("s3:GetObjects")
+	require.False(t, ok)
+	_, ok = c.Action("GetObject")
+	require.False(t, ok)
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load(strings.NewReader(`{
+		"version": "test",
+		"complete": true,
+		"global-condition-keys": ["aws:SourceIp"],
+		"services": [{"prefix": "demo", "actions": [{"name": "Run", "condition-keys": ["demo:Tag/${TagKey}"]}]}]
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, "test", c.Version)
+	require.True(t, c.Complete)
+	require.False(t, Default().Complete)
+	require.Equal(t, []string{"demo:Run"}, c.Expand("demo:*"))
+	require.True(t, c.SupportsConditionKey("demo:Run", "demo:Tag/team"))
+
+	_, err = Load(strings.NewReader(`{"services": [{"name": "no prefix"}]}`))
+	require.Error(t, err)
+	_, err = Load(strings.NewReader(`not json`))
+	require.Error(t, err)
+}
+
+func TestExpand(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		pattern  string
+		contains []string
+		excludes []string
+	}{
+		{pattern: "s3:Get<.*>", contains: []string{"s3:GetObject", "s3:GetBucketPolicy"}, excludes: []string{"s3:PutObject"}},
+		{pattern: "s3:*Object", contains: []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject"}, excludes: []string{"s3:GetObjectAcl"}},
+		{pattern: "iam:?etRole", contains: []string{"iam:GetRole"}},
+		{pattern: "S3:LISTBUCKET", contains: []string{"s3:ListBucket"}, excludes: []string{"s3:ListBucketVersions"}},
+		{pattern: "*:PassRole", contains: []string{"iam:PassRole"}},
+		{pattern: "<.*>", contains: []string{"sts:AssumeRole", "s3:GetObject"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got := c.Expand(tt.pattern)
+			for _, a := range tt.contains {
+				require.Contains(t, got, a)
+			}
+			for _, a := range tt.excludes {
+				require.NotContains(t, got, a)
+			}
+			require.IsIncreasing(t, got)
+		})
+	}
+
+	require.Empty(t, c.Expand("s3:Fly*"))
+	require.Empty(t, c.Expand("nosuchservice:*"))
+	require.Empty(t, c.Expand("GetObject"))
+}
+
+func TestSupportsConditionKey(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		action string
+		key    string
+		want   bool
+	}{
+		{action: "s3:ListBucket", key: "s3:prefix", want: true},
+		{action: "s3:GetObject", key: "s3:prefix", want: false},
+		{action: "s3:GetObject", key: "s3:ExistingObjectTag/team", want: true},
+		{action: "s3:GetObject", key: "aws:SourceIp", want: true},
+		{action: "s3:GetObject", key: "AWS:SOURCEIP", want: true},
+		{action: "s3:GetObject", key: "aws:PrincipalTag/team", want: true},
+		{action: "iam:CreateRole", key: "aws:RequestTag/team", want: true},
+		{action: "iam:GetRole", key: "aws:RequestTag/team", want: false},
+		{action: "iam:PassRole", key: "iam:PassedToService", want: true},
+		{action: "sts:AssumeRoleWithWebIdentity", key: "token.actions.githubusercontent.com:sub", want: true},
+		{action: "sts:AssumeRoleWithWebIdentity", key: "cognito-identity.amazonaws.com:aud", want: true},
+		{action: "kms:Decrypt", key: "kms:EncryptionContext:Department", want: true},
+		{action: "s3:NoSuchAction", key: "s3:prefix", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+" "+tt.key, func(t *testing.T) {
+			require.Equal(t, tt.want, c.SupportsConditionKey(tt.action, tt.key))
+		})
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		action string
+		arn    string
+		want   bool
+	}{
+		{action: "s3:GetObject", arn: "arn:aws:s3:::artifacts/app.zip", want: true},
+		{action: "s3:GetObject", arn: "arn:aws:s3:::artifacts"},
+		{action: "s3:ListBucket", arn: "arn:aws:s3:::artifacts", want: true},
+		{action: "s3:ListBucket", arn: "arn:aws:s3:::artifacts/app.zip"},
+		{action: "logs:CreateLogStream", arn: "arn:aws:logs:us-east-1:111122223333:log-group:/aws/lambda/app", want: true},
+		{action: "sts:AssumeRole", arn: "arn:aws:iam::111122223333:role/deploy", want: true},
+		{action: "cloudwatch:PutMetricData", arn: "arn:aws:cloudwatch:us-east-1:111122223333:alarm:high"},
+		// The catalog lists neither the action nor the resource type.
+		{action: "s3:GetObjectAttributes", arn: "arn:aws:s3:::artifacts", want: true},
+		{action: "s3:GetObject", arn: "arn:aws:s3:us-east-1:111122223333:job/1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+" "+tt.arn, func(t *testing.T) {
+			require.Equal(t, tt.want, c.AppliesTo(tt.action, tt.arn))
+		})
+	}
+
+	s, _ := c.Service("s3")
+	rt, ok := s.ResourceType("arn:aws:s3:::artifacts/logs/app.log")
+	require.True(t, ok)
+	require.Equal(t, "object", rt.Name)
+}
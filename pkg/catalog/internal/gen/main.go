@@ -0,0 +1,196 @@
+// Command gen builds the catalog from the service reference AWS publishes at
+// https://servicereference.us-east-1.amazonaws.com, which lists the actions,
+// resource types and condition keys of every service in JSON.
+//
+// The service reference has neither the global condition keys nor the names
+// of the services, so they are kept from the catalog the output replaces.
+//
+//	go run ./internal/gen -o data/aws.json
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+)
+
+// serviceEntry is an entry of the list of services of the service reference.
+type serviceEntry struct {
+	Service string `json:"service"`
+	Url     string `json:"url"`
+}
+
+// serviceReference is the service reference of one service.
+type serviceReference struct {
+	Name    string `json:"Name"`
+	Actions []struct {
+		Name                string   `json:"Name"`
+		ActionConditionKeys []string `json:"ActionConditionKeys"`
+		Resources           []struct {
+			Name string `json:"Name"`
+		} `json:"Resources"`
+		Annotations struct {
+			Properties struct {
+				IsList                 bool `json:"IsList"`
+				IsPermissionManagement bool `json:"IsPermissionManagement"`
+				IsTaggingOnly          bool `json:"IsTaggingOnly"`
+				IsWrite                bool `json:"IsWrite"`
+			} `json:"Properties"`
+		} `json:"Annotations"`
+	} `json:"Actions"`
+	Resources []struct {
+		Name       string   `json:"Name"`
+		ArnFormats []string `json:"ARNFormats"`
+	} `json:"Resources"`
+	ConditionKeys []struct {
+		Name  string   `json:"Name"`
+		Types []string `json:"Types"`
+	} `json:"ConditionKeys"`
+}
+
+func main() {
+	output := flag.String("o", "data/aws.json", "catalog to write; its global condition keys and service names are kept")
+	url := flag.String("url", "https://servicereference.us-east-1.amazonaws.com/", "list of services of the service reference")
+	version := flag.String("version", time.Now().UTC().Format(time.DateOnly), "version of the catalog")
+	flag.Parse()
+
+	previous, err := readCatalog(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, err := build(*url, previous)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c.Version = *version
+	if err := writeCatalog(*output, c); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readCatalog reads the catalog at path, or returns an empty one when there
+// is none.
+func readCatalog(path string) (*catalog.Catalog, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &catalog.Catalog{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return catalog.Load(f)
+}
+
+func build(url string, previous *catalog.Catalog) (*catalog.Catalog, error) {
+	var entries []serviceEntry
+	if err := fetch(url, &entries); err != nil {
+		return nil, err
+	}
+
+	c := &catalog.Catalog{
+		Complete:            true,
+		GlobalConditionKeys: previous.GlobalConditionKeys,
+		ConditionKeyTypes:   map[string]catalog.KeyType{},
+	}
+	for key, t := range previous.ConditionKeyTypes {
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			c.ConditionKeyTypes[key] = t
+		}
+	}
+	for _, e := range entries {
+		var ref serviceReference
+		if err := fetch(e.Url, &ref); err != nil {
+			return nil, err
+		}
+		c.Services = append(c.Services, service(e.Service, ref, previous, c.ConditionKeyTypes))
+	}
+	sort.Slice(c.Services, func(i, j int) bool { return c.Services[i].Prefix < c.Services[j].Prefix })
+	return c, nil
+}
+
+// service converts the service reference of prefix, recording the types of
+// its condition keys in types.
+func service(prefix string, ref serviceReference, previous *catalog.Catalog, types map[string]catalog.KeyType) *catalog.Service {
+	s := &catalog.Service{Prefix: prefix, Name: prefix}
+	if p, ok := previous.Service(prefix); ok && p.Name != "" {
+		s.Name = p.Name
+	}
+	for _, r := range ref.Resources {
+		t := &catalog.ResourceType{Name: r.Name}
+		if len(r.ArnFormats) > 0 {
+			t.Arn = r.ArnFormats[0]
+		}
+		s.ResourceTypes = append(s.ResourceTypes, t)
+	}
+	for _, k := range ref.ConditionKeys {
+		s.ConditionKeys = append(s.ConditionKeys, k.Name)
+		if len(k.Types) > 0 && k.Types[0] != string(catalog.KeyString) && !strings.HasPrefix(strings.ToLower(k.Name), "aws:") {
+			types[k.Name] = catalog.KeyType(k.Types[0])
+		}
+	}
+	for _, a := range ref.Actions {
+		action := &catalog.Action{Name: a.Name, AccessLevel: catalog.Read, ConditionKeys: a.ActionConditionKeys}
+		switch p := a.Annotations.Properties; {
+		case p.IsPermissionManagement:
+			action.AccessLevel = catalog.PermissionsManagement
+		case p.IsTaggingOnly:
+			action.AccessLevel = catalog.Tagging
+		case p.IsWrite:
+			action.AccessLevel = catalog.Write
+		case p.IsList:
+			action.AccessLevel = catalog.List
+		}
+		for _, r := range a.Resources {
+			action.ResourceTypes = append(action.ResourceTypes, r.Name)
+		}
+		s.Actions = append(s.Actions, action)
+	}
+	sort.Slice(s.Actions, func(i, j int) bool { return s.Actions[i].Name < s.Actions[j].Name })
+	return s
+}
+
+func fetch(url string, v any) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("fetch %s: %w", url, err)
+	}
+	return nil
+}
+
+func writeCatalog(path string, c *catalog.Catalog) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
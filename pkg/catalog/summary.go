@@ -0,0 +1,353 @@
+package catalog
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// ClassifiedAction is a concrete action granted or denied by a statement,
+// tagged with its access level.
+type ClassifiedAction struct {
+	Action string      `json:"action" yaml:"action"`
+	Level  AccessLevel `json:"access-level" yaml:"access-level"`
+}
+
+// Classify expands the Action entries of p, or the complement of its
+// NotAction entries, into the catalog actions they cover. Entries that cover
+// no catalog action, such as actions of services the catalog does not know,
+// are returned as unknown. Only actions the catalog lists are returned, so for
+// a partial catalog "*" and NotAction cover more than the result.
+func (c *Catalog) Classify(p *policy.Policy) (actions []ClassifiedAction, unknown []string) {
+	covered := map[string]bool{}
+	switch {
+	case len(p.Actions) > 0:
+		for _, entry := range p.Actions {
+			expanded := c.Expand(entry)
+			if len(expanded) == 0 {
+				unknown = append(unknown, entry)
+			}
+			for _, a := range expanded {
+				covered[a] = true
+			}
+		}
+	case len(p.NotActions) > 0:
+		excluded := map[string]bool{}
+		for _, entry := range p.NotActions {
+			for _, a := range c.Expand(entry) {
+				excluded[a] = true
+			}
+		}
+		for _, a := range c.Expand("*") {
+			if !excluded[a] {
+				covered[a] = true
+			}
+		}
+	}
+
+	for name := range covered {
+		a, _ := c.Action(name)
+		actions = append(actions, ClassifiedAction{Action: name, Level: a.AccessLevel})
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].Action < actions[j].Action })
+	return actions, unknown
+}
+
+// Summary describes what a set of statements allows and denies per service,
+// like the policy summary of the IAM console. Resources and conditions are not
+// taken into account.
+//
+// A partial catalog cannot tell whether a list of actions covers every action
+// of a service or level, so only entries that name every action of a service,
+// such as s3:* or *, give full access to it. Statements that cover
+// services it does not list, such as Action "*" or NotAction, add an entry
+// with the Service OtherServices, whose access is unknown.
+type Summary struct {
+	Allow   []ServiceSummary `json:"allow" yaml:"allow"`
+	Deny    []ServiceSummary `json:"deny" yaml:"deny"`
+	Unknown []string         `json:"unknown" yaml:"unknown"` // action entries that match nothing in the catalog
+}
+
+type ServiceSummary struct {
+	Service string         `json:"service" yaml:"service"` // service prefix
+	Name    string         `json:"name" yaml:"name"`
+	Full    bool           `json:"full-access" yaml:"full-access"` // every action of the service is covered
+	Partial bool           `json:"partial" yaml:"partial"`         // the catalog may not list every action of the service
+	Total   int            `json:"total" yaml:"total"`             // number of actions of the service in the catalog
+	Levels  []LevelSummary `json:"levels" yaml:"levels"`
+}
+
+// OtherServices is the Service of the summary of the services a partial
+// catalog does not list.
+const OtherServices = "*"
+
+type LevelSummary struct {
+	Level   AccessLevel `json:"access-level" yaml:"access-level"`
+	Full    bool        `json:"full" yaml:"full"` // every action of this level is covered, only known of a complete catalog
+	Actions []string    `json:"actions" yaml:"actions"`
+	Total   int         `json:"total" yaml:"total"` // number of actions of this level in the service
+}
+
+// Summarize summarizes the Allow and Deny statements of policies, such as the
+// statements of one document. Summarize a single statement by passing it on
+// its own.
+func (c *Catalog) Summarize(policies []*policy.Policy) *Summary {
+	allow := map[string]bool{}
+	deny := map[string]bool{}
+	allowWhole := map[string]bool{}
+	denyWhole := map[string]bool{}
+	unknown := map[string]bool{}
+	var allowOther, denyOther bool
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		for prefix := range c.wholeServices(p) {
+			if p.Allowed {
+				allowWhole[prefix] = true
+			} else {
+				denyWhole[prefix] = true
+			}
+		}
+		if c.CoversOtherServices(p) {
+			if p.Allowed {
+				allowOther = true
+			} else {
+				denyOther = true
+			}
+		}
+		actions, entries := c.Classify(p)
+		for _, a := range actions {
+			if p.Allowed {
+				allow[a.Action] = true
+			} else {
+				deny[a.Action] = true
+			}
+		}
+		for _, e := range entries {
+			unknown[e] = true
+		}
+	}
+
+	s := &Summary{
+		Allow: c.summarizeServices(allow, allowWhole),
+		Deny:  c.summarizeServices(deny, denyWhole),
+	}
+	other := ServiceSummary{Service: OtherServices, Name: "services not in the catalog", Partial: true}
+	if allowOther {
+		s.Allow = append(s.Allow, other)
+	}
+	if denyOther {
+		s.Deny = append(s.Deny, other)
+	}
+	for e := range unknown {
+		s.Unknown = append(s.Unknown, e)
+	}
+	sort.Strings(s.Unknown)
+	return s
+}
+
+// summarizeServices summarizes the covered actions per service. whole holds
+// the services of which every action is covered, listed by the catalog or not.
+func (c *Catalog) summarizeServices(covered, whole map[string]bool) []ServiceSummary {
+	byService := map[string][]string{}
+	for name := range covered {
+		prefix, _, _ := strings.Cut(name, ":")
+		byService[prefix] = append(byService[prefix], name)
+	}
+
+	var out []ServiceSummary
+	for prefix, names := range byService {
+		svc, _ := c.Service(prefix)
+		known := c.Complete || whole[strings.ToLower(svc.Prefix)]
+		summary := ServiceSummary{
+			Service: svc.Prefix,
+			Name:    svc.Name,
+			Full:    known && len(names) == len(svc.Actions),
+			Partial: !known,
+			Total:   len(svc.Actions),
+		}
+		sort.Strings(names)
+		for _, level := range AccessLevels {
+			l := LevelSummary{Level: level}
+			for _, a := range svc.Actions {
+				if a.AccessLevel == level {
+					l.Total++
+				}
+			}
+			for _, name := range names {
+				if a, _ := c.Action(name); a.AccessLevel == level {
+					l.Actions = append(l.Actions, name)
+				}
+			}
+			if len(l.Actions) == 0 {
+				continue
+			}
+			l.Full = known && len(l.Actions) == l.Total
+			summary.Levels = append(summary.Levels, l)
+		}
+		out = append(out, summary)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Service < out[j].Service })
+	return out
+}
+
+// wholeServices returns the lowercased prefixes of the catalog services of
+// which p covers every action, whether the catalog lists it or not: its Action
+// has an entry such as s3:* or *, or its NotAction has no entry for the
+// service.
+func (c *Catalog) wholeServices(p *policy.Policy) map[string]bool {
+	out := map[string]bool{}
+	wildcard := func(s string) bool { return strings.Trim(s, "*") == "" }
+	if len(p.Actions) > 0 {
+		for _, entry := range p.Actions {
+			prefix, name, ok := strings.Cut(strings.ToLower(strings.ReplaceAll(entry, "<.*>", "*")), ":")
+			if !ok {
+				if !wildcard(prefix) {
+					continue
+				}
+				name = "*"
+			}
+			if !wildcard(name) {
+				continue
+			}
+			for key := range c.services {
+				if wildcardMatch(prefix, key) {
+					out[key] = true
+				}
+			}
+		}
+		return out
+	}
+	if len(p.NotActions) == 0 {
+		return out
+	}
+	for key := range c.services {
+		excluded := slices.ContainsFunc(p.NotActions, func(entry string) bool {
+			prefix, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(entry, "<.*>", "*")), ":")
+			return wildcardMatch(prefix, key)
+		})
+		if !excluded {
+			out[key] = true
+		}
+	}
+	return out
+}
+
+// CoversOtherServices reports whether p covers actions of services a partial
+// catalog does not list: its Action names a wildcard service, as "*" does, or
+// its NotAction does not exclude every action.
+func (c *Catalog) CoversOtherServices(p *policy.Policy) bool {
+	if c.Complete {
+		return false
+	}
+	wildcard := func(s string) bool { return strings.Trim(s, "*?") == "" }
+	if len(p.Actions) > 0 {
+		for _, entry := range p.Actions {
+			prefix, _, _ := strings.Cut(strings.ReplaceAll(entry, "<.*>", "*"), ":")
+			if strings.ContainsAny(prefix, "*?") {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p.NotActions) == 0 {
+		return false
+	}
+	for _, entry := range p.NotActions {
+		prefix, name, ok := strings.Cut(strings.ReplaceAll(entry, "<.*>", "*"), ":")
+		if wildcard(prefix) && (!ok || wildcard(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe renders the access a service summary grants in words, such as
+// "full access to Amazon S3" or "read, limited write on Amazon DynamoDB".
+// Levels of a partial catalog are limited when some action the catalog lists
+// is not covered; otherwise they are named without a qualifier.
+func (s ServiceSummary) Describe() string {
+	name := s.Name
+	if name == "" {
+		name = s.Service
+	}
+	if s.Full {
+		return "full access to " + name
+	}
+	if len(s.Levels) == 0 {
+		return "unknown access to " + name
+	}
+	var levels []string
+	for _, l := range s.Levels {
+		level := strings.ToLower(string(l.Level))
+		if len(l.Actions) < l.Total {
+			level = "limited " + level
+		}
+		levels = append(levels, level)
+	}
+	return strings.Join(levels, ", ") + " on " + name
+}
+
+func (s *Summary) String() string {
+	var b strings.Builder
+	write := func(effect string, services []ServiceSummary) {
+		if len(services) == 0 {
+			return
+		}
+		var parts []string
+		for _, svc := range services {
+			parts = append(parts, svc.Describe())
+		}
+		fmt.Fprintf(&b, "%s: %s\n", effect, strings.Join(parts, "; "))
+	}
+	write("Allow", s.Allow)
+	write("Deny", s.Deny)
+	if len(s.Unknown) > 0 {
+		fmt.Fprintf(&b, "Not in catalog: %s\n", strings.Join(s.Unknown, ", "))
+	}
+	return b.String()
+}
+
+// Markdown renders the summary as a table, for review comments.
+func (s *Summary) Markdown() string {
+	var b strings.Builder
+	b.WriteString("| Effect | Service | Access level | Actions |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	write := func(effect string, services []ServiceSummary) {
+		for _, svc := range services {
+			if len(svc.Levels) == 0 {
+				fmt.Fprintf(&b, "| %s | %s | Unknown | |\n", effect, svc.Name)
+				continue
+			}
+			var levels []string
+			count := 0
+			for _, l := range svc.Levels {
+				level := string(l.Level)
+				if len(l.Actions) < l.Total {
+					level = "Limited: " + level
+				}
+				levels = append(levels, level)
+				count += len(l.Actions)
+			}
+			access := strings.Join(levels, ", ")
+			if svc.Full {
+				access = "Full access"
+			}
+			total := fmt.Sprint(svc.Total)
+			if svc.Partial {
+				total += " listed"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %d of %s |\n", effect, svc.Name, access, count, total)
+		}
+	}
+	write("Allow", s.Allow)
+	write("Deny", s.Deny)
+	for _, e := range s.Unknown {
+		fmt.Fprintf(&b, "| | `%s` | not in catalog | |\n", e)
+	}
+	return b.String()
+}
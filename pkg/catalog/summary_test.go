@@ -0,0 +1,153 @@
+package catalog
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+)
+
+func TestClassify(t *testing.T) {
+	c := Default()
+	policies := testutil.Parse(t, `{
+		"Statement": [
+			{"Effect": "Allow", "Action": ["s3:GetObject", "s3:PutBucketPolicy", "s3:List*", "foo:Bar"], "Resource": "*"},
+			{"Effect": "Allow", "NotAction": ["iam:*", "s3:*", "ec2:*"], "Resource": "*"}
+		]
+	}`)
+
+	actions, unknown := c.Classify(policies[0])
+	require.Equal(t, []string{"foo:Bar"}, unknown)
+	require.Contains(t, actions, ClassifiedAction{Action: "s3:GetObject", Level: Read})
+	require.Contains(t, actions, ClassifiedAction{Action: "s3:PutBucketPolicy", Level: PermissionsManagement})
+	require.Contains(t, actions, ClassifiedAction{Action: "s3:ListBucket", Level: List})
+	require.Contains(t, actions, ClassifiedAction{Action: "s3:ListAllMyBuckets", Level: List})
+	require.NotContains(t, actions, ClassifiedAction{Action: "s3:PutObject", Level: Write})
+
+	actions, unknown = c.Classify(policies[1])
+	require.Empty(t, unknown)
+	require.Contains(t, actions, ClassifiedAction{Action: "sts:AssumeRole", Level: Write})
+	for _, a := range actions {
+		require.NotRegexp(t, `^(iam|s3|ec2):`, a.Action)
+	}
+}
+
+func TestAccessLevelsAreSet(t *testing.T) {
+	valid := map[AccessLevel]bool{}
+	for _, l := range AccessLevels {
+		valid[l] = true
+	}
+	for _, s := range Default().Services {
+		for _, a := range s.Actions {
+			require.True(t, valid[a.AccessLevel], "%s has access level %q", a.FullName(), a.AccessLevel)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Statement": [
+			{"Effect": "Allow", "Action": "s3:*", "Resource": "*"},
+			{"Effect": "Allow", "Action": ["dynamodb:GetItem", "dynamodb:ListTables"], "Resource": "*"},
+			{"Effect": "Allow", "Action": "ec2:Describe*", "Resource": "*"},
+			{"Effect": "Allow", "Action": ["iam:Put*", "iam:Attach*"], "Resource": "*"},
+			{"Effect": "Deny", "Action": "iam:PutUserPolicy", "Resource": "*"},
+			{"Effect": "Allow", "Action": "foo:Bar", "Resource": "*"}
+		]
+	}`)
+
+	s := Default().Summarize(policies)
+	require.Len(t, s.Allow, 4)
+	require.Equal(t, "dynamodb", s.Allow[0].Service)
+	require.Equal(t, "list, limited read on Amazon DynamoDB", s.Allow[0].Describe())
+	// The bundled catalog may not list every action of EC2 matching ec2:Describe*.
+	require.Equal(t, "ec2", s.Allow[1].Service)
+	require.False(t, s.Allow[1].Full)
+	require.True(t, s.Allow[1].Partial)
+	for _, l := range s.Allow[1].Levels {
+		require.False(t, l.Full)
+	}
+	require.Equal(t, "limited permissions management on AWS Identity and Access Management (IAM)", s.Allow[2].Describe())
+	// s3:* covers every action of S3, listed by the catalog or not.
+	require.True(t, s.Allow[3].Full)
+	require.False(t, s.Allow[3].Partial)
+	require.Equal(t, "full access to Amazon S3", s.Allow[3].Describe())
+	for _, l := range s.Allow[3].Levels {
+		require.True(t, l.Full)
+	}
+
+	require.Len(t, s.Deny, 1)
+	require.Equal(t, []string{"iam:PutUserPolicy"}, s.Deny[0].Levels[0].Actions)
+	require.Equal(t, []string{"foo:Bar"}, s.Unknown)
+
+	require.Equal(t, "Allow: list, limited read on Amazon DynamoDB; list on Amazon EC2; "+
+		"limited permissions management on AWS Identity and Access Management (IAM); full access to Amazon S3\n"+
+		"Deny: limited permissions management on AWS Identity and Access Management (IAM)\n"+
+		"Not in catalog: foo:Bar\n", s.String())
+
+	markdown := s.Markdown()
+	require.Contains(t, markdown, "| Effect | Service | Access level | Actions |\n")
+	require.Contains(t, markdown, "| Allow | Amazon S3 | Full access | 45 of 45 |")
+	require.Contains(t, markdown, "| Allow | Amazon EC2 | List | ")
+	require.Contains(t, markdown, "| Allow | Amazon DynamoDB | List, Limited: Read | 2 of ")
+	require.Contains(t, markdown, "| Deny | AWS Identity and Access Management (IAM) | Limited: Permissions management | 1 of ")
+	require.Contains(t, markdown, "| | `foo:Bar` | not in catalog | |\n")
+}
+
+func TestSummarize_Complete(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Statement": [
+			{"Effect": "Allow", "Action": ["s3:*", "dynamodb:GetItem"], "Resource": "*"},
+			{"Effect": "Allow", "NotAction": "iam:*", "Resource": "*"}
+		]
+	}`)
+
+	s := complete(t).Summarize(policies)
+	require.Len(t, s.Allow, len(Default().Services)-1)
+	for _, svc := range s.Allow {
+		require.NotEqual(t, OtherServices, svc.Service)
+		require.False(t, svc.Partial)
+	}
+	i := slices.IndexFunc(s.Allow, func(svc ServiceSummary) bool { return svc.Service == "s3" })
+	require.True(t, s.Allow[i].Full)
+	require.Equal(t, "full access to Amazon S3", s.Allow[i].Describe())
+	require.Contains(t, s.Markdown(), "| Allow | Amazon S3 | Full access | 45 of 45 |")
+}
+
+func TestSummarize_OtherServices(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		allow  bool
+		deny   bool
+		full   bool
+	}{
+		{name: "Any Action", policy: `{"Effect": "Allow", "Action": "*", "Resource": "*"}`, allow: true, full: true},
+		{name: "Any Service", policy: `{"Effect": "Deny", "Action": "*:Delete*", "Resource": "*"}`, deny: true},
+		{name: "NotAction", policy: `{"Effect": "Allow", "NotAction": ["iam:*", "s3:*"], "Resource": "*"}`, allow: true, full: true},
+		{name: "NotAction Of Everything", policy: `{"Effect": "Deny", "NotAction": "*", "Resource": "*"}`},
+		{name: "Catalog Service", policy: `{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}`, full: true},
+	}
+	other := func(services []ServiceSummary) bool {
+		return slices.ContainsFunc(services, func(s ServiceSummary) bool { return s.Service == OtherServices })
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Default().Summarize(testutil.Parse(t, `{"Statement": [`+tt.policy+`]}`))
+			require.Equal(t, tt.allow, other(s.Allow))
+			require.Equal(t, tt.deny, other(s.Deny))
+			for _, svc := range append(s.Allow, s.Deny...) {
+				require.Equal(t, tt.full && svc.Service != OtherServices, svc.Full, svc.Service)
+			}
+		})
+	}
+
+	s := Default().Summarize(testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]}`))
+	last := s.Allow[len(s.Allow)-1]
+	require.Equal(t, "unknown access to services not in the catalog", last.Describe())
+	require.True(t, strings.HasSuffix(s.String(), "; unknown access to services not in the catalog\n"))
+	require.Contains(t, s.Markdown(), "| Allow | services not in the catalog | Unknown | |\n")
+}
@@ -0,0 +1,256 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Validate checks the Actions and NotActions of parsed AWS statements against
+// the catalog. It reports actions that do not exist, such as s3:GetObjects,
+// wildcards that match no action, services missing from the catalog, and
+// condition keys that are unknown, mis-cased, compared with an operator of the
+// wrong type or not supported by the actions of the statement. Statement is
+// the index of the statement in policies.
+//
+// Only a Complete catalog can tell that an action, service or condition key
+// does not exist. A partial one, such as the bundled catalog, reports them
+// through Unknown instead, except for actions within a couple of edits of an
+// action it lists, which are reported as typos.
+func (c *Catalog) Validate(policies []*policy.Policy) []policy.Diagnostic {
+	problems, _ := c.check(policies)
+	return problems
+}
+
+// Unknown reports the actions, services and condition keys of parsed AWS
+// statements that a partial catalog does not list. They are information
+// rather than problems: AWS may well define them. A Complete catalog reports
+// none, as Validate reports them as problems.
+func (c *Catalog) Unknown(policies []*policy.Policy) []policy.Diagnostic {
+	_, unknown := c.check(policies)
+	return unknown
+}
+
+// CheckAction returns the problem Validate reports for action, such as the
+// s3:GetObjects typo, or nil when it reports none.
+func (c *Catalog) CheckAction(action string) error {
+	for _, f := range c.validateAction(0, "Action", action) {
+		if !f.missing || c.Complete {
+			return errors.New(f.Message)
+		}
+	}
+	return nil
+}
+
+// check returns the problems of policies and what they use that is not in
+// the catalog.
+func (c *Catalog) check(policies []*policy.Policy) (problems, unknown []policy.Diagnostic) {
+	for i, p := range policies {
+		if p == nil {
+			continue
+		}
+		var found []finding
+		for _, a := range p.Actions {
+			found = append(found, c.validateAction(i, "Action", a)...)
+		}
+		for _, a := range p.NotActions {
+			found = append(found, c.validateAction(i, "NotAction", a)...)
+		}
+		found = append(found, c.validateConditionKeys(i, p)...)
+		for _, f := range found {
+			if f.missing && !c.Complete {
+				unknown = append(unknown, f.Diagnostic)
+			} else {
+				problems = append(problems, f.Diagnostic)
+			}
+		}
+	}
+	return problems, unknown
+}
+
+// finding is a diagnostic and whether it is about something missing from the
+// catalog, which only a complete catalog can report as a problem.
+type finding struct {
+	policy.Diagnostic
+	missing bool
+}
+
+func (c *Catalog) validateAction(statement int, element, action string) []finding {
+	diagnostic := func(missing bool, format string, args ...any) []finding {
+		return []finding{{Diagnostic: policy.Diagnostic{
+			Statement: statement,
+			Key:       element,
+			Value:     action,
+			Message:   fmt.Sprintf(format, args...),
+		}, missing: missing}}
+	}
+
+	text := strings.ReplaceAll(action, "<.*>", "*")
+	if text == "*" {
+		return nil
+	}
+	prefix, name, ok := strings.Cut(text, ":")
+	if !ok || prefix == "" || name == "" {
+		return diagnostic(false, "%s is not of the form service:action", action)
+	}
+	if strings.ContainsAny(prefix, "*?") {
+		return nil
+	}
+	s, ok := c.Service(prefix)
+	if !ok {
+		return diagnostic(true, "service %s is not in the catalog", prefix)
+	}
+	if strings.ContainsAny(name, "*?") {
+		if len(c.Expand(text)) == 0 {
+			if !c.Complete {
+				return diagnostic(true, "%s matches no action in the catalog", text)
+			}
+			return diagnostic(true, "%s matches no action of %s", text, s.Prefix)
+		}
+		return nil
+	}
+	if _, ok := s.actions[strings.ToLower(name)]; ok {
+		return nil
+	}
+	// An action close to a listed one is a typo, even when the catalog is
+	// partial. A partial catalog only trusts a single edit, as actions it does
+	// not list are often a couple of edits from one it does, such as
+	// s3:PutObjectTagging from s3:GetObjectTagging.
+	maxEdits := 2
+	if !c.Complete {
+		maxEdits = 1
+	}
+	if suggestion := s.closest(name, maxEdits); suggestion != "" {
+		return diagnostic(false, "%s is not an action of %s, did you mean %s:%s?", action, s.Prefix, s.Prefix, suggestion)
+	}
+	if !c.Complete {
+		return diagnostic(true, "%s is not in the catalog", action)
+	}
+	return diagnostic(true, "%s is not an action of %s", action, s.Prefix)
+}
+
+// validateConditionKeys checks the keys of the Condition block. Keys of AWS or
+// of a service in the catalog must be known and spelled as the catalog spells
+// them, and the operator must suit the type of the key. Actions named in the
+// Action element must support the key, and a wildcard action must match at
+// least one action that does; wildcards usually cover actions a key is not
+// meant for, so the others are not reported.
+func (c *Catalog) validateConditionKeys(statement int, p *policy.Policy) []finding {
+	var out []finding
+	for _, cond := range p.Condition {
+		for _, key := range cond.Key {
+			report := func(missing bool, value, format string, args ...any) {
+				out = append(out, finding{Diagnostic: policy.Diagnostic{
+					Statement: statement,
+					Operator:  cond.Operation,
+					Key:       key,
+					Value:     value,
+					Message:   fmt.Sprintf(format, args...),
+				}, missing: missing})
+			}
+			diagnostic := func(value, format string, args ...any) {
+				report(false, value, format, args...)
+			}
+
+			spelling, keyType, ok := c.ConditionKey(key)
+			if !ok {
+				prefix, _, _ := strings.Cut(key, ":")
+				if _, known := c.Service(prefix); known || strings.EqualFold(prefix, "aws") {
+					report(true, "", "condition key %s is not in the catalog", key)
+				}
+				continue
+			}
+			if spelling != key {
+				diagnostic(spelling, "condition key %s is spelled %s", key, spelling)
+			}
+			if !operatorSuits(policy.OperatorFamily(cond.Operation), keyType) {
+				diagnostic(string(keyType), "operator %s does not suit condition key %s of type %s", cond.Operation, key, keyType)
+			}
+
+			for _, action := range p.Actions {
+				if a, ok := c.Action(action); ok {
+					if !c.SupportsConditionKey(action, key) {
+						diagnostic(action, "condition key %s is not supported by %s", key, a.FullName())
+					}
+					continue
+				}
+				expanded := c.Expand(action)
+				if len(expanded) == 0 || strings.ReplaceAll(action, "<.*>", "*") == "*" {
+					continue
+				}
+				supported := false
+				for _, e := range expanded {
+					if c.SupportsConditionKey(e, key) {
+						supported = true
+						break
+					}
+				}
+				if !supported {
+					text := strings.ReplaceAll(action, "<.*>", "*")
+					diagnostic(text, "condition key %s is not supported by any action %s matches", key, text)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// operatorSuits reports whether operators of family can compare values of a
+// key of type t. Date operators also accept numeric keys, which hold epoch
+// times, and string operators ARNs.
+func operatorSuits(family policy.Family, t KeyType) bool {
+	switch family {
+	case policy.FamilyString:
+		return slices.Contains([]KeyType{KeyString, KeyArn, KeyArrayOfString, KeyArrayOfArn}, t)
+	case policy.FamilyArn:
+		return t == KeyArn || t == KeyArrayOfArn
+	case policy.FamilyNumeric:
+		return t == KeyNumeric
+	case policy.FamilyDate:
+		return t == KeyDate || t == KeyNumeric
+	case policy.FamilyBool:
+		return t == KeyBool
+	case policy.FamilyIp:
+		return t == KeyIpAddress
+	}
+	// Null checks any key; unknown operators are reported by the parser.
+	return true
+}
+
+// closest returns the action of s nearest to name, if one is within maxEdits
+// edits.
+func (s *Service) closest(name string, maxEdits int) string {
+	best, bestDistance := "", maxEdits+1
+	lower := strings.ToLower(name)
+	for key, a := range s.actions {
+		d := editDistance(lower, key)
+		if d < bestDistance || (d == bestDistance && a.Name < best) {
+			best, bestDistance = a.Name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
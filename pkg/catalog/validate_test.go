@@ -0,0 +1,207 @@
+package catalog
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// complete returns the bundled catalog marked complete, as a generated one
+// would be.
+func complete(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := Load(bytes.NewReader(bundled))
+	require.NoError(t, err)
+	c.Complete = true
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Effect": "Allow",
+				"Action": ["s3:GetObjects", "s3:Get*", "s3:Fly*", "s4:GetObject", "*", "iam:PassRole"],
+				"Resource": "*"
+			},
+			{
+				"Effect": "Deny",
+				"NotAction": ["ec2:DescribeInstance", "sts:*"],
+				"Resource": "*"
+			},
+			{
+				"Effect": "Allow",
+				"Action": ["s3:ListBucket", "s3:GetObject", "s3:Put*"],
+				"Resource": "*",
+				"Condition": {
+					"StringLike": {"s3:prefix": "home/*"},
+					"Bool": {"aws:SecureTransport": "true"}
+				}
+			}
+		]
+	}`)
+
+	c := complete(t)
+	require.Empty(t, c.Unknown(policies))
+	var got []string
+	for _, d := range c.Validate(policies) {
+		got = append(got, d.String())
+	}
+	require.Equal(t, []string{
+		"statement 0: Action: s3:GetObjects is not an action of s3, did you mean s3:GetObject?",
+		"statement 0: Action: s3:Fly* matches no action of s3",
+		"statement 0: Action: service s4 is not in the catalog",
+		"statement 1: NotAction: ec2:DescribeInstance is not an action of ec2, did you mean ec2:DescribeInstances?",
+		"statement 2: StringLike s3:prefix: condition key s3:prefix is not supported by s3:GetObject",
+		"statement 2: StringLike s3:prefix: condition key s3:prefix is not supported by any action s3:Put* matches",
+	}, got)
+}
+
+func TestValidate_Partial(t *testing.T) {
+	// The bundled catalog does not list every action, so what it misses is
+	// reported as unknown, unless it is a single edit from a listed action.
+	policies := testutil.Parse(t, `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Action": ["s3:PutBucketOwnershipControls", "s3:GetObjectAttributes", "s3:Fly*", "athena:StartQueryExecution", "s3:GetObject", "s3:GetObjects"],
+			"Resource": "*",
+			"Condition": {"StringEquals": {"s3:Owner": "me"}, "NumericLessThan": {"aws:SourceIp": "3"}}
+		}]
+	}`)
+	c := Default()
+	require.False(t, c.Complete)
+
+	var problems, unknown []string
+	for _, d := range c.Validate(policies) {
+		problems = append(problems, d.String())
+	}
+	for _, d := range c.Unknown(policies) {
+		unknown = append(unknown, d.String())
+	}
+	require.Equal(t, []string{
+		"statement 0: Action: s3:GetObjects is not an action of s3, did you mean s3:GetObject?",
+		"statement 0: NumericLessThan aws:SourceIp: operator NumericLessThan does not suit condition key aws:SourceIp of type IPAddress",
+	}, problems)
+	slices.Sort(unknown)
+	require.Equal(t, []string{
+		"statement 0: Action: s3:Fly* matches no action in the catalog",
+		"statement 0: Action: s3:GetObjectAttributes is not in the catalog",
+		"statement 0: Action: s3:PutBucketOwnershipControls is not in the catalog",
+		"statement 0: Action: service athena is not in the catalog",
+		"statement 0: StringEquals s3:Owner: condition key s3:Owner is not in the catalog",
+	}, unknown)
+}
+
+func TestValidateConditionKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    string
+		condition string
+		want      []string
+	}{
+		{
+			name:      "Known Keys",
+			action:    `["s3:GetObject", "s3:GetObject*"]`,
+			condition: `{"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}, "StringEquals": {"aws:PrincipalTag/team": "data", "s3:ExistingObjectTag/owner": "me"}}`,
+		},
+		{
+			name:      "Mis-cased Keys",
+			action:    `"s3:GetObject"`,
+			condition: `{"IpAddress": {"aws:sourceip": "10.0.0.0/8"}, "StringEquals": {"AWS:principaltag/Team": "data"}}`,
+			want: []string{
+				"statement 0: IpAddress aws:sourceip: condition key aws:sourceip is spelled aws:SourceIp",
+				"statement 0: StringEquals AWS:principaltag/Team: condition key AWS:principaltag/Team is spelled aws:PrincipalTag/Team",
+			},
+		},
+		{
+			name:      "Unknown Keys",
+			action:    `"s3:GetObject"`,
+			condition: `{"StringEquals": {"aws:SourceIpAddress": "10.0.0.1", "s3:Owner": "me", "token.actions.githubusercontent.com:sub": "repo:org/app:*"}}`,
+			want: []string{
+				"statement 0: StringEquals aws:SourceIpAddress: condition key aws:SourceIpAddress is not in the catalog",
+				"statement 0: StringEquals s3:Owner: condition key s3:Owner is not in the catalog",
+				// Keys of identity providers are sts keys.
+				"statement 0: StringEquals token.actions.githubusercontent.com:sub: condition key token.actions.githubusercontent.com:sub is not supported by s3:GetObject",
+			},
+		},
+		{
+			name:      "Keys Of Other Actions",
+			action:    `["ec2:RunInstances", "iam:Pass*", "s3:Delete*"]`,
+			condition: `{"StringEquals": {"iam:PassedToService": "ec2.amazonaws.com"}}`,
+			want: []string{
+				"statement 0: StringEquals iam:PassedToService: condition key iam:PassedToService is not supported by any action s3:Delete* matches",
+				"statement 0: StringEquals iam:PassedToService: condition key iam:PassedToService is not supported by ec2:RunInstances",
+			},
+		},
+		{
+			name:   "Operator Types",
+			action: `"s3:ListBucket"`,
+			condition: `{"NumericLessThan": {"aws:SourceIp": "3", "s3:max-keys": "10"}, "StringEquals": {"aws:SecureTransport": "true"},
+				"DateGreaterThan": {"aws:CurrentTime": "2026-01-01T00:00:00Z", "aws:EpochTime": "1767225600"},
+				"ArnLike": {"aws:PrincipalArn": "arn:aws:iam::*:role/admin", "aws:SourceAccount": "arn:aws:iam::111122223333:root"},
+				"Null": {"aws:MultiFactorAuthAge": "true"}, "ForAnyValue:StringLike": {"aws:CalledVia": "athena.amazonaws.com"}}`,
+			want: []string{
+				"statement 0: ArnLike aws:SourceAccount: operator ArnLike does not suit condition key aws:SourceAccount of type String",
+				"statement 0: NumericLessThan aws:SourceIp: operator NumericLessThan does not suit condition key aws:SourceIp of type IPAddress",
+				"statement 0: StringEquals aws:SecureTransport: operator StringEquals does not suit condition key aws:SecureTransport of type Bool",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+				{"Effect": "Allow", "Action": `+tt.action+`, "Resource": "*", "Condition": `+tt.condition+`}]}`)
+			var got []string
+			for _, d := range complete(t).Validate(policies) {
+				got = append(got, d.String())
+			}
+			slices.Sort(got)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConditionKey(t *testing.T) {
+	spelling, keyType, ok := Default().ConditionKey("AWS:PRINCIPALTAG/CostCenter")
+	require.True(t, ok)
+	require.Equal(t, "aws:PrincipalTag/CostCenter", spelling)
+	require.Equal(t, KeyString, keyType)
+
+	spelling, keyType, ok = Default().ConditionKey("s3:MAX-KEYS")
+	require.True(t, ok)
+	require.Equal(t, "s3:max-keys", spelling)
+	require.Equal(t, KeyNumeric, keyType)
+
+	_, _, ok = Default().ConditionKey("s3:max-key")
+	require.False(t, ok)
+}
+
+func TestValidate_Clean(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Statement": [{
+			"Effect": "Allow",
+			"Action": ["sts:AssumeRoleWithWebIdentity"],
+			"Principal": {"Federated": "cognito-identity.amazonaws.com"},
+			"Condition": {
+				"StringEquals": {"cognito-identity.amazonaws.com:aud": "us-west-2:7e9abc23"},
+				"ForAnyValue:StringLike": {"cognito-identity.amazonaws.com:amr": "authenticated"}
+			}
+		}]
+	}`)
+	require.Empty(t, Default().Validate(policies))
+	require.Empty(t, Default().Validate([]*policy.Policy{nil}))
+}
+
+func TestEditDistance(t *testing.T) {
+	require.Equal(t, 0, editDistance("getobject", "getobject"))
+	require.Equal(t, 1, editDistance("getobjects", "getobject"))
+	require.Equal(t, 3, editDistance("kitten", "sitting"))
+	require.Equal(t, 4, editDistance("", "abcd"))
+}
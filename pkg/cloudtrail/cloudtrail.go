@@ -0,0 +1,267 @@
+// Package cloudtrail reads CloudTrail log files as delivered to S3: JSON
+// documents with a Records list, optionally gzipped.
+package cloudtrail
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/eval"
+)
+
+// Event is the part of a CloudTrail record that tells who performed which
+// action on which resources.
+type Event struct {
+	Time          time.Time `json:"time" yaml:"time"`
+	Source        string    `json:"source" yaml:"source"` // event source, such as s3.amazonaws.com
+	Name          string    `json:"name" yaml:"name"`     // event name, such as GetObject
+	Region        string    `json:"region" yaml:"region"`
+	Account       string    `json:"account" yaml:"account"`                                   // account of the caller
+	Principal     string    `json:"principal" yaml:"principal"`                               // ARN of the caller
+	SessionIssuer string    `json:"session-issuer,omitempty" yaml:"session-issuer,omitempty"` // ARN of the role behind a role session
+	Resources     []string  `json:"resources,omitempty" yaml:"resources,omitempty"`           // ARNs of the resources the event lists
+	ErrorCode     string    `json:"error-code,omitempty" yaml:"error-code,omitempty"`
+}
+
+// sourcePrefixes maps the event sources whose service prefix differs from the
+// first label of the source.
+var sourcePrefixes = map[string]string{
+	"monitoring.amazonaws.com": "cloudwatch",
+	"email.amazonaws.com":      "ses",
+	"api.ecr.amazonaws.com":    "ecr",
+}
+
+// apiVersion matches the API version some services append to event names,
+// such as the 20150331 of Lambda's UpdateFunctionCode20150331v2.
+var apiVersion = regexp.MustCompile(`\d{8}(v\d+)?$`)
+
+// eventActions maps the event names that are not the IAM action which
+// authorizes the call, prefixed like actions, to that action.
+var eventActions = map[string]string{
+	"s3:ListObjects":                        "s3:ListBucket",
+	"s3:ListObjectsV2":                      "s3:ListBucket",
+	"s3:ListObjectVersions":                 "s3:ListBucketVersions",
+	"s3:HeadBucket":                         "s3:ListBucket",
+	"s3:HeadObject":                         "s3:GetObject",
+	"s3:SelectObjectContent":                "s3:GetObject",
+	"s3:ListBuckets":                        "s3:ListAllMyBuckets",
+	"s3:CopyObject":                         "s3:PutObject",
+	"s3:CreateMultipartUpload":              "s3:PutObject",
+	"s3:UploadPart":                         "s3:PutObject",
+	"s3:UploadPartCopy":                     "s3:PutObject",
+	"s3:CompleteMultipartUpload":            "s3:PutObject",
+	"s3:DeleteObjects":                      "s3:DeleteObject",
+	"s3:ListParts":                          "s3:ListMultipartUploadParts",
+	"s3:ListMultipartUploads":               "s3:ListBucketMultipartUploads",
+	"s3:GetBucketEncryption":                "s3:GetEncryptionConfiguration",
+	"s3:PutBucketEncryption":                "s3:PutEncryptionConfiguration",
+	"s3:DeleteBucketEncryption":             "s3:PutEncryptionConfiguration",
+	"s3:GetBucketLifecycle":                 "s3:GetLifecycleConfiguration",
+	"s3:GetBucketLifecycleConfiguration":    "s3:GetLifecycleConfiguration",
+	"s3:PutBucketLifecycle":                 "s3:PutLifecycleConfiguration",
+	"s3:PutBucketLifecycleConfiguration":    "s3:PutLifecycleConfiguration",
+	"s3:DeleteBucketLifecycle":              "s3:PutLifecycleConfiguration",
+	"s3:GetBucketReplication":               "s3:GetReplicationConfiguration",
+	"s3:PutBucketReplication":               "s3:PutReplicationConfiguration",
+	"s3:DeleteBucketReplication":            "s3:PutReplicationConfiguration",
+	"s3:GetBucketNotificationConfiguration": "s3:GetBucketNotification",
+	"s3:PutBucketNotificationConfiguration": "s3:PutBucketNotification",
+	"s3:GetBucketCors":                      "s3:GetBucketCORS",
+	"s3:PutBucketCors":                      "s3:PutBucketCORS",
+	"s3:DeleteBucketCors":                   "s3:PutBucketCORS",
+	"s3:DeleteBucketTagging":                "s3:PutBucketTagging",
+	"s3:GetPublicAccessBlock":               "s3:GetBucketPublicAccessBlock",
+	"s3:PutPublicAccessBlock":               "s3:PutBucketPublicAccessBlock",
+	"s3:DeletePublicAccessBlock":            "s3:PutBucketPublicAccessBlock",
+	"s3:GetObjectLockConfiguration":         "s3:GetBucketObjectLockConfiguration",
+	"s3:PutObjectLockConfiguration":         "s3:PutBucketObjectLockConfiguration",
+	"lambda:Invoke":                         "lambda:InvokeFunction",
+	"lambda:InvokeWithResponseStream":       "lambda:InvokeFunction",
+	"dynamodb:TransactGetItems":             "dynamodb:GetItem",
+}
+
+// Action returns the IAM action of the event, such as s3:GetObject. Most
+// event names are the action, and the others, such as the ListObjects of a
+// call s3:ListBucket allows, are mapped to it. Check the result with
+// CatalogAction.
+func (e Event) Action() string {
+	prefix, ok := sourcePrefixes[e.Source]
+	if !ok {
+		prefix, _, _ = strings.Cut(e.Source, ".")
+	}
+	action := prefix + ":" + apiVersion.ReplaceAllString(e.Name, "")
+	if mapped, ok := eventActions[action]; ok {
+		return mapped
+	}
+	return action
+}
+
+// CatalogAction returns Action, or the problem c reports with it, as for an
+// event name that is no IAM action and that Action does not map.
+func (e Event) CatalogAction(c *catalog.Catalog) (string, error) {
+	action := e.Action()
+	if err := c.CheckAction(action); err != nil {
+		return "", fmt.Errorf("%s event %s: %w", e.Source, e.Name, err)
+	}
+	return action, nil
+}
+
+// Denied reports whether AWS refused the call for lack of permissions.
+func (e Event) Denied() bool {
+	return strings.Contains(e.ErrorCode, "AccessDenied") || e.ErrorCode == "Client.UnauthorizedOperation" ||
+		e.ErrorCode == "UnauthorizedOperation"
+}
+
+// Filter selects events. Zero fields select everything.
+type Filter struct {
+	// Principal is an ARN or account id as in a Principal element: a role
+	// matches its sessions, and an account every principal of the account.
+	Principal string
+	Start     time.Time // inclusive
+	End       time.Time // exclusive
+}
+
+// Match reports whether f selects e. Events of a role session match the role
+// through the session issuer.
+func (f Filter) Match(e Event) bool {
+	if !f.Start.IsZero() && e.Time.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && !e.Time.Before(f.End) {
+		return false
+	}
+	if f.Principal == "" {
+		return true
+	}
+	if e.SessionIssuer != "" && eval.MatchPrincipal(f.Principal, e.SessionIssuer, nil) {
+		return true
+	}
+	return eval.MatchPrincipal(f.Principal, e.Principal, nil)
+}
+
+type record struct {
+	EventTime    time.Time `json:"eventTime"`
+	EventSource  string    `json:"eventSource"`
+	EventName    string    `json:"eventName"`
+	AwsRegion    string    `json:"awsRegion"`
+	ErrorCode    string    `json:"errorCode"`
+	UserIdentity struct {
+		Arn            string `json:"arn"`
+		AccountId      string `json:"accountId"`
+		SessionContext struct {
+			SessionIssuer struct {
+				Arn string `json:"arn"`
+			} `json:"sessionIssuer"`
+		} `json:"sessionContext"`
+	} `json:"userIdentity"`
+	Resources []struct {
+		Arn string `json:"ARN"`
+	} `json:"resources"`
+}
+
+// Read decodes the events of one log file. The file may be gzipped, and may
+// hold a {"Records": [...]} document or a bare list of records.
+func Read(r io.Reader) ([]Event, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("error reading gzipped log: %w", err)
+		}
+		defer gz.Close()
+		return decode(gz)
+	}
+	return decode(br)
+}
+
+func decode(r io.Reader) ([]Event, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &records)
+	} else {
+		var doc struct {
+			Records []record `json:"Records"`
+		}
+		err = json.Unmarshal(data, &doc)
+		records = doc.Records
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading CloudTrail records: %w", err)
+	}
+
+	events := make([]Event, 0, len(records))
+	for _, rec := range records {
+		e := Event{
+			Time:          rec.EventTime,
+			Source:        rec.EventSource,
+			Name:          rec.EventName,
+			Region:        rec.AwsRegion,
+			Account:       rec.UserIdentity.AccountId,
+			Principal:     rec.UserIdentity.Arn,
+			SessionIssuer: rec.UserIdentity.SessionContext.SessionIssuer.Arn,
+			ErrorCode:     rec.ErrorCode,
+		}
+		for _, res := range rec.Resources {
+			if res.Arn != "" {
+				e.Resources = append(e.Resources, res.Arn)
+			}
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// ReadFiles reads the events of files and of every file below directories,
+// keeping those f matches, sorted by time.
+func ReadFiles(paths []string, f Filter) ([]Event, error) {
+	var events []Event
+	readFile := func(path string) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open log file %q: %w", path, err)
+		}
+		defer file.Close()
+		read, err := Read(file)
+		if err != nil {
+			return fmt.Errorf("read log file %q: %w", path, err)
+		}
+		for _, e := range read {
+			if f.Match(e) {
+				events = append(events, e)
+			}
+		}
+		return nil
+	}
+
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (p != path && strings.HasPrefix(d.Name(), ".")) {
+				return nil
+			}
+			return readFile(p)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
@@ -0,0 +1,123 @@
+package cloudtrail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+)
+
+func TestRead(t *testing.T) {
+	data, err := os.ReadFile("testdata/trail.json")
+	require.NoError(t, err)
+	events, err := Read(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, events, 6)
+
+	e := events[0]
+	require.Equal(t, "s3:GetObject", e.Action())
+	require.Equal(t, "arn:aws:sts::111122223333:assumed-role/deploy/build", e.Principal)
+	require.Equal(t, "arn:aws:iam::111122223333:role/deploy", e.SessionIssuer)
+	require.Equal(t, []string{"arn:aws:s3:::artifacts/app.zip", "arn:aws:s3:::artifacts"}, e.Resources)
+	require.Equal(t, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), e.Time)
+
+	require.Equal(t, "lambda:UpdateFunctionCode", events[1].Action())
+	require.Equal(t, "cloudwatch:PutMetricData", events[2].Action())
+	require.True(t, events[3].Denied())
+	require.False(t, events[0].Denied())
+
+	// Gzipped files and bare record lists read the same.
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	zipped, err := Read(&gz)
+	require.NoError(t, err)
+	require.Equal(t, events, zipped)
+
+	bare, err := Read(bytes.NewReader([]byte(`[{"eventSource": "sts.amazonaws.com", "eventName": "GetCallerIdentity"}]`)))
+	require.NoError(t, err)
+	require.Equal(t, "sts:GetCallerIdentity", bare[0].Action())
+
+	_, err = Read(bytes.NewReader([]byte(`{"Records": {}}`)))
+	require.Error(t, err)
+}
+
+func TestEvent_Action(t *testing.T) {
+	tests := []struct {
+		source string
+		name   string
+		want   string
+		err    string
+	}{
+		{source: "s3.amazonaws.com", name: "GetObject", want: "s3:GetObject"},
+		{source: "s3.amazonaws.com", name: "ListObjectsV2", want: "s3:ListBucket"},
+		{source: "s3.amazonaws.com", name: "HeadObject", want: "s3:GetObject"},
+		{source: "s3.amazonaws.com", name: "HeadBucket", want: "s3:ListBucket"},
+		{source: "lambda.amazonaws.com", name: "Invoke", want: "lambda:InvokeFunction"},
+		{source: "lambda.amazonaws.com", name: "UpdateFunctionCode20150331v2", want: "lambda:UpdateFunctionCode"},
+		// Unlisted actions of a partial catalog may exist.
+		{source: "s3.amazonaws.com", name: "GetObjectAttributes", want: "s3:GetObjectAttributes"},
+		{source: "s3.amazonaws.com", name: "GetObjects", err: "s3.amazonaws.com event GetObjects: s3:GetObjects is not an action of s3, did you mean s3:GetObject?"},
+		{source: "s3.amazonaws.com", err: "s3.amazonaws.com event : s3: is not of the form service:action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source+" "+tt.name, func(t *testing.T) {
+			action, err := Event{Source: tt.source, Name: tt.name}.CatalogAction(catalog.Default())
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, action)
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	role := Event{Principal: "arn:aws:sts::111122223333:assumed-role/deploy/build", SessionIssuer: "arn:aws:iam::111122223333:role/deploy",
+		Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	user := Event{Principal: "arn:aws:iam::111122223333:user/alice", Time: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)}
+
+	require.True(t, Filter{}.Match(role))
+	require.True(t, Filter{Principal: "arn:aws:iam::111122223333:role/deploy"}.Match(role))
+	require.False(t, Filter{Principal: "arn:aws:iam::111122223333:role/deploy"}.Match(user))
+	require.True(t, Filter{Principal: "111122223333"}.Match(user))
+	require.True(t, Filter{Principal: "arn:aws:iam::111122223333:user/alice"}.Match(user))
+
+	march := Filter{Start: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)}
+	require.True(t, march.Match(role))
+	require.False(t, march.Match(user))
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("testdata/trail.json")
+	require.NoError(t, err)
+	nested := filepath.Join(dir, "AWSLogs", "111122223333", "CloudTrail")
+	require.NoError(t, os.MkdirAll(nested, 0o755))
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(nested, "trail.json.gz"), gz.Bytes(), 0o644))
+
+	events, err := ReadFiles([]string{dir}, Filter{Principal: "arn:aws:iam::111122223333:role/deploy"})
+	require.NoError(t, err)
+	require.Len(t, events, 5)
+	for i := 1; i < len(events); i++ {
+		require.False(t, events[i].Time.Before(events[i-1].Time))
+	}
+
+	_, err = ReadFiles([]string{filepath.Join(dir, "missing")}, Filter{})
+	require.Error(t, err)
+}
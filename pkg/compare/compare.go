@@ -0,0 +1,447 @@
+// Package compare decides whether two sets of parsed statements grant the
+// same access. It partitions the actions, resources and principals into the
+// regions the statements' patterns tell apart, picks one request from every
+// combination of regions and condition values, and evaluates both sets on it.
+// Any request one set allows and the other does not is a counterexample.
+package compare
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/paullesiak/policyparser/pkg/arn"
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/eval"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+type Relation string
+
+const (
+	Equivalent   Relation = "equivalent"   // both sets allow the same requests
+	Subset       Relation = "subset"       // the first set allows strictly less than the second
+	Superset     Relation = "superset"     // the first set allows strictly more than the second
+	Incomparable Relation = "incomparable" // each set allows requests the other does not
+)
+
+// Limits of a comparison. Counterexamples are capped per side; the number of
+// condition contexts and requests bound the time a comparison takes.
+const (
+	maxCounterexamples = 10
+	maxContexts        = 1024
+	maxRequests        = 200000
+)
+
+type Result struct {
+	Relation Relation       `json:"relation" yaml:"relation"`
+	OnlyA    []eval.Request `json:"only-a" yaml:"only-a"` // requests the first set allows and the second does not
+	OnlyB    []eval.Request `json:"only-b" yaml:"only-b"` // requests the second set allows and the first does not
+	Checked  int            `json:"checked" yaml:"checked"`
+	// Exhaustive is false when the statements have more regions or condition
+	// values than a comparison covers; the relation then only holds for the
+	// requests that were checked.
+	Exhaustive bool `json:"exhaustive" yaml:"exhaustive"`
+}
+
+func (r *Result) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%d requests checked", r.Relation, r.Checked)
+	if !r.Exhaustive {
+		b.WriteString(", not exhaustive")
+	}
+	b.WriteString(")\n")
+	write := func(label string, requests []eval.Request) {
+		for _, req := range requests {
+			fmt.Fprintf(&b, "  %s: %s\n", label, Describe(req))
+		}
+	}
+	write("only first allows", r.OnlyA)
+	write("only second allows", r.OnlyB)
+	return b.String()
+}
+
+// Describe renders a request in one line, such as
+// "action s3:GetObject, resource arn:aws:s3:::bucket/key, aws:SourceIp=10.0.0.1".
+func Describe(req eval.Request) string {
+	var parts []string
+	if req.Principal != "" {
+		parts = append(parts, "principal "+req.Principal)
+	}
+	parts = append(parts, "action "+req.Action)
+	if req.Resource != "" {
+		parts = append(parts, "resource "+req.Resource)
+	}
+	keys := make([]string, 0, len(req.Context))
+	for k := range req.Context {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, strings.Join(req.Context[k], ",")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Equal reports whether a and b allow the same requests.
+func Equal(a, b []*policy.Policy) bool {
+	return Compare(a, b).Relation == Equivalent
+}
+
+// Compare compares the requests a and b allow. Explicit Denies, wildcards and
+// the Not* elements are evaluated as AWS does. Condition keys are compared
+// with single values drawn from the policies, values on both sides of each
+// numeric, date and IP bound, and the key being absent. Keys of ForAllValues
+// and ForAnyValue operators are also compared with all their values at once,
+// but multi-valued keys have more sets than that, so such comparisons are not
+// exhaustive.
+func Compare(a, b []*policy.Policy) *Result {
+	all := slices.Concat(a, b)
+	res := &Result{Exhaustive: true}
+
+	actions, ok := actionWitnesses(all)
+	res.Exhaustive = res.Exhaustive && ok
+	resources, ok := resourceWitnesses(all)
+	res.Exhaustive = res.Exhaustive && ok
+	principals, ok := principalWitnesses(all)
+	res.Exhaustive = res.Exhaustive && ok
+	contexts, ok := contextWitnesses(all)
+	res.Exhaustive = res.Exhaustive && ok
+
+	for _, ctx := range contexts {
+		for _, principal := range principals {
+			for _, action := range actions {
+				for _, resource := range resources {
+					if res.Checked >= maxRequests {
+						res.Exhaustive = false
+						res.Relation = relation(len(res.OnlyA) > 0, len(res.OnlyB) > 0)
+						return res
+					}
+					res.Checked++
+					req := eval.Request{Principal: principal, Action: action, Resource: resource, Context: ctx}
+					inA := eval.Evaluate(a, req).Decision == eval.Allow
+					inB := eval.Evaluate(b, req).Decision == eval.Allow
+					switch {
+					case inA && !inB && len(res.OnlyA) < maxCounterexamples:
+						res.OnlyA = append(res.OnlyA, req)
+					case inB && !inA && len(res.OnlyB) < maxCounterexamples:
+						res.OnlyB = append(res.OnlyB, req)
+					}
+				}
+			}
+		}
+	}
+	res.Relation = relation(len(res.OnlyA) > 0, len(res.OnlyB) > 0)
+	return res
+}
+
+func relation(onlyA, onlyB bool) Relation {
+	switch {
+	case onlyA && onlyB:
+		return Incomparable
+	case onlyA:
+		return Superset
+	case onlyB:
+		return Subset
+	}
+	return Equivalent
+}
+
+// witnesses returns one value of every region of patterns. Regions that a
+// value of prefer falls into are represented by that value, so that
+// counterexamples name real actions where possible.
+func witnesses(patterns []string, fold bool, prefer []string) ([]string, bool) {
+	slices.Sort(patterns)
+	patterns = slices.Compact(patterns)
+	globs := make([]glob, 0, len(patterns))
+	for _, p := range patterns {
+		globs = append(globs, compileGlob(p, fold))
+	}
+	found, complete := regions(globs)
+	for _, v := range prefer {
+		key := v
+		if fold {
+			key = strings.ToLower(v)
+		}
+		sig := signature(globs, key)
+		if w, ok := found[sig]; ok && !slices.Contains(prefer, w) {
+			found[sig] = v
+		}
+	}
+	out := make([]string, 0, len(found))
+	for _, w := range found {
+		out = append(out, w)
+	}
+	sort.Strings(out)
+	return out, complete
+}
+
+func actionWitnesses(policies []*policy.Policy) ([]string, bool) {
+	var patterns []string
+	for _, p := range policies {
+		if p != nil {
+			patterns = append(patterns, p.Actions...)
+			patterns = append(patterns, p.NotActions...)
+		}
+	}
+	return witnesses(patterns, true, catalog.Default().Expand("*"))
+}
+
+func resourceWitnesses(policies []*policy.Policy) ([]string, bool) {
+	var patterns []string
+	for _, p := range policies {
+		if p != nil {
+			patterns = append(patterns, p.Resources...)
+			patterns = append(patterns, p.NotResources...)
+		}
+	}
+	return witnesses(patterns, false, nil)
+}
+
+// principalWitnesses adds, for every subject that stands for a whole account
+// or a role, a principal that only matches it through that relation: a user
+// of the account, or a session of the role.
+func principalWitnesses(policies []*policy.Policy) ([]string, bool) {
+	var patterns []string
+	for _, p := range policies {
+		if p != nil {
+			patterns = append(patterns, p.Subjects...)
+			patterns = append(patterns, p.NotSubjects...)
+		}
+	}
+	if len(patterns) == 0 {
+		// Identity policies apply to whoever they are attached to.
+		return []string{""}, true
+	}
+	var related []string
+	for _, s := range patterns {
+		if isAccountId(s) {
+			related = append(related, "arn:aws:iam::"+s+":user/witness")
+			continue
+		}
+		a, err := arn.Parse(s)
+		if err != nil || a.Service != "iam" || strings.Contains(s, "<.*>") {
+			continue
+		}
+		switch {
+		case a.Resource == "root":
+			related = append(related, "arn:"+a.Partition+":iam::"+a.Account+":user/witness")
+		case strings.HasPrefix(a.Resource, "role/"):
+			name := a.Resource[strings.LastIndex(a.Resource, "/")+1:]
+			related = append(related, "arn:"+a.Partition+":sts::"+a.Account+":assumed-role/"+name+"/witness")
+		}
+	}
+	out, complete := witnesses(patterns, false, nil)
+	for _, r := range related {
+		if !slices.Contains(out, r) {
+			out = append(out, r)
+		}
+	}
+	return out, complete
+}
+
+func isAccountId(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([^}*?$][^}]*)\}`)
+
+// variables returns the policy variables the patterns of policies reference.
+// Requests resolve every variable to its own text, so that a pattern with a
+// variable behaves like the same literal pattern.
+func variables(policies []*policy.Policy) eval.Context {
+	ctx := eval.Context{}
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		for _, v := range slices.Concat(p.Actions, p.NotActions, p.Resources, p.NotResources, p.Subjects, p.NotSubjects) {
+			for _, m := range variablePattern.FindAllStringSubmatch(v, -1) {
+				name, _, _ := strings.Cut(m[1], ",")
+				ctx[strings.TrimSpace(name)] = []string{"${" + m[1] + "}"}
+			}
+		}
+	}
+	return ctx
+}
+
+// contextWitnesses returns the condition contexts to compare with. When the
+// combinations of all keys' values exceed maxContexts, it varies one key at a
+// time instead and reports that the comparison is not exhaustive.
+func contextWitnesses(policies []*policy.Policy) ([]eval.Context, bool) {
+	base := variables(policies)
+
+	type keyValues struct {
+		key      string
+		values   []string
+		patterns bool       // a string or ARN operator uses the key
+		set      bool       // a ForAllValues or ForAnyValue operator uses the key
+		choices  [][]string // nil when the key is absent
+	}
+	byKey := map[string]*keyValues{}
+	var keys []string
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		for _, c := range p.Condition {
+			family := policy.OperatorFamily(c.Operation)
+			for i, key := range c.Key {
+				lower := strings.ToLower(key)
+				kv, ok := byKey[lower]
+				if !ok {
+					kv = &keyValues{key: key}
+					byKey[lower] = kv
+					keys = append(keys, lower)
+				}
+				var raw any
+				if i < len(c.Value) {
+					raw = c.Value[i]
+				}
+				kv.values = append(kv.values, conditionValues(family, policy.ValueStrings(raw))...)
+				kv.patterns = kv.patterns || family == policy.FamilyString || family == policy.FamilyArn
+				kv.set = kv.set || strings.HasPrefix(c.Operation, "ForAllValues:") || strings.HasPrefix(c.Operation, "ForAnyValue:")
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	complete := true
+	for _, k := range keys {
+		kv := byKey[k]
+		values := kv.values
+		if kv.patterns {
+			// String values may be patterns; add a value of every region they
+			// define, including one that matches none of them.
+			found, ok := witnesses(slices.Clone(values), false, values)
+			complete = complete && ok
+			values = append(values, found...)
+		}
+		slices.Sort(values)
+		values = slices.Compact(values)
+		kv.choices = [][]string{nil}
+		for _, v := range values {
+			kv.choices = append(kv.choices, []string{v})
+		}
+		if kv.set {
+			// Set operators tell single values and sets apart; try the set of
+			// every value, which includes one that matches none of them.
+			if len(values) > 1 {
+				kv.choices = append(kv.choices, values)
+			}
+			complete = false
+		}
+	}
+
+	with := func(set map[string][]string) eval.Context {
+		ctx := eval.Context{}
+		for k, v := range base {
+			ctx[k] = v
+		}
+		for k, v := range set {
+			delete(ctx, k)
+			for bk := range ctx {
+				if strings.EqualFold(bk, k) {
+					delete(ctx, bk)
+				}
+			}
+			if v != nil {
+				ctx[k] = v
+			}
+		}
+		return ctx
+	}
+
+	total := 1
+	for _, k := range keys {
+		total *= len(byKey[k].choices)
+		if total > maxContexts {
+			break
+		}
+	}
+	if total > maxContexts {
+		out := []eval.Context{with(nil)}
+		for _, k := range keys {
+			kv := byKey[k]
+			for _, v := range kv.choices[1:] {
+				out = append(out, with(map[string][]string{kv.key: v}))
+			}
+		}
+		return out, false
+	}
+
+	out := []eval.Context{}
+	var walk func(i int, set map[string][]string)
+	walk = func(i int, set map[string][]string) {
+		if i == len(keys) {
+			out = append(out, with(set))
+			return
+		}
+		kv := byKey[keys[i]]
+		for _, v := range kv.choices {
+			set[kv.key] = v
+			walk(i+1, set)
+		}
+		delete(set, kv.key)
+	}
+	walk(0, map[string][]string{})
+	return out, complete
+}
+
+// conditionValues returns the policy values of a condition key, plus values
+// on both sides of the bounds numeric, date and IP operators set. Numbers are
+// also offset by a half, to tell integer from fractional bounds apart.
+func conditionValues(family policy.Family, values []string) []string {
+	var out []string
+	for _, raw := range values {
+		out = append(out, raw)
+		v, err := policy.ParseTypedValue(family, raw)
+		if err != nil || strings.Contains(raw, "${") {
+			continue
+		}
+		switch family {
+		case policy.FamilyNumeric:
+			for _, d := range []float64{-1, -0.5, 0.5, 1} {
+				out = append(out, new(big.Float).Add(v.Number, big.NewFloat(d)).Text('f', -1))
+			}
+		case policy.FamilyDate:
+			out = append(out,
+				v.Time.Add(-time.Second).Format(time.RFC3339),
+				v.Time.Format(time.RFC3339),
+				v.Time.Add(time.Second).Format(time.RFC3339))
+		case policy.FamilyIp:
+			out = append(out, v.Prefix.Addr().String())
+			if next := lastAddr(v.Prefix).Next(); next.IsValid() {
+				out = append(out, next.String())
+			}
+			if prev := v.Prefix.Addr().Prev(); prev.IsValid() {
+				out = append(out, prev.String())
+			}
+		case policy.FamilyBool, policy.FamilyNull:
+			out = append(out, "true", "false")
+		}
+	}
+	return out
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,159 @@
+package compare
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/eval"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		relation Relation
+	}{
+		{
+			name:     "Identical",
+			a:        `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+			b:        `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+			relation: Equivalent,
+		},
+		{
+			name: "Split Statements",
+			a:    `{"Statement": [{"Effect": "Allow", "Action": ["s3:GetObject", "s3:PutObject"], "Resource": "*"}]}`,
+			b: `{"Statement": [
+				{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"},
+				{"Effect": "Allow", "Action": "S3:PUTOBJECT", "Resource": "*"}]}`,
+			relation: Equivalent,
+		},
+		{
+			name:     "Narrower Resource",
+			a:        `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/logs/*"}]}`,
+			b:        `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+			relation: Subset,
+		},
+		{
+			name:     "Wildcard Action",
+			a:        `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`,
+			b:        `{"Statement": [{"Effect": "Allow", "Action": "s3:Get*", "Resource": "*"}]}`,
+			relation: Superset,
+		},
+		{
+			name: "NotAction Against Deny",
+			a:    `{"Statement": [{"Effect": "Allow", "NotAction": "iam:*", "Resource": "*"}]}`,
+			b: `{"Statement": [
+				{"Effect": "Allow", "Action": "*", "Resource": "*"},
+				{"Effect": "Deny", "Action": "iam:*", "Resource": "*"}]}`,
+			relation: Equivalent,
+		},
+		{
+			name: "Deny Removes Access",
+			a:    `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`,
+			b: `{"Statement": [
+				{"Effect": "Allow", "Action": "s3:*", "Resource": "*"},
+				{"Effect": "Deny", "Action": "s3:DeleteBucket", "Resource": "*"}]}`,
+			relation: Superset,
+		},
+		{
+			name:     "Disjoint",
+			a:        `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`,
+			b:        `{"Statement": [{"Effect": "Allow", "Action": "sqs:SendMessage", "Resource": "*"}]}`,
+			relation: Incomparable,
+		},
+		{
+			name: "Overlapping Patterns",
+			a:    `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::a*"}]}`,
+			b: `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::a*"},
+				{"Effect": "Deny", "Action": "s3:*", "NotResource": "arn:aws:s3:::*b"}]}`,
+			relation: Superset,
+		},
+		{
+			name: "Condition Narrows",
+			a:    `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`,
+			b: `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*",
+				"Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}}]}`,
+			relation: Superset,
+		},
+		{
+			name: "Fractional Numeric Bounds",
+			a: `{"Statement": [{"Effect": "Allow", "Action": "s3:ListBucket", "Resource": "*",
+				"Condition": {"NumericLessThan": {"s3:max-keys": "11"}}}]}`,
+			b: `{"Statement": [{"Effect": "Allow", "Action": "s3:ListBucket", "Resource": "*",
+				"Condition": {"NumericLessThanEquals": {"s3:max-keys": "10"}}}]}`,
+			relation: Superset,
+		},
+		{
+			name: "Principals",
+			a: `{"Statement": [{"Effect": "Allow", "Principal": {"AWS": "111122223333"},
+				"Action": "sqs:SendMessage", "Resource": "*"}]}`,
+			b: `{"Statement": [{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111122223333:role/app"},
+				"Action": "sqs:SendMessage", "Resource": "*"}]}`,
+			relation: Superset,
+		},
+		{
+			name:     "Policy Variables",
+			a:        `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::home/${aws:username}/*"}]}`,
+			b:        `{"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "arn:aws:s3:::home/${aws:username}/*"}]}`,
+			relation: Equivalent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := testutil.Parse(t, tt.a), testutil.Parse(t, tt.b)
+			res := Compare(a, b)
+			require.Equal(t, tt.relation, res.Relation, res.String())
+			require.True(t, res.Exhaustive)
+
+			// Counterexamples must really tell the sets apart.
+			for _, req := range res.OnlyA {
+				require.Equal(t, eval.Allow, eval.Evaluate(a, req).Decision)
+				require.NotEqual(t, eval.Allow, eval.Evaluate(b, req).Decision)
+			}
+			for _, req := range res.OnlyB {
+				require.Equal(t, eval.Allow, eval.Evaluate(b, req).Decision)
+				require.NotEqual(t, eval.Allow, eval.Evaluate(a, req).Decision)
+			}
+		})
+	}
+}
+
+func TestCompare_SetOperators(t *testing.T) {
+	// With the key present, single tag keys are allowed by both; only a
+	// request with several tag keys tells the operators apart.
+	statement := func(op string) string {
+		return `{"Statement": [{"Effect": "Allow", "Action": "ec2:CreateTags", "Resource": "*",
+			"Condition": {"` + op + `": {"aws:TagKeys": ["env", "team"]}, "Null": {"aws:TagKeys": "false"}}}]}`
+	}
+	a, b := testutil.Parse(t, statement("ForAllValues:StringEquals")), testutil.Parse(t, statement("ForAnyValue:StringEquals"))
+	res := Compare(a, b)
+	require.Equal(t, Subset, res.Relation, res.String())
+	require.False(t, res.Exhaustive)
+	require.NotEmpty(t, res.OnlyB)
+	require.Greater(t, len(res.OnlyB[0].Context.Get("aws:TagKeys")), 1)
+	require.Equal(t, eval.Allow, eval.Evaluate(b, res.OnlyB[0]).Decision)
+	require.NotEqual(t, eval.Allow, eval.Evaluate(a, res.OnlyB[0]).Decision)
+
+	res = Compare(a, a)
+	require.Equal(t, Equivalent, res.Relation)
+	require.False(t, res.Exhaustive)
+}
+
+func TestCompare_Counterexample(t *testing.T) {
+	a := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "s3:Get*", "Resource": "arn:aws:s3:::bucket/*"}]}`)
+	b := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`)
+	res := Compare(a, b)
+	require.Equal(t, Superset, res.Relation)
+	require.Empty(t, res.OnlyB)
+	require.NotEmpty(t, res.OnlyA)
+	// Actions come from the catalog where possible.
+	require.Regexp(t, `^s3:Get[A-Za-z]+$`, res.OnlyA[0].Action)
+	require.NotEqual(t, "s3:GetObject", res.OnlyA[0].Action)
+	require.Equal(t, "arn:aws:s3:::bucket/", res.OnlyA[0].Resource)
+
+	require.True(t, Equal(a, a))
+	require.False(t, Equal(a, b))
+}
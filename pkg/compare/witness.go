@@ -0,0 +1,196 @@
+package compare
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// A glob is a pattern of the parsed policy form compiled into tokens: "<.*>"
+// and "*" match any sequence, "?" any single character. The ${*}, ${?} and
+// ${$} escapes are literal characters; other policy variables are kept as
+// literal text, and requests resolve them to that same text (see variables).
+type glob []token
+
+type token struct {
+	wildcard byte // 0 for a literal, '?' or '*'
+	ch       byte
+}
+
+func compileGlob(pattern string, fold bool) glob {
+	if fold {
+		pattern = strings.ToLower(pattern)
+	}
+	var g glob
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "<.*>"):
+			g = append(g, token{wildcard: '*'})
+			i += len("<.*>")
+		case strings.HasPrefix(pattern[i:], "${*}"), strings.HasPrefix(pattern[i:], "${?}"), strings.HasPrefix(pattern[i:], "${$}"):
+			g = append(g, token{ch: pattern[i+2]})
+			i += 4
+		case pattern[i] == '*' || pattern[i] == '?':
+			g = append(g, token{wildcard: pattern[i]})
+			i++
+		default:
+			g = append(g, token{ch: pattern[i]})
+			i++
+		}
+	}
+	return g
+}
+
+// positions is the set of tokens of a glob that a prefix of the input can have
+// reached; len(glob) stands for the end of the pattern.
+type positions []bool
+
+func (g glob) start() positions {
+	s := make(positions, len(g)+1)
+	s[0] = true
+	return g.closure(s)
+}
+
+// closure adds the positions reachable by letting a * match nothing.
+func (g glob) closure(s positions) positions {
+	for i := range g {
+		if s[i] && g[i].wildcard == '*' {
+			s[i+1] = true
+		}
+	}
+	return s
+}
+
+func (g glob) step(s positions, c byte) positions {
+	next := make(positions, len(g)+1)
+	for i, t := range g {
+		if !s[i] {
+			continue
+		}
+		switch {
+		case t.wildcard == '*':
+			next[i] = true
+		case t.wildcard == '?' || t.ch == c:
+			next[i+1] = true
+		}
+	}
+	return g.closure(next)
+}
+
+func (g glob) accepts(s positions) bool {
+	return s[len(g)]
+}
+
+func (g glob) match(value string) bool {
+	s := g.start()
+	for i := 0; i < len(value); i++ {
+		s = g.step(s, value[i])
+	}
+	return g.accepts(s)
+}
+
+// maxStates bounds the search of regions.
+const maxStates = 20000
+
+// regions partitions all strings by the subset of globs they match and returns
+// the shortest string of every non-empty part, keyed by the part's signature.
+// It searches the product of the glob automata breadth first, over the
+// characters the globs use plus one character they do not. complete is false
+// when the search hit maxStates and some parts may be missing.
+func regions(globs []glob) (witnesses map[string]string, complete bool) {
+	used := map[byte]bool{}
+	for _, g := range globs {
+		for _, t := range g {
+			if t.wildcard == 0 {
+				used[t.ch] = true
+			}
+		}
+	}
+	// Every character the globs do not mention behaves the same.
+	for _, c := range []byte("xyz0123456789abcdefghijklmnopqrstuvw~") {
+		if !used[c] {
+			used[c] = true
+			break
+		}
+	}
+	alphabet := slices.Sorted(maps.Keys(used))
+
+	type state struct {
+		sets    []positions
+		witness string
+	}
+	key := func(sets []positions) string {
+		var b strings.Builder
+		for _, s := range sets {
+			for _, in := range s {
+				if in {
+					b.WriteByte('1')
+				} else {
+					b.WriteByte('0')
+				}
+			}
+			b.WriteByte('|')
+		}
+		return b.String()
+	}
+
+	initial := make([]positions, len(globs))
+	for i, g := range globs {
+		initial[i] = g.start()
+	}
+	witnesses = map[string]string{}
+	seen := map[string]bool{key(initial): true}
+	queue := []state{{sets: initial}}
+	for len(queue) > 0 {
+		st := queue[0]
+		queue = queue[1:]
+
+		sig := make([]byte, len(globs))
+		live := false
+		for i, g := range globs {
+			sig[i] = '0'
+			if g.accepts(st.sets[i]) {
+				sig[i] = '1'
+			}
+			for _, in := range st.sets[i] {
+				live = live || in
+			}
+		}
+		if _, ok := witnesses[string(sig)]; !ok {
+			witnesses[string(sig)] = st.witness
+		}
+		if !live {
+			continue
+		}
+
+		for _, c := range alphabet {
+			next := make([]positions, len(globs))
+			for i, g := range globs {
+				next[i] = g.step(st.sets[i], c)
+			}
+			k := key(next)
+			if seen[k] {
+				continue
+			}
+			if len(seen) >= maxStates {
+				return witnesses, false
+			}
+			seen[k] = true
+			queue = append(queue, state{sets: next, witness: st.witness + string(c)})
+		}
+	}
+	return witnesses, true
+}
+
+// signature returns the subset of globs value matches, in the form regions
+// uses for its keys.
+func signature(globs []glob, value string) string {
+	sig := make([]byte, len(globs))
+	for i, g := range globs {
+		sig[i] = '0'
+		if g.match(value) {
+			sig[i] = '1'
+		}
+	}
+	return string(sig)
+}
@@ -0,0 +1,37 @@
+package compare
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegions(t *testing.T) {
+	globs := []glob{compileGlob("a<.*>", false), compileGlob("<.*>b", false), compileGlob("a?", false)}
+	found, complete := regions(globs)
+	require.True(t, complete)
+	for sig, w := range found {
+		require.Equal(t, sig, signature(globs, w), "witness %q", w)
+	}
+	// Every combination but "a?" without "a*" is possible.
+	require.Len(t, found, 6)
+	require.Equal(t, "ab", found["111"])
+	require.Equal(t, "", found["000"])
+}
+
+func TestWitnesses(t *testing.T) {
+	out, complete := witnesses([]string{"s3:Get<.*>", "s3:GetObject"}, true, []string{"s3:GetObject", "s3:GetBucketPolicy", "sqs:SendMessage"})
+	require.True(t, complete)
+	require.True(t, slices.Contains(out, "s3:GetObject"))
+	require.True(t, slices.Contains(out, "s3:GetBucketPolicy"))
+	require.True(t, slices.Contains(out, "sqs:SendMessage"))
+	require.Len(t, out, 3)
+}
+
+func TestCompileGlob(t *testing.T) {
+	g := compileGlob("a${*}b?", false)
+	require.True(t, g.match("a*bc"))
+	require.False(t, g.match("axbc"))
+	require.True(t, compileGlob("S3:Get*", true).match("s3:getobject"))
+}
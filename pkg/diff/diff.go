@@ -0,0 +1,241 @@
+// Package diff compares two versions of a policy by what they grant rather
+// than by their text. Statements are broken down into permissions, one per
+// principal, action and resource, so that reordering statements or entries,
+// renaming Sids and splitting or merging statements leave the diff empty.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/compare"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Permission is one principal, action and resource of a statement, under all
+// of the statement's conditions. Statements with NotPrincipal, NotAction or
+// NotResource keep the whole list in the Not* field, as the entries only mean
+// something together.
+type Permission struct {
+	Effect       string   `json:"effect" yaml:"effect"`
+	Principal    string   `json:"principal,omitempty" yaml:"principal,omitempty"`
+	NotPrincipal []string `json:"not-principal,omitempty" yaml:"not-principal,omitempty"`
+	Action       string   `json:"action,omitempty" yaml:"action,omitempty"`
+	NotAction    []string `json:"not-action,omitempty" yaml:"not-action,omitempty"`
+	Resource     string   `json:"resource,omitempty" yaml:"resource,omitempty"`
+	NotResource  []string `json:"not-resource,omitempty" yaml:"not-resource,omitempty"`
+	Conditions   []string `json:"conditions,omitempty" yaml:"conditions,omitempty"` // such as StringEquals aws:SourceAccount [111122223333]
+	Expands      int      `json:"expands,omitempty" yaml:"expands,omitempty"`       // number of catalog actions a wildcard Action covers
+}
+
+func (p Permission) String() string {
+	var b strings.Builder
+	b.WriteString(p.Effect)
+	switch {
+	case len(p.NotAction) > 0:
+		fmt.Fprintf(&b, " every action except %s", strings.Join(p.NotAction, ", "))
+	default:
+		b.WriteString(" " + p.Action)
+		if p.Expands > 0 {
+			fmt.Fprintf(&b, " (%d actions)", p.Expands)
+		}
+	}
+	switch {
+	case len(p.NotResource) > 0:
+		fmt.Fprintf(&b, " on every resource except %s", strings.Join(p.NotResource, ", "))
+	case p.Resource != "":
+		b.WriteString(" on " + p.Resource)
+	}
+	switch {
+	case len(p.NotPrincipal) > 0:
+		fmt.Fprintf(&b, " for every principal except %s", strings.Join(p.NotPrincipal, ", "))
+	case p.Principal != "":
+		b.WriteString(" for " + p.Principal)
+	}
+	if len(p.Conditions) > 0 {
+		b.WriteString(" when " + strings.Join(p.Conditions, " and "))
+	}
+	return b.String()
+}
+
+// key identifies a permission. Actions ignore case, like AWS does.
+func (p Permission) key() string {
+	lower := make([]string, 0, len(p.NotAction))
+	for _, a := range p.NotAction {
+		lower = append(lower, strings.ToLower(a))
+	}
+	return strings.Join([]string{
+		p.Effect,
+		p.Principal, strings.Join(p.NotPrincipal, ","),
+		strings.ToLower(p.Action), strings.Join(lower, ","),
+		p.Resource, strings.Join(p.NotResource, ","),
+		strings.Join(p.Conditions, ";"),
+	}, "|")
+}
+
+// Permissions breaks statements down into their permissions, sorted and
+// without duplicates.
+func Permissions(policies []*policy.Policy) []Permission {
+	seen := map[string]bool{}
+	var out []Permission
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		base := Permission{
+			Effect:       "Deny",
+			NotPrincipal: entries(p.NotSubjects),
+			NotAction:    entries(p.NotActions),
+			NotResource:  entries(p.NotResources),
+			Conditions:   conditions(p.Condition),
+		}
+		if p.Allowed {
+			base.Effect = "Allow"
+		}
+		for _, principal := range orNone(p.Subjects, p.NotSubjects) {
+			for _, action := range orNone(p.Actions, p.NotActions) {
+				for _, resource := range orNone(p.Resources, p.NotResources) {
+					perm := base
+					perm.Principal = display(principal)
+					perm.Action = display(action)
+					perm.Resource = display(resource)
+					if strings.ContainsAny(perm.Action, "*?") {
+						perm.Expands = len(catalog.Default().Expand(action))
+					}
+					if k := perm.key(); !seen[k] {
+						seen[k] = true
+						out = append(out, perm)
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].key() < out[j].key() })
+	return out
+}
+
+// orNone returns the entries of an element, or a single empty entry when the
+// statement has no such element or uses its Not* form instead.
+func orNone(values, not []string) []string {
+	if len(values) == 0 || len(not) > 0 {
+		return []string{""}
+	}
+	return values
+}
+
+func entries(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, display(v))
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// display turns the <.*> of parsed policies back into *.
+func display(s string) string {
+	return strings.ReplaceAll(s, "<.*>", "*")
+}
+
+// conditions renders every key of a Condition block in a canonical form, so
+// that the order of operators, keys and values does not matter.
+func conditions(block []policy.Condition) []string {
+	var out []string
+	for _, c := range block {
+		for i, key := range c.Key {
+			var raw any
+			if i < len(c.Value) {
+				raw = c.Value[i]
+			}
+			values := slices.Clone(policy.ValueStrings(raw))
+			slices.Sort(values)
+			values = slices.Compact(values)
+			out = append(out, fmt.Sprintf("%s %s [%s]", c.Operation, strings.ToLower(key), strings.Join(values, ", ")))
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+type Result struct {
+	Added   []Permission `json:"added" yaml:"added"`
+	Removed []Permission `json:"removed" yaml:"removed"`
+	// Access compares the requests the old and the new policies allow; its
+	// OnlyA requests lost access and its OnlyB requests gained it.
+	Access *compare.Result `json:"access" yaml:"access"`
+}
+
+// Policies diffs the statements of a policy before and after a change. Both
+// sides are normalized first, so entries another entry covers, such as
+// s3:GetObject next to s3:*, do not show up as changes.
+func Policies(before, after []*policy.Policy) *Result {
+	old, updated := Permissions(policy.Normalize(before)), Permissions(policy.Normalize(after))
+	keys := func(perms []Permission) map[string]bool {
+		m := map[string]bool{}
+		for _, p := range perms {
+			m[p.key()] = true
+		}
+		return m
+	}
+	oldKeys, updatedKeys := keys(old), keys(updated)
+
+	r := &Result{Access: compare.Compare(before, after)}
+	for _, p := range updated {
+		if !oldKeys[p.key()] {
+			r.Added = append(r.Added, p)
+		}
+	}
+	for _, p := range old {
+		if !updatedKeys[p.key()] {
+			r.Removed = append(r.Removed, p)
+		}
+	}
+	return r
+}
+
+// Empty reports whether the policies have the same permissions.
+func (r *Result) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0
+}
+
+// Verdict describes in words how the access of the new policies relates to
+// the old.
+func (r *Result) Verdict() string {
+	switch r.Access.Relation {
+	case compare.Equivalent:
+		return "the new policy grants the same access"
+	case compare.Subset:
+		return "the new policy grants more access"
+	case compare.Superset:
+		return "the new policy grants less access"
+	}
+	return "the new policy grants some access the old did not and removes some it did"
+}
+
+func (r *Result) String() string {
+	var b strings.Builder
+	for _, p := range r.Removed {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+	for _, p := range r.Added {
+		fmt.Fprintf(&b, "+ %s\n", p)
+	}
+	b.WriteString(r.Verdict())
+	if !r.Access.Exhaustive {
+		b.WriteString(" (not every request was checked)")
+	}
+	b.WriteString("\n")
+	for _, req := range r.Access.OnlyB {
+		fmt.Fprintf(&b, "  now allowed: %s\n", compare.Describe(req))
+	}
+	for _, req := range r.Access.OnlyA {
+		fmt.Fprintf(&b, "  now denied: %s\n", compare.Describe(req))
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/compare"
+)
+
+func TestPolicies_Cosmetic(t *testing.T) {
+	before := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Read", "Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"],
+			"Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"],
+			"Condition": {"StringEquals": {"aws:SourceVpce": ["vpce-2", "vpce-1"]}, "Bool": {"aws:SecureTransport": "true"}}}]}`)
+	after := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "List", "Effect": "Allow", "Action": "S3:ListBucket",
+			"Resource": ["arn:aws:s3:::bucket/*", "arn:aws:s3:::bucket"],
+			"Condition": {"Bool": {"aws:SecureTransport": "true"}, "StringEquals": {"aws:SourceVpce": ["vpce-1", "vpce-2"]}}},
+		{"Sid": "Get", "Effect": "Allow", "Action": "s3:GetObject",
+			"Resource": ["arn:aws:s3:::bucket", "arn:aws:s3:::bucket/*"],
+			"Condition": {"StringEquals": {"aws:SourceVpce": ["vpce-1", "vpce-2"]}, "Bool": {"aws:SecureTransport": "true"}}}]}`)
+
+	r := Policies(before, after)
+	require.True(t, r.Empty(), r.String())
+	require.Equal(t, compare.Equivalent, r.Access.Relation)
+	require.Equal(t, "the new policy grants the same access\n", r.String())
+}
+
+func TestPolicies_Wildcard(t *testing.T) {
+	before := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "iam:GetRole", "Resource": "*"}]}`)
+	after := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "iam:*", "Resource": "*"}]}`)
+
+	r := Policies(before, after)
+	require.Len(t, r.Removed, 1)
+	require.Equal(t, "Allow iam:GetRole on *", r.Removed[0].String())
+	require.Len(t, r.Added, 1)
+	require.Equal(t, "iam:*", r.Added[0].Action)
+	require.Greater(t, r.Added[0].Expands, 1)
+	require.Equal(t, compare.Subset, r.Access.Relation)
+	require.Equal(t, "the new policy grants more access", r.Verdict())
+	require.NotEmpty(t, r.Access.OnlyB)
+	require.Empty(t, r.Access.OnlyA)
+}
+
+func TestPolicies_Changes(t *testing.T) {
+	before := testutil.Parse(t, `{"Statement": [
+		{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111122223333:root"}, "Action": "sqs:SendMessage", "Resource": "*"},
+		{"Effect": "Deny", "Principal": "*", "NotAction": "sqs:SendMessage", "Resource": "*",
+			"Condition": {"StringNotEquals": {"aws:PrincipalOrgID": "o-1"}}}]}`)
+	after := testutil.Parse(t, `{"Statement": [
+		{"Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::444455556666:root"}, "Action": "sqs:SendMessage", "Resource": "*"},
+		{"Effect": "Deny", "Principal": "*", "NotAction": "sqs:SendMessage", "Resource": "*",
+			"Condition": {"StringNotEquals": {"aws:PrincipalOrgID": "o-1"}}}]}`)
+
+	r := Policies(before, after)
+	require.Equal(t, []string{
+		"Allow sqs:SendMessage on * for arn:aws:iam::444455556666:root",
+	}, describe(r.Added))
+	require.Equal(t, []string{
+		"Allow sqs:SendMessage on * for arn:aws:iam::111122223333:root",
+	}, describe(r.Removed))
+	require.Equal(t, compare.Incomparable, r.Access.Relation)
+}
+
+func TestPermissions(t *testing.T) {
+	perms := Permissions(testutil.Parse(t, `{"Statement": [
+		{"Effect": "Allow", "NotAction": ["iam:*", "sts:*"], "NotResource": "arn:aws:s3:::secret/*",
+			"Condition": {"NumericLessThan": {"s3:max-keys": 10}}},
+		{"Effect": "Deny", "NotPrincipal": {"AWS": "111122223333"}, "Action": "s3:DeleteBucket", "Resource": "*"}]}`))
+	require.Equal(t, []string{
+		"Allow every action except iam:*, sts:* on every resource except arn:aws:s3:::secret/* when NumericLessThan s3:max-keys [10]",
+		"Deny s3:DeleteBucket on * for every principal except 111122223333",
+	}, describe(perms))
+}
+
+func describe(perms []Permission) []string {
+	var out []string
+	for _, p := range perms {
+		out = append(out, p.String())
+	}
+	return out
+}
+
+func TestPolicies_Covered(t *testing.T) {
+	before := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": ["s3:*", "s3:GetObject"], "Resource": "*"}]}`)
+	after := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "S3:*", "Resource": "*"}]}`)
+	require.True(t, Policies(before, after).Empty())
+}
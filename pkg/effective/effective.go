@@ -0,0 +1,459 @@
+// Package effective computes the access a principal is left with once all the
+// policies that apply to it are combined: its identity policies grant actions,
+// and permissions boundaries, SCPs and Deny statements take them away again.
+package effective
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/eval"
+	"github.com/paullesiak/policyparser/pkg/parser"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Grant is one way an action is allowed: an identity statement, narrowed by
+// the other policies.
+type Grant struct {
+	Resources    []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+	NotResources []string `json:"not-resources,omitempty" yaml:"not-resources,omitempty"`
+	Except       []string `json:"except,omitempty" yaml:"except,omitempty"` // resources an unconditional Deny takes away
+	// Conditions must all hold for the grant to apply: the conditions of the
+	// granting statement, what the limiting policies allow, and the negated
+	// conditions of Deny statements.
+	Conditions []string `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Source     string   `json:"source" yaml:"source"` // policy of the granting statement
+	Statement  string   `json:"statement" yaml:"statement"`
+}
+
+type Action struct {
+	Action string              `json:"action" yaml:"action"`
+	Level  catalog.AccessLevel `json:"access-level" yaml:"access-level"`
+	Grants []Grant             `json:"grants" yaml:"grants"`
+}
+
+type Service struct {
+	Service string   `json:"service" yaml:"service"` // service prefix
+	Actions []Action `json:"actions" yaml:"actions"`
+}
+
+type Report struct {
+	Services []Service `json:"services" yaml:"services"`
+	Unknown  []string  `json:"unknown,omitempty" yaml:"unknown,omitempty"` // allowed action entries that match nothing in the catalog
+}
+
+// limiting are the policy types that only restrict what identity policies
+// grant.
+var limiting = []eval.PolicyType{
+	eval.ServiceControlPolicy,
+	eval.ResourceControlPolicy,
+	eval.PermissionsBoundary,
+	eval.SessionPolicy,
+}
+
+// perSet are the limiting policy types of which every set must allow an
+// action, as each SCP or RCP given applies to the account. The sets of the
+// other types together make up one policy.
+var perSet = []eval.PolicyType{eval.ServiceControlPolicy, eval.ResourceControlPolicy}
+
+// SetsFor collects the policies of an authorization details dump that apply
+// to the user or role principal: its inline and managed policies, those of
+// the groups of a user, and its permissions boundary. Append the SCPs that
+// apply to the account, which the dump does not include.
+func SetsFor(details *parser.AuthorizationDetails, principal string) ([]eval.PolicySet, error) {
+	var entity *parser.Entity
+	for i := range details.Entities {
+		if details.Entities[i].Arn == principal {
+			entity = &details.Entities[i]
+		}
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("principal %q is not in the authorization details", principal)
+	}
+
+	identity := eval.PolicySet{Type: eval.IdentityPolicy}
+	boundary := eval.PolicySet{Type: eval.PermissionsBoundary}
+	owners := append([]string{entity.Arn}, entity.Groups...)
+	for _, p := range details.Policies {
+		if p.Source == nil || !slices.Contains(owners, p.Source.Entity) {
+			continue
+		}
+		switch p.Source.Relation {
+		case policy.RelationInline, policy.RelationManaged:
+			identity.Policies = append(identity.Policies, p)
+		case policy.RelationPermissionsBoundary:
+			boundary.Policies = append(boundary.Policies, p)
+		}
+	}
+	sets := []eval.PolicySet{identity}
+	if len(boundary.Policies) > 0 {
+		sets = append(sets, boundary)
+	}
+	return sets, nil
+}
+
+// Analyze returns the actions the identity policies of sets allow that no
+// other policy takes away, per service. Actions are expanded with the bundled
+// catalog; what identity statements allow of services it does not list, such
+// as with Action "*", is reported under the Service catalog.OtherServices. A
+// limiting policy that is present, such as a permissions boundary or each
+// SCP, must allow an action; where it allows it only on some resources or
+// under conditions, those become conditions of the grant. Deny statements of
+// any type remove a grant when they apply to all of its resources without
+// conditions, and otherwise narrow it.
+//
+// Resource policies are not taken into account: they grant access to the
+// resource they are attached to, not to the principal.
+func Analyze(sets []eval.PolicySet) *Report {
+	c := catalog.Default()
+	var allows, denies []located
+	var limits []*limit
+	byType := map[eval.PolicyType]*limit{}
+	candidates := map[string]bool{}
+	unknown := map[string]bool{}
+	for _, set := range sets {
+		var l *limit
+		switch {
+		case slices.Contains(perSet, set.Type):
+			l = &limit{t: set.Type, name: set.Name}
+			limits = append(limits, l)
+		case slices.Contains(limiting, set.Type):
+			// A limiting policy type without Allow statements still limits.
+			if l = byType[set.Type]; l == nil {
+				l = &limit{t: set.Type}
+				byType[set.Type] = l
+				limits = append(limits, l)
+			}
+		}
+		for _, p := range set.Policies {
+			if p == nil {
+				continue
+			}
+			switch {
+			case !p.Allowed:
+				denies = append(denies, located{set, p})
+			case set.Type == eval.IdentityPolicy:
+				allows = append(allows, located{set, p})
+				actions, entries := c.Classify(p)
+				for _, a := range actions {
+					candidates[a.Action] = true
+				}
+				for _, e := range entries {
+					unknown[e] = true
+				}
+			case l != nil:
+				l.statements = append(l.statements, p)
+			}
+		}
+	}
+	slices.SortStableFunc(limits, func(a, b *limit) int {
+		return slices.Index(limiting, a.t) - slices.Index(limiting, b.t)
+	})
+
+	byService := map[string][]Action{}
+	for _, name := range sortedKeys(candidates) {
+		a := Action{Action: name}
+		if info, ok := c.Action(name); ok {
+			a.Level = info.AccessLevel
+		}
+		match := func(p *policy.Policy) bool { return matchAction(p, name) }
+		a.Grants = grants(allows, denies, limits, match, func(*policy.Policy) []string { return nil })
+		if len(a.Grants) > 0 {
+			prefix, _, _ := strings.Cut(name, ":")
+			byService[prefix] = append(byService[prefix], a)
+		}
+	}
+
+	r := &Report{Unknown: sortedKeys(unknown)}
+	for _, prefix := range sortedKeys(byService) {
+		r.Services = append(r.Services, Service{Service: prefix, Actions: byService[prefix]})
+	}
+	// The actions of services the catalog does not list are unknown, so the
+	// statements that cover them are matched by what they cover of them.
+	if other := grants(allows, denies, limits, c.CoversOtherServices, actionScope); len(other) > 0 {
+		r.Services = append(r.Services, Service{Service: catalog.OtherServices, Actions: []Action{{Action: "*", Grants: other}}})
+	}
+	return r
+}
+
+// located is a statement with the set it belongs to.
+type located struct {
+	set eval.PolicySet
+	p   *policy.Policy
+}
+
+// limit is a limiting policy: one SCP or RCP, or the sets of another
+// limiting type together.
+type limit struct {
+	t          eval.PolicyType
+	name       string // set name of an SCP or RCP
+	statements []*policy.Policy
+}
+
+// grants returns the grants of the allows that match an action, narrowed by
+// the limits and denies that match it. only returns the part of the action a
+// statement applies to, nothing when it applies to all of it.
+func grants(allows, denies []located, limits []*limit, match func(*policy.Policy) bool, only func(*policy.Policy) []string) []Grant {
+	// Every limiting policy must allow the action.
+	var clauses []string
+	for _, l := range limits {
+		var matching []*policy.Policy
+		for _, p := range l.statements {
+			if match(p) {
+				matching = append(matching, p)
+			}
+		}
+		if len(matching) == 0 {
+			return nil
+		}
+		if clause := l.clause(matching, only); clause != "" {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	var out []Grant
+	for _, l := range allows {
+		if !match(l.p) {
+			continue
+		}
+		g := Grant{
+			Resources:    display(l.p.Resources),
+			NotResources: display(l.p.NotResources),
+			Conditions:   slices.Concat(only(l.p), conditions(l.p.Condition), clauses),
+			Source:       sourceName(l.set, l.p),
+			Statement:    l.p.Id,
+		}
+		removed := false
+		for _, d := range denies {
+			if !match(d.p) || !mayOverlap(d.p, l.p) {
+				continue
+			}
+			all := coversResources(d.p, l.p)
+			unconditional := len(d.p.Condition) == 0 && len(only(d.p)) == 0
+			switch {
+			case unconditional && all:
+				removed = true
+			case unconditional && len(d.p.Resources) > 0:
+				g.Except = append(g.Except, display(d.p.Resources)...)
+			case unconditional:
+				// A Deny with NotResource leaves only the resources it lists.
+				g.Conditions = append(g.Conditions, "resource in ["+strings.Join(display(d.p.NotResources), ", ")+"]")
+			case all:
+				g.Conditions = append(g.Conditions, "not ("+strings.Join(slices.Concat(only(d.p), conditions(d.p.Condition)), " and ")+")")
+			default:
+				g.Conditions = append(g.Conditions, "not ("+strings.Join(slices.Concat(scope(d.p), only(d.p), conditions(d.p.Condition)), " and ")+")")
+			}
+			if removed {
+				break
+			}
+		}
+		if !removed {
+			slices.Sort(g.Except)
+			g.Except = slices.Compact(g.Except)
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// matchAction reports whether a statement applies to action.
+func matchAction(p *policy.Policy, action string) bool {
+	switch {
+	case len(p.Actions) > 0:
+		return matchAny(p.Actions, action, true)
+	case len(p.NotActions) > 0:
+		return !matchAny(p.NotActions, action, true)
+	}
+	return false
+}
+
+func matchAny(patterns []string, value string, ignoreCase bool) bool {
+	for _, pattern := range patterns {
+		if eval.Match(pattern, value, ignoreCase, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversResources reports whether statement d applies to every resource of
+// statement g. Resource patterns of g are matched as literal values, so a
+// wildcard of d covers a wildcard of g.
+func coversResources(d, g *policy.Policy) bool {
+	switch {
+	case len(d.Resources) == 0 && len(d.NotResources) == 0:
+		return true
+	case len(d.NotResources) > 0:
+		return false
+	case len(g.Resources) == 0:
+		return matchAny(d.Resources, "<.*>", false)
+	}
+	for _, r := range g.Resources {
+		if !matchAny(d.Resources, r, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// mayOverlap reports whether statement d can apply to some resource of
+// statement g, erring towards true.
+func mayOverlap(d, g *policy.Policy) bool {
+	if len(d.Resources) == 0 || len(g.Resources) == 0 {
+		return true
+	}
+	for _, dr := range d.Resources {
+		for _, gr := range g.Resources {
+			if eval.Match(dr, gr, false, nil) || eval.Match(gr, dr, false, nil) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clause describes what the statements of a limiting policy allow for one
+// action, or returns "" when one of them allows all of it on every resource
+// without conditions.
+func (l *limit) clause(statements []*policy.Policy, only func(*policy.Policy) []string) string {
+	var alternatives []string
+	for _, p := range statements {
+		parts := slices.Concat(scope(p), only(p), conditions(p.Condition))
+		if len(parts) == 0 {
+			return ""
+		}
+		alternatives = append(alternatives, strings.Join(parts, " and "))
+	}
+	slices.Sort(alternatives)
+	name := string(l.t)
+	if l.name != "" {
+		name += " " + l.name
+	}
+	return fmt.Sprintf("%s allows %s", name, strings.Join(slices.Compact(alternatives), " or "))
+}
+
+// actionScope describes the actions of a statement, nothing when it applies
+// to every action.
+func actionScope(p *policy.Policy) []string {
+	switch {
+	case slices.ContainsFunc(p.Actions, func(a string) bool { return strings.Trim(a, "*<.>:") == "" }):
+		return nil
+	case len(p.Actions) > 0:
+		return []string{"action in [" + strings.Join(display(p.Actions), ", ") + "]"}
+	case len(p.NotActions) > 0:
+		return []string{"action not in [" + strings.Join(display(p.NotActions), ", ") + "]"}
+	}
+	return nil
+}
+
+// scope describes the resources of a statement, nothing when it applies to
+// every resource.
+func scope(p *policy.Policy) []string {
+	switch {
+	case len(p.Resources) > 0 && !slices.Contains(p.Resources, "<.*>"):
+		return []string{"resource in [" + strings.Join(display(p.Resources), ", ") + "]"}
+	case len(p.NotResources) > 0:
+		return []string{"resource not in [" + strings.Join(display(p.NotResources), ", ") + "]"}
+	}
+	return nil
+}
+
+func display(values []string) []string {
+	var out []string
+	for _, v := range values {
+		out = append(out, strings.ReplaceAll(v, "<.*>", "*"))
+	}
+	return out
+}
+
+// conditions renders every key of a Condition block, such as
+// StringEquals aws:SourceAccount [111122223333].
+func conditions(block []policy.Condition) []string {
+	var out []string
+	for _, c := range block {
+		for i, key := range c.Key {
+			var raw any
+			if i < len(c.Value) {
+				raw = c.Value[i]
+			}
+			out = append(out, fmt.Sprintf("%s %s [%s]", c.Operation, key, strings.Join(policy.ValueStrings(raw), ", ")))
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// sourceName names the policy of a statement: the set name, or the ARN or name
+// of the policy it was imported from.
+func sourceName(set eval.PolicySet, p *policy.Policy) string {
+	switch {
+	case set.Name != "":
+		return set.Name
+	case p.Source == nil:
+		return string(set.Type)
+	case p.Source.PolicyArn != "":
+		return p.Source.PolicyArn
+	case p.Source.PolicyName != "":
+		return p.Source.Entity + ":" + p.Source.PolicyName
+	}
+	return p.Source.Entity
+}
+
+// String renders the report as a table with one row per grant.
+func (r *Report) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tACTION\tACCESS LEVEL\tRESOURCES\tCONDITIONS\tSOURCE")
+	for _, svc := range r.Services {
+		for _, a := range svc.Actions {
+			for _, g := range a.Grants {
+				level := string(a.Level)
+				if level == "" {
+					level = "Unknown"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", svc.Service, a.Action, level, g.resources(), orDash(g.Conditions), g.Source)
+			}
+		}
+	}
+	w.Flush()
+	if len(r.Unknown) > 0 {
+		fmt.Fprintf(&b, "Not in catalog: %s\n", strings.Join(r.Unknown, ", "))
+	}
+	return b.String()
+}
+
+func (g Grant) resources() string {
+	var s string
+	switch {
+	case len(g.Resources) > 0:
+		s = strings.Join(g.Resources, ", ")
+	case len(g.NotResources) > 0:
+		s = "* except " + strings.Join(g.NotResources, ", ")
+	default:
+		s = "*"
+	}
+	if len(g.Except) > 0 {
+		s += " (denied: " + strings.Join(g.Except, ", ") + ")"
+	}
+	return s
+}
+
+func orDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, "; ")
+}
@@ -0,0 +1,156 @@
+package effective
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/eval"
+	"github.com/paullesiak/policyparser/pkg/parser"
+)
+
+func bobSets(t *testing.T) []eval.PolicySet {
+	t.Helper()
+	details, err := parser.ImportAuthorizationDetails("testdata/authorization-details.json")
+	require.NoError(t, err)
+	sets, err := SetsFor(details, "arn:aws:iam::111122223333:user/bob")
+	require.NoError(t, err)
+	return sets
+}
+
+func TestSetsFor(t *testing.T) {
+	details, err := parser.ImportAuthorizationDetails("testdata/authorization-details.json")
+	require.NoError(t, err)
+
+	sets, err := SetsFor(details, "arn:aws:iam::111122223333:user/bob")
+	require.NoError(t, err)
+	require.Len(t, sets, 2)
+	require.Equal(t, eval.IdentityPolicy, sets[0].Type)
+	require.Len(t, sets[0].Policies, 4) // two inline statements, two of the group's managed policy
+	require.Equal(t, eval.PermissionsBoundary, sets[1].Type)
+	require.Len(t, sets[1].Policies, 2)
+
+	sets, err = SetsFor(details, "arn:aws:iam::111122223333:user/carol")
+	require.NoError(t, err)
+	require.Equal(t, []eval.PolicySet{{Type: eval.IdentityPolicy}}, sets)
+
+	_, err = SetsFor(details, "arn:aws:iam::111122223333:user/mallory")
+	require.ErrorContains(t, err, "not in the authorization details")
+}
+
+func TestAnalyze(t *testing.T) {
+	scp := eval.PolicySet{Type: eval.ServiceControlPolicy, Name: "scp.json", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": "*", "Resource": "*"},
+		{"Effect": "Deny", "Action": "s3:PutObject", "Resource": "*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}]}`)}
+	report := Analyze(append(bobSets(t), scp))
+
+	// iam:PassRole is outside the boundary.
+	require.Len(t, report.Services, 2)
+	require.Equal(t, "ec2", report.Services[0].Service)
+	require.Equal(t, []Action{{
+		Action: "ec2:DescribeInstances",
+		Level:  catalog.List,
+		Grants: []Grant{{
+			Resources:  []string{"*"},
+			Conditions: []string{"permissions-boundary allows StringEquals aws:RequestedRegion [eu-west-1]"},
+			Source:     "arn:aws:iam::111122223333:policy/DeveloperAccess",
+			Statement:  "Describe",
+		}},
+	}}, report.Services[0].Actions)
+
+	s3 := report.Services[1]
+	require.Equal(t, "s3", s3.Service)
+	require.Len(t, s3.Actions, 3)
+	objects := Grant{
+		Resources: []string{"arn:aws:s3:::artifacts/*"},
+		Source:    "arn:aws:iam::111122223333:user/bob:artifacts",
+		Statement: "Objects",
+	}
+	deleteObject := objects
+	deleteObject.Except = []string{"arn:aws:s3:::artifacts/releases/*"}
+	putObject := objects
+	putObject.Conditions = []string{"not (Bool aws:SecureTransport [false])"}
+	require.Equal(t, []Action{
+		{Action: "s3:DeleteObject", Level: catalog.Write, Grants: []Grant{deleteObject}},
+		{Action: "s3:GetObject", Level: catalog.Read, Grants: []Grant{objects}},
+		{Action: "s3:PutObject", Level: catalog.Write, Grants: []Grant{putObject}},
+	}, s3.Actions)
+
+	lines := strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+	require.Regexp(t, `^SERVICE +ACTION +ACCESS LEVEL +RESOURCES +CONDITIONS +SOURCE$`, lines[0])
+	require.Regexp(t, `^s3 +s3:DeleteObject +Write +arn:aws:s3:::artifacts/\* \(denied: arn:aws:s3:::artifacts/releases/\*\) +- +arn:aws:iam::111122223333:user/bob:artifacts$`, lines[2])
+}
+
+func TestAnalyzeDenies(t *testing.T) {
+	identity := eval.PolicySet{Type: eval.IdentityPolicy, Name: "identity", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": ["sqs:SendMessage", "sqs:DeleteQueue", "sqs:PurgeQueue"], "Resource": "arn:aws:sqs:us-east-1:111122223333:jobs"},
+		{"Effect": "Allow", "Action": "kms:Decrypt", "Resource": "*", "Condition": {"StringEquals": {"kms:ViaService": "sqs.us-east-1.amazonaws.com"}}},
+		{"Effect": "Allow", "Action": "notaservice:Do", "Resource": "*"},
+		{"Effect": "Deny", "Action": "sqs:Delete*", "Resource": "*"},
+		{"Effect": "Deny", "Action": "sqs:PurgeQueue", "Resource": "arn:aws:sqs:*:*:jobs", "Condition": {"Bool": {"aws:MultiFactorAuthPresent": "false"}}},
+		{"Effect": "Deny", "Action": "kms:*", "NotResource": "arn:aws:kms:us-east-1:111122223333:key/*"}]}`)}
+	report := Analyze([]eval.PolicySet{identity})
+
+	require.Equal(t, []string{"notaservice:Do"}, report.Unknown)
+	require.Len(t, report.Services, 2)
+	require.Equal(t, []Grant{{
+		Resources:  []string{"*"},
+		Conditions: []string{"StringEquals kms:ViaService [sqs.us-east-1.amazonaws.com]", "resource in [arn:aws:kms:us-east-1:111122223333:key/*]"},
+		Source:     "identity",
+		Statement:  ":1",
+	}}, report.Services[0].Actions[0].Grants)
+
+	var actions []string
+	for _, a := range report.Services[1].Actions {
+		actions = append(actions, a.Action)
+	}
+	require.Equal(t, []string{"sqs:PurgeQueue", "sqs:SendMessage"}, actions)
+	require.Equal(t, []string{"not (Bool aws:MultiFactorAuthPresent [false])"}, report.Services[1].Actions[0].Grants[0].Conditions)
+}
+
+func TestAnalyzeScps(t *testing.T) {
+	identity := eval.PolicySet{Type: eval.IdentityPolicy, Name: "identity", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": ["s3:GetObject", "ec2:DescribeInstances", "sqs:SendMessage"], "Resource": "*"}]}`)}
+	storage := eval.PolicySet{Type: eval.ServiceControlPolicy, Name: "storage.json", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": ["s3:*", "sqs:*"], "Resource": "*"}]}`)}
+	regions := eval.PolicySet{Type: eval.ServiceControlPolicy, Name: "regions.json", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": ["s3:*", "ec2:*"], "Resource": "*"},
+		{"Effect": "Allow", "Action": "sqs:*", "Resource": "*", "Condition": {"StringEquals": {"aws:RequestedRegion": "eu-west-1"}}}]}`)}
+
+	// Each SCP applies to the account, so each must allow an action.
+	report := Analyze([]eval.PolicySet{identity, storage, regions})
+	require.Len(t, report.Services, 2)
+	require.Equal(t, "s3:GetObject", report.Services[0].Actions[0].Action)
+	require.Empty(t, report.Services[0].Actions[0].Grants[0].Conditions)
+	require.Equal(t, "sqs:SendMessage", report.Services[1].Actions[0].Action)
+	require.Equal(t, []string{"scp regions.json allows StringEquals aws:RequestedRegion [eu-west-1]"}, report.Services[1].Actions[0].Grants[0].Conditions)
+}
+
+func TestAnalyzeOtherServices(t *testing.T) {
+	identity := eval.PolicySet{Type: eval.IdentityPolicy, Name: "identity", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Admin", "Effect": "Allow", "NotAction": "iam:*", "Resource": "*"},
+		{"Sid": "Read", "Effect": "Allow", "Action": "*:Get*", "Resource": "*"},
+		{"Sid": "Objects", "Effect": "Allow", "Action": "s3:*", "Resource": "*"},
+		{"Effect": "Deny", "Action": "*:Delete*", "Resource": "*"}]}`)}
+	scp := eval.PolicySet{Type: eval.ServiceControlPolicy, Name: "scp.json", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": "*", "Resource": "*"}]}`)}
+
+	report := Analyze([]eval.PolicySet{identity, scp})
+	other := report.Services[len(report.Services)-1]
+	require.Equal(t, catalog.OtherServices, other.Service)
+	require.Equal(t, []Action{{Action: "*", Grants: []Grant{
+		{Resources: []string{"*"}, Conditions: []string{"action not in [iam:*]", "not (action in [*:Delete*])"}, Source: "identity", Statement: "Admin"},
+		{Resources: []string{"*"}, Conditions: []string{"action in [*:Get*]", "not (action in [*:Delete*])"}, Source: "identity", Statement: "Read"},
+	}}}, other.Actions)
+	require.Regexp(t, `(?m)^\* +\* +Unknown +\* +action not in \[iam:\*\]; not \(action in \[\*:Delete\*\]\) +identity$`, report.String())
+
+	// An SCP that only allows listed services takes the unknown access away.
+	scp.Policies = testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`)
+	report = Analyze([]eval.PolicySet{identity, scp})
+	require.Len(t, report.Services, 1)
+	require.Equal(t, "s3", report.Services[0].Service)
+}
@@ -0,0 +1,210 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// conditionMatch is the outcome of one key of a condition operator.
+type conditionMatch struct {
+	operator string
+	key      string
+	values   []string // values from the policy
+	context  []string // values from the request context
+	present  bool     // key is present in the request context
+	result   bool
+}
+
+// operator is a condition operator split into its parts, for example
+// ForAnyValue:StringLikeIfExists is {set: ForAnyValue, base: StringLike, ifExists: true}.
+type operator struct {
+	set      string
+	base     string
+	ifExists bool
+}
+
+func parseOperator(op string) operator {
+	o := operator{base: op}
+	if i := strings.Index(op, ":"); i >= 0 {
+		o.set = op[:i]
+		o.base = op[i+1:]
+	}
+	if o.base != "Null" && strings.HasSuffix(o.base, "IfExists") {
+		o.ifExists = true
+		o.base = strings.TrimSuffix(o.base, "IfExists")
+	}
+	return o
+}
+
+// negated reports whether the operator matches when the context value matches
+// none of the policy values.
+func (o operator) negated() bool {
+	switch o.base {
+	case "StringNotEquals", "StringNotEqualsIgnoreCase", "StringNotLike",
+		"NumericNotEquals", "DateNotEquals", "NotIpAddress",
+		"ArnNotEquals", "ArnNotLike":
+		return true
+	}
+	return false
+}
+
+// evaluateCondition evaluates every key of c. The keys of one operator are
+// ANDed together, the values of one key are ORed.
+func evaluateCondition(c policy.Condition, ctx Context) []conditionMatch {
+	op := parseOperator(c.Operation)
+	var out []conditionMatch
+	for i, key := range c.Key {
+		var raw any
+		if i < len(c.Value) {
+			raw = c.Value[i]
+		}
+		m := conditionMatch{
+			operator: c.Operation,
+			key:      key,
+			values:   policy.ValueStrings(raw),
+			context:  ctx.Get(key),
+			present:  ctx.Has(key),
+		}
+		m.result = op.evaluate(m.values, m.context, m.present, ctx)
+		out = append(out, m)
+	}
+	return out
+}
+
+func (o operator) evaluate(values, context []string, present bool, ctx Context) bool {
+	if o.base == "Null" {
+		for _, v := range values {
+			if strings.EqualFold(v, "true") != present {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !present || len(context) == 0 {
+		switch {
+		case o.ifExists:
+			return true
+		case o.set == "ForAllValues":
+			return true
+		case o.set == "ForAnyValue":
+			return false
+		}
+		return o.negated()
+	}
+
+	matchOne := func(c string) bool {
+		for _, v := range values {
+			if o.compare(v, c, ctx) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if o.set == "ForAllValues" {
+		for _, c := range context {
+			if matchOne(c) == o.negated() {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Single valued keys, and ForAnyValue, need one context value to satisfy the
+	// operator. Negated operators are satisfied by a value that matches nothing.
+	for _, c := range context {
+		if matchOne(c) != o.negated() {
+			return true
+		}
+	}
+	return false
+}
+
+// compare reports whether the context value c matches the policy value v for
+// the positive form of the operator. Values that do not parse for the
+// operator's family never match.
+func (o operator) compare(v, c string, ctx Context) bool {
+	switch o.base {
+	case "StringEquals", "StringNotEquals":
+		resolved, ok := resolveVariables(v, ctx)
+		return ok && unmark(resolved) == c
+	case "StringEqualsIgnoreCase", "StringNotEqualsIgnoreCase":
+		resolved, ok := resolveVariables(v, ctx)
+		return ok && strings.EqualFold(unmark(resolved), c)
+	case "StringLike", "StringNotLike":
+		return Match(v, c, false, ctx)
+	case "ArnEquals", "ArnLike", "ArnNotEquals", "ArnNotLike":
+		return matchArn(v, c, ctx)
+	}
+
+	family := policy.OperatorFamily(o.base)
+	if resolved, ok := resolveVariables(v, ctx); ok {
+		v = unmark(resolved)
+	}
+	if strings.Contains(v, "${") || strings.Contains(c, "${") {
+		// Unresolved variables are not validated by ParseTypedValue.
+		return false
+	}
+	want, err := policy.ParseTypedValue(family, v)
+	if err != nil {
+		return false
+	}
+	got, err := policy.ParseTypedValue(family, c)
+	if err != nil {
+		return false
+	}
+
+	switch o.base {
+	case "NumericEquals", "NumericNotEquals":
+		return got.Number.Cmp(want.Number) == 0
+	case "NumericLessThan":
+		return got.Number.Cmp(want.Number) < 0
+	case "NumericLessThanEquals":
+		return got.Number.Cmp(want.Number) <= 0
+	case "NumericGreaterThan":
+		return got.Number.Cmp(want.Number) > 0
+	case "NumericGreaterThanEquals":
+		return got.Number.Cmp(want.Number) >= 0
+	case "DateEquals", "DateNotEquals":
+		return got.Time.Equal(want.Time)
+	case "DateLessThan":
+		return got.Time.Before(want.Time)
+	case "DateLessThanEquals":
+		return !got.Time.After(want.Time)
+	case "DateGreaterThan":
+		return got.Time.After(want.Time)
+	case "DateGreaterThanEquals":
+		return !got.Time.Before(want.Time)
+	case "Bool":
+		return got.Bool == want.Bool
+	case "BinaryEquals":
+		return bytes.Equal(got.Binary, want.Binary)
+	case "IpAddress", "NotIpAddress":
+		return got.Prefix.IsSingleIP() && want.Prefix.Contains(got.Prefix.Addr())
+	}
+	return false
+}
+
+// unmark drops the literal markers that resolveVariables puts around values.
+func unmark(s string) string {
+	return strings.ReplaceAll(s, literalMarker, "")
+}
+
+// matchArn matches the six colon separated ARN segments one by one, so that a
+// wildcard cannot span a segment boundary.
+func matchArn(pattern, value string, ctx Context) bool {
+	patternParts := strings.SplitN(pattern, ":", 6)
+	valueParts := strings.SplitN(value, ":", 6)
+	if len(patternParts) != 6 || len(valueParts) != 6 {
+		return Match(pattern, value, false, ctx)
+	}
+	for i := range patternParts {
+		if !Match(patternParts[i], valueParts[i], false, ctx) {
+			return false
+		}
+	}
+	return true
+}
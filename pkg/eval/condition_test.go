@@ -0,0 +1,95 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateCondition(t *testing.T) {
+	cond := func(op, key string, values any) policy.Condition {
+		return policy.Condition{Operation: op, Key: []string{key}, Value: []any{values}}
+	}
+
+	tests := []struct {
+		name     string
+		cond     policy.Condition
+		ctx      Context
+		expected bool
+	}{
+		{name: "StringEquals", cond: cond("StringEquals", "aws:username", []string{"alice", "bob"}), ctx: Context{"aws:username": {"bob"}}, expected: true},
+		{name: "StringEquals Mismatch", cond: cond("StringEquals", "aws:username", []string{"alice"}), ctx: Context{"aws:username": {"bob"}}, expected: false},
+		{name: "StringEquals Missing Key", cond: cond("StringEquals", "aws:username", []string{"alice"}), expected: false},
+		{name: "StringEquals Variable", cond: cond("StringEquals", "s3:prefix", []string{"${aws:username}"}), ctx: Context{"s3:prefix": {"bob"}, "aws:username": {"bob"}}, expected: true},
+		{name: "StringNotEquals Missing Key", cond: cond("StringNotEquals", "aws:username", []string{"alice"}), expected: true},
+		{name: "StringNotEquals", cond: cond("StringNotEquals", "aws:username", []string{"alice"}), ctx: Context{"aws:username": {"alice"}}, expected: false},
+		{name: "StringEqualsIgnoreCase", cond: cond("StringEqualsIgnoreCase", "aws:username", []string{"ALICE"}), ctx: Context{"aws:username": {"alice"}}, expected: true},
+		{name: "StringLike", cond: cond("StringLike", "s3:prefix", []string{"home/*"}), ctx: Context{"s3:prefix": {"home/alice"}}, expected: true},
+		{name: "StringNotLike", cond: cond("StringNotLike", "s3:prefix", []string{"home/*"}), ctx: Context{"s3:prefix": {"tmp/x"}}, expected: true},
+		{name: "StringEqualsIfExists Missing", cond: cond("StringEqualsIfExists", "ec2:InstanceType", []string{"t3.micro"}), expected: true},
+		{name: "StringEqualsIfExists Present", cond: cond("StringEqualsIfExists", "ec2:InstanceType", []string{"t3.micro"}), ctx: Context{"ec2:InstanceType": {"m5.large"}}, expected: false},
+		{name: "NumericLessThan", cond: cond("NumericLessThan", "s3:max-keys", []int64{10}), ctx: Context{"s3:max-keys": {"5"}}, expected: true},
+		{name: "NumericLessThan String Value", cond: cond("NumericLessThan", "s3:max-keys", []string{"10"}), ctx: Context{"s3:max-keys": {"15"}}, expected: false},
+		{name: "NumericGreaterThanEquals", cond: cond("NumericGreaterThanEquals", "aws:MultiFactorAuthAge", []int64{3600}), ctx: Context{"aws:MultiFactorAuthAge": {"3600"}}, expected: true},
+		{name: "NumericNotEquals", cond: cond("NumericNotEquals", "n", []int64{1}), ctx: Context{"n": {"2"}}, expected: true},
+		{name: "Numeric Invalid", cond: cond("NumericEquals", "n", []string{"abc"}), ctx: Context{"n": {"1"}}, expected: false},
+		{name: "DateGreaterThan", cond: cond("DateGreaterThan", "aws:CurrentTime", []string{"2024-01-01T00:00:00Z"}), ctx: Context{"aws:CurrentTime": {"2024-06-01T12:00:00Z"}}, expected: true},
+		{name: "DateLessThan Epoch", cond: cond("DateLessThan", "aws:EpochTime", []int64{1700000000}), ctx: Context{"aws:EpochTime": {"2020-01-01T00:00:00Z"}}, expected: true},
+		{name: "DateLessThanEquals Day", cond: cond("DateLessThanEquals", "aws:CurrentTime", []string{"2024-01-01"}), ctx: Context{"aws:CurrentTime": {"2024-01-01T00:00:00Z"}}, expected: true},
+		{name: "Bool", cond: cond("Bool", "aws:SecureTransport", []bool{false}), ctx: Context{"aws:SecureTransport": {"false"}}, expected: true},
+		{name: "Bool Missing", cond: cond("Bool", "aws:SecureTransport", []bool{false}), expected: false},
+		{name: "BoolIfExists Missing", cond: cond("BoolIfExists", "aws:MultiFactorAuthPresent", []bool{false}), expected: true},
+		{name: "IpAddress", cond: cond("IpAddress", "aws:SourceIp", []string{"192.0.2.0/24"}), ctx: Context{"aws:SourceIp": {"192.0.2.10"}}, expected: true},
+		{name: "IpAddress Single", cond: cond("IpAddress", "aws:SourceIp", []string{"203.0.113.5"}), ctx: Context{"aws:SourceIp": {"203.0.113.5"}}, expected: true},
+		{name: "NotIpAddress", cond: cond("NotIpAddress", "aws:SourceIp", []string{"192.0.2.0/24"}), ctx: Context{"aws:SourceIp": {"198.51.100.1"}}, expected: true},
+		{name: "IpAddress IPv6", cond: cond("IpAddress", "aws:SourceIp", []string{"2001:db8::/32"}), ctx: Context{"aws:SourceIp": {"2001:db8::1"}}, expected: true},
+		{name: "ArnLike", cond: cond("ArnLike", "aws:SourceArn", []string{"arn:aws:sns:*:123456789012:*"}), ctx: Context{"aws:SourceArn": {"arn:aws:sns:us-east-1:123456789012:topic"}}, expected: true},
+		{name: "ArnLike Segment Boundary", cond: cond("ArnLike", "aws:SourceArn", []string{"arn:aws:sns:*:123456789012"}), ctx: Context{"aws:SourceArn": {"arn:aws:sns:us-east-1:123456789012:topic"}}, expected: false},
+		{name: "ArnNotEquals", cond: cond("ArnNotEquals", "aws:SourceArn", []string{"arn:aws:sns:us-east-1:123456789012:topic"}), ctx: Context{"aws:SourceArn": {"arn:aws:sns:us-east-1:123456789012:other"}}, expected: true},
+		{name: "BinaryEquals", cond: cond("BinaryEquals", "key", []string{"QmluYXJ5"}), ctx: Context{"key": {"QmluYXJ5"}}, expected: true},
+		{name: "Null True Absent", cond: cond("Null", "aws:TokenIssueTime", []bool{true}), expected: true},
+		{name: "Null True Present", cond: cond("Null", "aws:TokenIssueTime", []bool{true}), ctx: Context{"aws:TokenIssueTime": {"x"}}, expected: false},
+		{name: "Null False Present", cond: cond("Null", "aws:TokenIssueTime", []bool{false}), ctx: Context{"aws:TokenIssueTime": {"x"}}, expected: true},
+		{name: "ForAnyValue", cond: cond("ForAnyValue:StringEquals", "aws:TagKeys", []string{"env"}), ctx: Context{"aws:TagKeys": {"owner", "env"}}, expected: true},
+		{name: "ForAnyValue Missing", cond: cond("ForAnyValue:StringEquals", "aws:TagKeys", []string{"env"}), expected: false},
+		{name: "ForAllValues", cond: cond("ForAllValues:StringEquals", "aws:TagKeys", []string{"env", "owner"}), ctx: Context{"aws:TagKeys": {"owner", "env"}}, expected: true},
+		{name: "ForAllValues Extra Value", cond: cond("ForAllValues:StringEquals", "aws:TagKeys", []string{"env"}), ctx: Context{"aws:TagKeys": {"owner", "env"}}, expected: false},
+		{name: "ForAllValues Missing", cond: cond("ForAllValues:StringEquals", "aws:TagKeys", []string{"env"}), expected: true},
+		{name: "ForAllValues Negated", cond: cond("ForAllValues:StringNotLike", "aws:TagKeys", []string{"aws:*"}), ctx: Context{"aws:TagKeys": {"env", "aws:x"}}, expected: false},
+		{name: "Unknown Operator", cond: cond("Bogus", "k", []string{"v"}), ctx: Context{"k": {"v"}}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := evaluateCondition(tt.cond, tt.ctx)
+			require.Len(t, matches, 1)
+			require.Equal(t, tt.expected, matches[0].result)
+		})
+	}
+}
+
+func TestEvaluateConditionKeysAreAnded(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Statement": [{
+			"Effect": "Allow",
+			"Action": "s3:GetObject",
+			"Resource": "*",
+			"Condition": {
+				"StringEquals": {"aws:PrincipalTag/team": "data", "aws:RequestedRegion": "eu-west-1"},
+				"Bool": {"aws:SecureTransport": true}
+			}
+		}]
+	}`)
+
+	req := Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::b/k", Context: Context{
+		"aws:PrincipalTag/team": {"data"},
+		"aws:RequestedRegion":   {"eu-west-1"},
+		"aws:SecureTransport":   {"true"},
+	}}
+	require.Equal(t, Allow, Evaluate(policies, req).Decision)
+
+	req.Context["aws:RequestedRegion"] = []string{"us-east-1"}
+	require.Equal(t, ImplicitDeny, Evaluate(policies, req).Decision)
+}
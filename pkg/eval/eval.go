@@ -0,0 +1,252 @@
+package eval
+
+import (
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+type Decision string
+
+const (
+	Allow        Decision = "Allow"        // an Allow statement matched and no Deny did
+	ExplicitDeny Decision = "ExplicitDeny" // a Deny statement matched
+	ImplicitDeny Decision = "ImplicitDeny" // no statement matched
+)
+
+// Context holds the request context keys and their values. Keys are matched
+// case-insensitively, the way AWS treats condition keys.
+type Context map[string][]string
+
+// Get returns the values of key, ignoring the case of the key.
+func (c Context) Get(key string) []string {
+	if v, ok := c[key]; ok {
+		return v
+	}
+	for k, v := range c {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Has reports whether key is present in the context.
+func (c Context) Has(key string) bool {
+	if _, ok := c[key]; ok {
+		return true
+	}
+	for k := range c {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Request is the question asked of a set of policies: may Principal perform
+// Action on Resource given Context.
+type Request struct {
+	Principal string  `json:"principal" yaml:"principal"` // ARN, account id or service principal making the request
+	Action    string  `json:"action" yaml:"action"`       // such as s3:GetObject
+	Resource  string  `json:"resource" yaml:"resource"`   // ARN of the resource
+	Context   Context `json:"context" yaml:"context"`     // condition keys and their values
+}
+
+type Result struct {
+	Decision Decision         `json:"decision" yaml:"decision"`
+	Matched  []*policy.Policy `json:"matched" yaml:"matched"` // statements that apply to the request
+}
+
+// Denied returns the matched Deny statements.
+func (r Result) Denied() []*policy.Policy {
+	var out []*policy.Policy
+	for _, p := range r.Matched {
+		if !p.Allowed {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Allowed returns the matched Allow statements.
+func (r Result) Allowed() []*policy.Policy {
+	var out []*policy.Policy
+	for _, p := range r.Matched {
+		if p.Allowed {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Evaluate decides req against policies. An explicit Deny always wins over an
+// Allow; a request that no statement matches is implicitly denied.
+func Evaluate(policies []*policy.Policy, req Request) Result {
+	res := Result{Decision: ImplicitDeny}
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		if !evaluateStatement(p, req).applies() {
+			continue
+		}
+		res.Matched = append(res.Matched, p)
+		switch {
+		case !p.Allowed:
+			res.Decision = ExplicitDeny
+		case res.Decision != ExplicitDeny:
+			res.Decision = Allow
+		}
+	}
+	return res
+}
+
+// statementMatch records how each element of a statement matched a request.
+type statementMatch struct {
+	subjects   bool
+	actions    bool
+	resources  bool
+	conditions []conditionMatch
+}
+
+func (m statementMatch) applies() bool {
+	if !m.subjects || !m.actions || !m.resources {
+		return false
+	}
+	for _, c := range m.conditions {
+		if !c.result {
+			return false
+		}
+	}
+	return true
+}
+
+func evaluateStatement(p *policy.Policy, req Request) statementMatch {
+	m := statementMatch{
+		subjects:  matchSubjects(p, req),
+		actions:   matchElement(p.Actions, p.NotActions, req.Action, true, false, req.Context),
+		resources: matchElement(p.Resources, p.NotResources, req.Resource, false, true, req.Context),
+	}
+	for _, c := range p.Condition {
+		m.conditions = append(m.conditions, evaluateCondition(c, req.Context)...)
+	}
+	return m
+}
+
+// matchElement matches value against an element and its Not* counterpart. When
+// neither is set the element matches only if emptyMatches; statements without
+// a Resource, such as trust policies, apply to the resource they are attached to.
+func matchElement(include, exclude []string, value string, ignoreCase, emptyMatches bool, ctx Context) bool {
+	switch {
+	case len(include) > 0:
+		return matchAny(include, value, ignoreCase, ctx)
+	case len(exclude) > 0:
+		return !matchAny(exclude, value, ignoreCase, ctx)
+	}
+	return emptyMatches
+}
+
+func matchAny(patterns []string, value string, ignoreCase bool, ctx Context) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, value, ignoreCase, ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSubjects(p *policy.Policy, req Request) bool {
+	switch {
+	case len(p.Subjects) > 0:
+		return matchAnyPrincipal(p.Subjects, req.Principal, req.Context)
+	case len(p.NotSubjects) > 0:
+		return !matchAnyPrincipal(p.NotSubjects, req.Principal, req.Context)
+	}
+	// Identity policies have no principal, they apply to whoever they are attached to.
+	return true
+}
+
+func matchAnyPrincipal(subjects []string, principal string, ctx Context) bool {
+	for _, s := range subjects {
+		if MatchPrincipal(s, principal, ctx) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPrincipal reports whether principal is covered by the Principal element
+// entry subject. An account id, or the account's root ARN, covers every
+// principal of that account, and a role ARN covers the sessions of that role.
+func MatchPrincipal(subject, principal string, ctx Context) bool {
+	if subject == "<.*>" || subject == "*" {
+		return true
+	}
+	if principal == "" {
+		return false
+	}
+	if Match(subject, principal, false, ctx) {
+		return true
+	}
+
+	if account, ok := accountOf(subject); ok {
+		if principalAccount, ok := principalAccountOf(principal); ok && account == principalAccount {
+			return true
+		}
+	}
+	if role, ok := roleOfSession(principal); ok {
+		return Match(subject, role, false, ctx)
+	}
+	return false
+}
+
+// accountOf returns the account of a subject that stands for a whole account:
+// a bare account id or an arn:aws:iam::<account>:root ARN.
+func accountOf(subject string) (string, bool) {
+	if isAccountId(subject) {
+		return subject, true
+	}
+	parts := strings.SplitN(subject, ":", 6)
+	if len(parts) == 6 && parts[0] == "arn" && parts[2] == "iam" && parts[5] == "root" {
+		return parts[4], true
+	}
+	return "", false
+}
+
+func principalAccountOf(principal string) (string, bool) {
+	if isAccountId(principal) {
+		return principal, true
+	}
+	parts := strings.SplitN(principal, ":", 6)
+	if len(parts) == 6 && parts[0] == "arn" && parts[4] != "" {
+		return parts[4], true
+	}
+	return "", false
+}
+
+// roleOfSession maps arn:aws:sts::<account>:assumed-role/<role>/<session> to the
+// ARN of the role.
+func roleOfSession(principal string) (string, bool) {
+	parts := strings.SplitN(principal, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sts" {
+		return "", false
+	}
+	resource := strings.Split(parts[5], "/")
+	if len(resource) < 3 || resource[0] != "assumed-role" {
+		return "", false
+	}
+	return "arn:" + parts[1] + ":iam::" + parts[4] + ":role/" + resource[1], true
+}
+
+func isAccountId(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
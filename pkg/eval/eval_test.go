@@ -0,0 +1,195 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	identity := testutil.Parse(t, `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "ReadBucket",
+				"Effect": "Allow",
+				"Action": ["s3:Get*", "s3:List*"],
+				"Resource": ["arn:aws:s3:::reports", "arn:aws:s3:::reports/*"]
+			},
+			{
+				"Sid": "DenySecrets",
+				"Effect": "Deny",
+				"Action": "s3:*",
+				"Resource": "arn:aws:s3:::reports/secret/*"
+			},
+			{
+				"Sid": "EverythingButIam",
+				"Effect": "Allow",
+				"NotAction": "iam:*",
+				"NotResource": "arn:aws:s3:::reports*"
+			},
+			{
+				"Sid": "HomeFolder",
+				"Effect": "Allow",
+				"Action": "s3:PutObject",
+				"Resource": "arn:aws:s3:::home/${aws:username}/*"
+			}
+		]
+	}`)
+
+	tests := []struct {
+		name     string
+		req      Request
+		decision Decision
+		matched  []string
+	}{
+		{
+			name:     "Wildcard Action Allowed",
+			req:      Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::reports/q1.csv"},
+			decision: Allow,
+			matched:  []string{"ReadBucket"},
+		},
+		{
+			name:     "Actions Are Case Insensitive",
+			req:      Request{Action: "S3:getobject", Resource: "arn:aws:s3:::reports/q1.csv"},
+			decision: Allow,
+			matched:  []string{"ReadBucket"},
+		},
+		{
+			name:     "Resources Are Case Sensitive",
+			req:      Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::Reports/q1.csv"},
+			decision: Allow,
+			matched:  []string{"EverythingButIam"},
+		},
+		{
+			name:     "Explicit Deny Wins",
+			req:      Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::reports/secret/key"},
+			decision: ExplicitDeny,
+			matched:  []string{"ReadBucket", "DenySecrets"},
+		},
+		{
+			name:     "NotAction Excludes",
+			req:      Request{Action: "iam:CreateUser", Resource: "arn:aws:iam::123456789012:user/bob"},
+			decision: ImplicitDeny,
+		},
+		{
+			name:     "NotAction And NotResource Allow",
+			req:      Request{Action: "ec2:RunInstances", Resource: "arn:aws:ec2:us-east-1:123456789012:instance/i-1"},
+			decision: Allow,
+			matched:  []string{"EverythingButIam"},
+		},
+		{
+			name:     "Policy Variable Resolved",
+			req:      Request{Action: "s3:PutObject", Resource: "arn:aws:s3:::home/alice/notes.txt", Context: Context{"aws:username": {"alice"}}},
+			decision: Allow,
+			matched:  []string{"EverythingButIam", "HomeFolder"},
+		},
+		{
+			name:     "Policy Variable Mismatch",
+			req:      Request{Action: "s3:PutObject", Resource: "arn:aws:s3:::reports/x", Context: Context{"aws:username": {"alice"}}},
+			decision: ImplicitDeny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Evaluate(identity, tt.req)
+			require.Equal(t, tt.decision, res.Decision)
+			var ids []string
+			for _, p := range res.Matched {
+				ids = append(ids, p.Id)
+			}
+			require.Equal(t, tt.matched, ids)
+		})
+	}
+}
+
+func TestEvaluateResourcePolicy(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Sid": "Account",
+				"Effect": "Allow",
+				"Principal": {"AWS": "111122223333"},
+				"Action": "sqs:SendMessage",
+				"Resource": "arn:aws:sqs:us-east-1:444455556666:queue"
+			},
+			{
+				"Sid": "Role",
+				"Effect": "Allow",
+				"Principal": {"AWS": "arn:aws:iam::777788889999:role/writer"},
+				"Action": "sqs:*"
+			},
+			{
+				"Sid": "NotPrincipal",
+				"Effect": "Deny",
+				"NotPrincipal": {"AWS": ["arn:aws:iam::111122223333:root", "arn:aws:iam::777788889999:role/writer"]},
+				"Action": "sqs:*"
+			}
+		]
+	}`)
+
+	tests := []struct {
+		name      string
+		principal string
+		decision  Decision
+	}{
+		{name: "Account Root Covers Users", principal: "arn:aws:iam::111122223333:user/alice", decision: Allow},
+		{name: "Role Covers Sessions", principal: "arn:aws:sts::777788889999:assumed-role/writer/session", decision: Allow},
+		{name: "Other Account Denied By NotPrincipal", principal: "arn:aws:iam::999999999999:user/mallory", decision: ExplicitDeny},
+		{name: "Anonymous Denied", principal: "", decision: ExplicitDeny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Evaluate(policies, Request{
+				Principal: tt.principal,
+				Action:    "sqs:SendMessage",
+				Resource:  "arn:aws:sqs:us-east-1:444455556666:queue",
+			})
+			require.Equal(t, tt.decision, res.Decision)
+		})
+	}
+}
+
+func TestResultHelpers(t *testing.T) {
+	allow := &policy.Policy{Id: "a", Allowed: true}
+	deny := &policy.Policy{Id: "d"}
+	res := Result{Decision: ExplicitDeny, Matched: []*policy.Policy{allow, deny}}
+	require.Equal(t, []*policy.Policy{allow}, res.Allowed())
+	require.Equal(t, []*policy.Policy{deny}, res.Denied())
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		value      string
+		ignoreCase bool
+		ctx        Context
+		expected   bool
+	}{
+		{pattern: "<.*>", value: "anything", expected: true},
+		{pattern: "s3:Get<.*>", value: "s3:GetObject", expected: true},
+		{pattern: "s3:Get<.*>", value: "s3:getobject", expected: false},
+		{pattern: "s3:Get<.*>", value: "s3:getobject", ignoreCase: true, expected: true},
+		{pattern: "home/*", value: "home/alice", expected: true},
+		{pattern: "file?.txt", value: "file1.txt", expected: true},
+		{pattern: "file?.txt", value: "file10.txt", expected: false},
+		{pattern: "a.b", value: "axb", expected: false},
+		{pattern: "arn:aws:s3:::${aws:username}", value: "arn:aws:s3:::bob", ctx: Context{"AWS:UserName": {"bob"}}, expected: true},
+		{pattern: "arn:aws:s3:::${aws:username}", value: "arn:aws:s3:::bob", expected: false},
+		{pattern: "arn:aws:s3:::${aws:username, 'guest'}", value: "arn:aws:s3:::guest", expected: true},
+		{pattern: "literal${*}", value: "literal*", expected: true},
+		{pattern: "literal${*}", value: "literalx", expected: false},
+		{pattern: "${aws:username}", value: "a.b", ctx: Context{"aws:username": {"a*b"}}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"~"+tt.value, func(t *testing.T) {
+			require.Equal(t, tt.expected, Match(tt.pattern, tt.value, tt.ignoreCase, tt.ctx))
+		})
+	}
+}
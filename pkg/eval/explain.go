@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Explanation traces how every statement matched a request.
+type Explanation struct {
+	Request     Request          `json:"request" yaml:"request"`
+	Decision    Decision         `json:"decision" yaml:"decision"`
+	Statements  []StatementTrace `json:"statements" yaml:"statements"`
+	WinningDeny *StatementTrace  `json:"winning-deny,omitempty" yaml:"winning-deny,omitempty"` // first Deny statement that applies
+}
+
+type StatementTrace struct {
+	Index      int              `json:"index" yaml:"index"` // position of the statement in the evaluated policies
+	Id         string           `json:"id" yaml:"id"`
+	Effect     string           `json:"effect" yaml:"effect"`
+	Subjects   bool             `json:"subjects-matched" yaml:"subjects-matched"`
+	Actions    bool             `json:"actions-matched" yaml:"actions-matched"`
+	Resources  bool             `json:"resources-matched" yaml:"resources-matched"`
+	Conditions []ConditionTrace `json:"conditions" yaml:"conditions"`
+	Applies    bool             `json:"applies" yaml:"applies"` // every element and condition matched
+}
+
+type ConditionTrace struct {
+	Operator      string   `json:"operator" yaml:"operator"`
+	Key           string   `json:"key" yaml:"key"`
+	Values        []string `json:"values" yaml:"values"`                 // values from the policy
+	ContextValues []string `json:"context-values" yaml:"context-values"` // values from the request context
+	Present       bool     `json:"present" yaml:"present"`               // key is present in the request context
+	Result        bool     `json:"result" yaml:"result"`
+}
+
+// Explain evaluates req like Evaluate and records the outcome of every
+// statement, element and condition along the way.
+func Explain(policies []*policy.Policy, req Request) *Explanation {
+	e := &Explanation{Request: req, Decision: ImplicitDeny}
+	for i, p := range policies {
+		if p == nil {
+			continue
+		}
+		m := evaluateStatement(p, req)
+		trace := StatementTrace{
+			Index:     i,
+			Id:        p.Id,
+			Effect:    effectOf(p),
+			Subjects:  m.subjects,
+			Actions:   m.actions,
+			Resources: m.resources,
+			Applies:   m.applies(),
+		}
+		for _, c := range m.conditions {
+			trace.Conditions = append(trace.Conditions, ConditionTrace{
+				Operator:      c.operator,
+				Key:           c.key,
+				Values:        c.values,
+				ContextValues: c.context,
+				Present:       c.present,
+				Result:        c.result,
+			})
+		}
+		e.Statements = append(e.Statements, trace)
+
+		if !trace.Applies {
+			continue
+		}
+		switch {
+		case !p.Allowed:
+			if e.WinningDeny == nil {
+				t := trace
+				e.WinningDeny = &t
+			}
+			e.Decision = ExplicitDeny
+		case e.Decision != ExplicitDeny:
+			e.Decision = Allow
+		}
+	}
+	return e
+}
+
+func (e *Explanation) Json() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// Text renders the explanation for humans, one block per statement.
+func (e *Explanation) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Request: principal=%q action=%q resource=%q\n", e.Request.Principal, e.Request.Action, e.Request.Resource)
+	fmt.Fprintf(&b, "Decision: %s\n", e.Decision)
+	if e.WinningDeny != nil {
+		fmt.Fprintf(&b, "Denied by statement #%d %s\n", e.WinningDeny.Index, e.WinningDeny.Id)
+	}
+	for _, s := range e.Statements {
+		applies := "does not apply"
+		if s.Applies {
+			applies = "applies"
+		}
+		fmt.Fprintf(&b, "\n#%d %s (%s): %s\n", s.Index, s.Id, s.Effect, applies)
+		fmt.Fprintf(&b, "  subjects:  %s\n", matched(s.Subjects))
+		fmt.Fprintf(&b, "  actions:   %s\n", matched(s.Actions))
+		fmt.Fprintf(&b, "  resources: %s\n", matched(s.Resources))
+		for _, c := range s.Conditions {
+			context := "<absent>"
+			if c.Present {
+				context = "[" + strings.Join(c.ContextValues, ", ") + "]"
+			}
+			fmt.Fprintf(&b, "  condition %s %s [%s] with %s: %t\n", c.Operator, c.Key, strings.Join(c.Values, ", "), context, c.Result)
+		}
+	}
+	return b.String()
+}
+
+func matched(ok bool) string {
+	if ok {
+		return "matched"
+	}
+	return "not matched"
+}
+
+func effectOf(p *policy.Policy) string {
+	if p.Allowed {
+		return "Allow"
+	}
+	return "Deny"
+}
@@ -0,0 +1,117 @@
+package eval
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+)
+
+func TestExplain(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Statement": [
+			{
+				"Sid": "ReadWithMfa",
+				"Effect": "Allow",
+				"Action": "s3:GetObject",
+				"Resource": "arn:aws:s3:::reports/*",
+				"Condition": {"Bool": {"aws:MultiFactorAuthPresent": true}}
+			},
+			{
+				"Sid": "ReadFromOffice",
+				"Effect": "Allow",
+				"Action": "s3:GetObject",
+				"Resource": "*",
+				"Condition": {"IpAddress": {"aws:SourceIp": "192.0.2.0/24"}}
+			},
+			{
+				"Sid": "DenyOutsideRegion",
+				"Effect": "Deny",
+				"NotAction": "iam:*",
+				"Resource": "*",
+				"Condition": {"StringNotEquals": {"aws:RequestedRegion": ["eu-west-1"]}}
+			},
+			{
+				"Sid": "DenyWrites",
+				"Effect": "Deny",
+				"Action": "s3:Put*",
+				"Resource": "*"
+			}
+		]
+	}`)
+
+	req := Request{
+		Principal: "arn:aws:iam::111122223333:user/alice",
+		Action:    "s3:GetObject",
+		Resource:  "arn:aws:s3:::reports/q1.csv",
+		Context: Context{
+			"aws:MultiFactorAuthPresent": {"true"},
+			"aws:SourceIp":               {"198.51.100.7"},
+			"aws:RequestedRegion":        {"us-east-1"},
+		},
+	}
+
+	e := Explain(policies, req)
+	require.Equal(t, ExplicitDeny, e.Decision)
+	require.Equal(t, Evaluate(policies, req).Decision, e.Decision)
+	require.Len(t, e.Statements, 4)
+
+	mfa := e.Statements[0]
+	require.True(t, mfa.Applies)
+	require.True(t, mfa.Subjects)
+	require.True(t, mfa.Actions)
+	require.True(t, mfa.Resources)
+	require.Equal(t, []ConditionTrace{{
+		Operator:      "Bool",
+		Key:           "aws:MultiFactorAuthPresent",
+		Values:        []string{"true"},
+		ContextValues: []string{"true"},
+		Present:       true,
+		Result:        true,
+	}}, mfa.Conditions)
+
+	office := e.Statements[1]
+	require.False(t, office.Applies)
+	require.False(t, office.Conditions[0].Result)
+	require.Equal(t, []string{"198.51.100.7"}, office.Conditions[0].ContextValues)
+
+	require.NotNil(t, e.WinningDeny)
+	require.Equal(t, "DenyOutsideRegion", e.WinningDeny.Id)
+	require.Equal(t, 2, e.WinningDeny.Index)
+
+	writes := e.Statements[3]
+	require.False(t, writes.Applies)
+	require.False(t, writes.Actions)
+
+	text := e.Text()
+	require.Contains(t, text, "Decision: ExplicitDeny")
+	require.Contains(t, text, "Denied by statement #2 DenyOutsideRegion")
+	require.Contains(t, text, "condition IpAddress aws:SourceIp [192.0.2.0/24] with [198.51.100.7]: false")
+
+	data, err := e.Json()
+	require.NoError(t, err)
+	var decoded Explanation
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, e.Decision, decoded.Decision)
+	require.Equal(t, e.Statements, decoded.Statements)
+}
+
+func TestExplainAbsentContext(t *testing.T) {
+	policies := testutil.Parse(t, `{
+		"Statement": [{
+			"Sid": "Tagged",
+			"Effect": "Allow",
+			"Action": "ec2:StartInstances",
+			"Resource": "*",
+			"Condition": {"StringEquals": {"aws:ResourceTag/team": "data"}}
+		}]
+	}`)
+
+	e := Explain(policies, Request{Action: "ec2:StartInstances", Resource: "arn:aws:ec2:us-east-1:111122223333:instance/i-1"})
+	require.Equal(t, ImplicitDeny, e.Decision)
+	require.Nil(t, e.WinningDeny)
+	require.False(t, e.Statements[0].Conditions[0].Present)
+	require.Contains(t, e.Text(), "with <absent>: false")
+}
@@ -0,0 +1,135 @@
+package eval
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// patternCache holds compiled wildcard patterns keyed by case sensitivity and
+// pattern text. Policies are evaluated many times with the same patterns.
+var patternCache sync.Map
+
+// Match reports whether value matches pattern. Patterns use the parsed policy
+// form where "<.*>" is a multi character wildcard; raw "*" and "?" from
+// condition values are wildcards as well. Policy variables such as
+// ${aws:username} are resolved from ctx; a pattern referencing a variable that
+// is missing from ctx never matches.
+func Match(pattern, value string, ignoreCase bool, ctx Context) bool {
+	if pattern == "<.*>" || pattern == "*" {
+		return true
+	}
+	resolved, ok := resolveVariables(pattern, ctx)
+	if !ok {
+		return false
+	}
+	re := compile(resolved, ignoreCase)
+	return re.MatchString(value)
+}
+
+func compile(pattern string, ignoreCase bool) *regexp.Regexp {
+	key := pattern
+	if ignoreCase {
+		key = "i:" + pattern
+	} else {
+		key = "s:" + pattern
+	}
+	if re, ok := patternCache.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	var b strings.Builder
+	if ignoreCase {
+		b.WriteString("(?is)^")
+	} else {
+		b.WriteString("(?s)^")
+	}
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "<.*>"):
+			b.WriteString(".*")
+			i += len("<.*>")
+		case pattern[i] == '*':
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '?':
+			b.WriteString(".")
+			i++
+		case strings.HasPrefix(pattern[i:], literalMarker):
+			// Escaped literals produced by resolveVariables.
+			end := strings.Index(pattern[i+len(literalMarker):], literalMarker)
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				continue
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i+len(literalMarker) : i+len(literalMarker)+end]))
+			i += 2*len(literalMarker) + end
+		default:
+			j := i
+			for j < len(pattern) && pattern[j] != '*' && pattern[j] != '?' && pattern[j] != '<' && pattern[j] != literalMarker[0] {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i:j]))
+			i = j
+		}
+	}
+	b.WriteString("$")
+
+	re := regexp.MustCompile(b.String())
+	patternCache.Store(key, re)
+	return re
+}
+
+// literalMarker wraps text that must be matched literally, such as the values of
+// resolved policy variables and the ${*}, ${?} and ${$} escapes.
+const literalMarker = "\x00"
+
+// resolveVariables substitutes ${key} policy variables with their single value
+// from ctx. Variables may carry a default, as in ${aws:username, 'nobody'}.
+func resolveVariables(pattern string, ctx Context) (string, bool) {
+	if !strings.Contains(pattern, "${") {
+		return pattern, true
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(pattern, "${")
+		if start < 0 {
+			b.WriteString(pattern)
+			return b.String(), true
+		}
+		end := strings.Index(pattern[start:], "}")
+		if end < 0 {
+			b.WriteString(pattern)
+			return b.String(), true
+		}
+		b.WriteString(pattern[:start])
+		name := pattern[start+2 : start+end]
+		pattern = pattern[start+end+1:]
+
+		switch name {
+		case "*", "?", "$":
+			b.WriteString(literalMarker + name + literalMarker)
+			continue
+		}
+
+		fallback, hasFallback := "", false
+		if i := strings.Index(name, ","); i >= 0 {
+			fallback = strings.Trim(strings.TrimSpace(name[i+1:]), "'")
+			hasFallback = true
+			name = strings.TrimSpace(name[:i])
+		}
+		values := ctx.Get(name)
+		switch {
+		case len(values) == 1:
+			b.WriteString(literalMarker + values[0] + literalMarker)
+		case hasFallback:
+			b.WriteString(literalMarker + fallback + literalMarker)
+		default:
+			return "", false
+		}
+	}
+}
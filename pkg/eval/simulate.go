@@ -0,0 +1,249 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+type PolicyType string
+
+const (
+	IdentityPolicy        PolicyType = "identity"
+	ResourcePolicy        PolicyType = "resource"
+	PermissionsBoundary   PolicyType = "permissions-boundary"
+	SessionPolicy         PolicyType = "session"
+	ServiceControlPolicy  PolicyType = "scp"
+	ResourceControlPolicy PolicyType = "rcp"
+)
+
+// layerOrder is the order in which the layers are reported.
+var layerOrder = []PolicyType{
+	ServiceControlPolicy,
+	ResourceControlPolicy,
+	ResourcePolicy,
+	IdentityPolicy,
+	PermissionsBoundary,
+	SessionPolicy,
+}
+
+// PolicySet is a group of parsed policies of one policy type.
+type PolicySet struct {
+	Type     PolicyType       `json:"type" yaml:"type"`
+	Name     string           `json:"name" yaml:"name"` // reported as the source policy id, such as the policy ARN
+	Policies []*policy.Policy `json:"policies" yaml:"policies"`
+}
+
+// Decisions reported by Simulate, named as in `aws iam simulate-principal-policy`.
+const (
+	EvalAllowed      = "allowed"
+	EvalExplicitDeny = "explicitDeny"
+	EvalImplicitDeny = "implicitDeny"
+)
+
+type MatchedStatement struct {
+	SourcePolicyId   string     `json:"SourcePolicyId" yaml:"SourcePolicyId"`
+	SourcePolicyType PolicyType `json:"SourcePolicyType" yaml:"SourcePolicyType"`
+	StatementId      string     `json:"StatementId" yaml:"StatementId"`
+	Effect           string     `json:"Effect" yaml:"Effect"`
+}
+
+// LayerResult is the decision of one policy type on its own.
+type LayerResult struct {
+	Type     PolicyType         `json:"Type" yaml:"Type"`
+	Decision Decision           `json:"Decision" yaml:"Decision"`
+	Matched  []MatchedStatement `json:"MatchedStatements" yaml:"MatchedStatements"`
+}
+
+// SimulationReport is the outcome of Simulate. Its fields follow the
+// EvaluationResult of `aws iam simulate-principal-policy`.
+type SimulationReport struct {
+	EvalActionName    string             `json:"EvalActionName" yaml:"EvalActionName"`
+	EvalResourceName  string             `json:"EvalResourceName" yaml:"EvalResourceName"`
+	EvalDecision      string             `json:"EvalDecision" yaml:"EvalDecision"`
+	DecidingLayer     PolicyType         `json:"DecidingPolicyType" yaml:"DecidingPolicyType"` // layer that allowed or blocked the request
+	Reason            string             `json:"Reason" yaml:"Reason"`
+	CrossAccount      bool               `json:"CrossAccount" yaml:"CrossAccount"`
+	MatchedStatements []MatchedStatement `json:"MatchedStatements" yaml:"MatchedStatements"`
+	Layers            []LayerResult      `json:"Layers" yaml:"Layers"`
+}
+
+// Allowed reports whether the simulated request is allowed.
+func (r *SimulationReport) Allowed() bool {
+	return r.EvalDecision == EvalAllowed
+}
+
+func (r *SimulationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "EvalActionName: %s\n", r.EvalActionName)
+	fmt.Fprintf(&b, "EvalResourceName: %s\n", r.EvalResourceName)
+	fmt.Fprintf(&b, "EvalDecision: %s\n", r.EvalDecision)
+	if r.DecidingLayer != "" {
+		fmt.Fprintf(&b, "DecidingPolicyType: %s\n", r.DecidingLayer)
+	}
+	fmt.Fprintf(&b, "Reason: %s\n", r.Reason)
+	if len(r.MatchedStatements) > 0 {
+		b.WriteString("MatchedStatements:\n")
+		for _, m := range r.MatchedStatements {
+			fmt.Fprintf(&b, "  - %s %s %s %s\n", m.SourcePolicyType, m.Effect, m.SourcePolicyId, m.StatementId)
+		}
+	}
+	for _, l := range r.Layers {
+		fmt.Fprintf(&b, "Layer %s: %s\n", l.Type, l.Decision)
+	}
+	return b.String()
+}
+
+// Simulate combines the decisions of the policy layers the way AWS does:
+//   - an explicit Deny in any layer denies the request;
+//   - SCPs and RCPs, when present, must allow the request;
+//   - a same account resource policy that allows the request is enough on its
+//     own when it names the IAM user or the exact role session that makes it;
+//     when it names the role or the account instead, boundaries and session
+//     policies, when present, must allow the request too;
+//   - otherwise an identity policy must allow it, and boundaries and session
+//     policies, when present, must allow it too;
+//   - cross account requests need both the identity and the resource policy.
+//
+// Requests are cross account when the principal's account differs from the
+// resource's account, taken from the resource ARN or the aws:ResourceAccount
+// context key.
+func Simulate(sets []PolicySet, req Request) *SimulationReport {
+	report := &SimulationReport{
+		EvalActionName:   req.Action,
+		EvalResourceName: req.Resource,
+		CrossAccount:     isCrossAccount(req),
+	}
+
+	layers := map[PolicyType]*LayerResult{}
+	var grants []*policy.Policy // Allow statements of resource policies that match
+	for _, set := range sets {
+		l, ok := layers[set.Type]
+		if !ok {
+			l = &LayerResult{Type: set.Type, Decision: ImplicitDeny}
+			layers[set.Type] = l
+		}
+		res := Evaluate(set.Policies, req)
+		for _, p := range res.Matched {
+			l.Matched = append(l.Matched, matchedStatement(set, p))
+			if set.Type == ResourcePolicy && p.Allowed {
+				grants = append(grants, p)
+			}
+		}
+		switch {
+		case res.Decision == ExplicitDeny:
+			l.Decision = ExplicitDeny
+		case res.Decision == Allow && l.Decision != ExplicitDeny:
+			l.Decision = Allow
+		}
+	}
+	for _, t := range layerOrder {
+		if l, ok := layers[t]; ok {
+			report.Layers = append(report.Layers, *l)
+			report.MatchedStatements = append(report.MatchedStatements, l.Matched...)
+		}
+	}
+
+	decide := func(decision string, layer PolicyType, reason string) *SimulationReport {
+		report.EvalDecision = decision
+		report.DecidingLayer = layer
+		report.Reason = reason
+		return report
+	}
+	allows := func(t PolicyType) bool {
+		l, ok := layers[t]
+		return ok && l.Decision == Allow
+	}
+	present := func(t PolicyType) bool {
+		_, ok := layers[t]
+		return ok
+	}
+
+	for _, t := range layerOrder {
+		if l, ok := layers[t]; ok && l.Decision == ExplicitDeny {
+			return decide(EvalExplicitDeny, t, fmt.Sprintf("explicitly denied by a %s policy", t))
+		}
+	}
+	if present(ServiceControlPolicy) && !allows(ServiceControlPolicy) {
+		return decide(EvalImplicitDeny, ServiceControlPolicy, "no service control policy allows the request")
+	}
+	if present(ResourceControlPolicy) && !allows(ResourceControlPolicy) {
+		return decide(EvalImplicitDeny, ResourceControlPolicy, "no resource control policy allows the request")
+	}
+	if !report.CrossAccount && allows(ResourcePolicy) {
+		if namesCaller(grants, req.Principal) {
+			return decide(EvalAllowed, ResourcePolicy, "allowed by the resource policy, which names the caller")
+		}
+		if present(PermissionsBoundary) && !allows(PermissionsBoundary) {
+			return decide(EvalImplicitDeny, PermissionsBoundary, "the permissions boundary does not allow the request the resource policy allows")
+		}
+		if present(SessionPolicy) && !allows(SessionPolicy) {
+			return decide(EvalImplicitDeny, SessionPolicy, "the session policy does not allow the request the resource policy allows")
+		}
+		return decide(EvalAllowed, ResourcePolicy, "allowed by the resource policy")
+	}
+	if !allows(IdentityPolicy) {
+		if report.CrossAccount && allows(ResourcePolicy) {
+			return decide(EvalImplicitDeny, IdentityPolicy, "cross account request is allowed by the resource policy but by no identity policy")
+		}
+		return decide(EvalImplicitDeny, IdentityPolicy, "no identity or resource policy allows the request")
+	}
+	if present(PermissionsBoundary) && !allows(PermissionsBoundary) {
+		return decide(EvalImplicitDeny, PermissionsBoundary, "the permissions boundary does not allow the request")
+	}
+	if present(SessionPolicy) && !allows(SessionPolicy) {
+		return decide(EvalImplicitDeny, SessionPolicy, "the session policy does not allow the request")
+	}
+	if report.CrossAccount && !allows(ResourcePolicy) {
+		return decide(EvalImplicitDeny, ResourcePolicy, "cross account request is not allowed by the resource policy")
+	}
+	return decide(EvalAllowed, IdentityPolicy, "allowed by an identity policy")
+}
+
+// namesCaller reports whether one of the resource policy grants names
+// principal itself, and principal is an IAM user or a role or federated user
+// session. Such grants are not limited by boundaries or session policies,
+// while grants to a role or an account are.
+func namesCaller(grants []*policy.Policy, principal string) bool {
+	if !strings.Contains(principal, ":user/") && !strings.Contains(principal, ":assumed-role/") && !strings.Contains(principal, ":federated-user/") {
+		return false
+	}
+	for _, p := range grants {
+		if slices.Contains(p.Subjects, principal) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchedStatement(set PolicySet, p *policy.Policy) MatchedStatement {
+	m := MatchedStatement{
+		SourcePolicyId:   set.Name,
+		SourcePolicyType: set.Type,
+		StatementId:      p.Id,
+		Effect:           effectOf(p),
+	}
+	if m.SourcePolicyId == "" && p.Source != nil {
+		m.SourcePolicyId = p.Source.PolicyArn
+		if m.SourcePolicyId == "" {
+			m.SourcePolicyId = p.Source.PolicyName
+		}
+	}
+	return m
+}
+
+func isCrossAccount(req Request) bool {
+	principalAccount, ok := principalAccountOf(req.Principal)
+	if !ok {
+		return false
+	}
+	resourceAccount := ""
+	if values := req.Context.Get("aws:ResourceAccount"); len(values) == 1 {
+		resourceAccount = values[0]
+	} else if parts := strings.SplitN(req.Resource, ":", 6); len(parts) == 6 && parts[0] == "arn" {
+		resourceAccount = parts[4]
+	}
+	return resourceAccount != "" && resourceAccount != principalAccount
+}
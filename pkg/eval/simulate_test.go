@@ -0,0 +1,196 @@
+package eval
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+)
+
+func TestSimulate(t *testing.T) {
+	identity := PolicySet{Type: IdentityPolicy, Name: "arn:aws:iam::111122223333:policy/app", Policies: testutil.Parse(t, `{
+		"Statement": [
+			{"Sid": "S3", "Effect": "Allow", "Action": "s3:*", "Resource": "*"},
+			{"Sid": "Sqs", "Effect": "Allow", "Action": "sqs:SendMessage", "Resource": "*"}
+		]
+	}`)}
+	boundary := PolicySet{Type: PermissionsBoundary, Name: "boundary", Policies: testutil.Parse(t, `{
+		"Statement": [{"Effect": "Allow", "Action": ["s3:*", "dynamodb:GetItem"], "Resource": "*"}]
+	}`)}
+	session := PolicySet{Type: SessionPolicy, Policies: testutil.Parse(t, `{
+		"Statement": [{"Sid": "ReadOnly", "Effect": "Allow", "Action": "s3:Get*", "Resource": "*"}]
+	}`)}
+	scp := PolicySet{Type: ServiceControlPolicy, Name: "p-deny-delete", Policies: testutil.Parse(t, `{
+		"Statement": [
+			{"Sid": "FullAccess", "Effect": "Allow", "Action": "*", "Resource": "*"},
+			{"Sid": "NoDelete", "Effect": "Deny", "Action": "s3:DeleteBucket", "Resource": "*"}
+		]
+	}`)}
+	restrictiveScp := PolicySet{Type: ServiceControlPolicy, Policies: testutil.Parse(t, `{
+		"Statement": [{"Effect": "Allow", "Action": "ec2:*", "Resource": "*"}]
+	}`)}
+	bucketPolicy := PolicySet{Type: ResourcePolicy, Name: "bucket", Policies: testutil.Parse(t, `{
+		"Statement": [{
+			"Sid": "Partner",
+			"Effect": "Allow",
+			"Principal": {"AWS": ["arn:aws:iam::444455556666:root", "arn:aws:iam::111122223333:role/reader"]},
+			"Action": "dynamodb:GetItem",
+			"Resource": "*"
+		}]
+	}`)}
+
+	readerBoundary := PolicySet{Type: PermissionsBoundary, Name: "reader-boundary", Policies: testutil.Parse(t, `{
+		"Statement": [{"Effect": "Allow", "Action": "s3:*", "Resource": "*"}]
+	}`)}
+	callerPolicy := PolicySet{Type: ResourcePolicy, Name: "table", Policies: testutil.Parse(t, `{
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": {"AWS": ["arn:aws:sts::111122223333:assumed-role/reader/s", "arn:aws:iam::111122223333:user/alice"]},
+			"Action": "dynamodb:GetItem",
+			"Resource": "*"
+		}]
+	}`)}
+
+	const principal = "arn:aws:iam::111122223333:role/app"
+	tests := []struct {
+		name     string
+		sets     []PolicySet
+		req      Request
+		decision string
+		layer    PolicyType
+	}{
+		{
+			name:     "Identity Allows",
+			sets:     []PolicySet{identity},
+			req:      Request{Principal: principal, Action: "s3:GetObject", Resource: "arn:aws:s3:::b/k"},
+			decision: EvalAllowed,
+			layer:    IdentityPolicy,
+		},
+		{
+			name:     "Nothing Allows",
+			sets:     []PolicySet{identity},
+			req:      Request{Principal: principal, Action: "ec2:RunInstances", Resource: "*"},
+			decision: EvalImplicitDeny,
+			layer:    IdentityPolicy,
+		},
+		{
+			name:     "Boundary Blocks",
+			sets:     []PolicySet{identity, boundary},
+			req:      Request{Principal: principal, Action: "sqs:SendMessage", Resource: "arn:aws:sqs:us-east-1:111122223333:q"},
+			decision: EvalImplicitDeny,
+			layer:    PermissionsBoundary,
+		},
+		{
+			name:     "Session Blocks",
+			sets:     []PolicySet{identity, boundary, session},
+			req:      Request{Principal: principal, Action: "s3:PutObject", Resource: "arn:aws:s3:::b/k"},
+			decision: EvalImplicitDeny,
+			layer:    SessionPolicy,
+		},
+		{
+			name:     "All Layers Allow",
+			sets:     []PolicySet{identity, boundary, session, scp},
+			req:      Request{Principal: principal, Action: "s3:GetObject", Resource: "arn:aws:s3:::b/k"},
+			decision: EvalAllowed,
+			layer:    IdentityPolicy,
+		},
+		{
+			name:     "SCP Explicit Deny",
+			sets:     []PolicySet{identity, scp},
+			req:      Request{Principal: principal, Action: "s3:DeleteBucket", Resource: "arn:aws:s3:::b"},
+			decision: EvalExplicitDeny,
+			layer:    ServiceControlPolicy,
+		},
+		{
+			name:     "SCP Implicit Deny",
+			sets:     []PolicySet{identity, restrictiveScp},
+			req:      Request{Principal: principal, Action: "s3:GetObject", Resource: "arn:aws:s3:::b/k"},
+			decision: EvalImplicitDeny,
+			layer:    ServiceControlPolicy,
+		},
+		{
+			name:     "Same Account Resource Policy Is Enough",
+			sets:     []PolicySet{identity, boundary, bucketPolicy},
+			req:      Request{Principal: "arn:aws:sts::111122223333:assumed-role/reader/s", Action: "dynamodb:GetItem", Resource: "arn:aws:dynamodb:us-east-1:111122223333:table/t"},
+			decision: EvalAllowed,
+			layer:    ResourcePolicy,
+		},
+		{
+			name:     "Boundary Limits Resource Policy Grant To Role",
+			sets:     []PolicySet{identity, readerBoundary, bucketPolicy},
+			req:      Request{Principal: "arn:aws:sts::111122223333:assumed-role/reader/s", Action: "dynamodb:GetItem", Resource: "arn:aws:dynamodb:us-east-1:111122223333:table/t"},
+			decision: EvalImplicitDeny,
+			layer:    PermissionsBoundary,
+		},
+		{
+			name:     "Session Policy Limits Resource Policy Grant To Role",
+			sets:     []PolicySet{identity, session, bucketPolicy},
+			req:      Request{Principal: "arn:aws:sts::111122223333:assumed-role/reader/s", Action: "dynamodb:GetItem", Resource: "arn:aws:dynamodb:us-east-1:111122223333:table/t"},
+			decision: EvalImplicitDeny,
+			layer:    SessionPolicy,
+		},
+		{
+			name:     "Resource Policy Grant To Session",
+			sets:     []PolicySet{identity, readerBoundary, session, callerPolicy},
+			req:      Request{Principal: "arn:aws:sts::111122223333:assumed-role/reader/s", Action: "dynamodb:GetItem", Resource: "arn:aws:dynamodb:us-east-1:111122223333:table/t"},
+			decision: EvalAllowed,
+			layer:    ResourcePolicy,
+		},
+		{
+			name:     "Resource Policy Grant To User",
+			sets:     []PolicySet{identity, readerBoundary, callerPolicy},
+			req:      Request{Principal: "arn:aws:iam::111122223333:user/alice", Action: "dynamodb:GetItem", Resource: "arn:aws:dynamodb:us-east-1:111122223333:table/t"},
+			decision: EvalAllowed,
+			layer:    ResourcePolicy,
+		},
+		{
+			name:     "Cross Account Needs Identity Policy",
+			sets:     []PolicySet{identity, bucketPolicy},
+			req:      Request{Principal: "arn:aws:iam::444455556666:user/partner", Action: "dynamodb:GetItem", Resource: "arn:aws:dynamodb:us-east-1:111122223333:table/t"},
+			decision: EvalImplicitDeny,
+			layer:    IdentityPolicy,
+		},
+		{
+			name:     "Cross Account Needs Resource Policy",
+			sets:     []PolicySet{identity},
+			req:      Request{Principal: principal, Action: "s3:GetObject", Resource: "arn:aws:s3:::b/k", Context: Context{"aws:ResourceAccount": {"999999999999"}}},
+			decision: EvalImplicitDeny,
+			layer:    ResourcePolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Simulate(tt.sets, tt.req)
+			require.Equal(t, tt.decision, report.EvalDecision, report.String())
+			require.Equal(t, tt.layer, report.DecidingLayer)
+			require.NotEmpty(t, report.Reason)
+			require.Equal(t, tt.decision == EvalAllowed, report.Allowed())
+		})
+	}
+}
+
+func TestSimulationReportOutput(t *testing.T) {
+	identity := PolicySet{Type: IdentityPolicy, Name: "arn:aws:iam::111122223333:policy/app", Policies: testutil.Parse(t, `{
+		"Statement": [{"Sid": "S3", "Effect": "Allow", "Action": "s3:*", "Resource": "*"}]
+	}`)}
+	report := Simulate([]PolicySet{identity}, Request{Action: "s3:GetObject", Resource: "arn:aws:s3:::b/k"})
+
+	require.Equal(t, []MatchedStatement{{
+		SourcePolicyId:   "arn:aws:iam::111122223333:policy/app",
+		SourcePolicyType: IdentityPolicy,
+		StatementId:      "S3",
+		Effect:           "Allow",
+	}}, report.MatchedStatements)
+
+	text := report.String()
+	require.Contains(t, text, "EvalDecision: allowed")
+	require.Contains(t, text, "identity Allow arn:aws:iam::111122223333:policy/app S3")
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"EvalDecision":"allowed"`)
+	require.Contains(t, string(data), `"SourcePolicyType":"identity"`)
+}
@@ -0,0 +1,106 @@
+// Package generate writes least-privilege policies from the activity that
+// CloudTrail recorded for a principal.
+package generate
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/cloudtrail"
+	"github.com/paullesiak/policyparser/pkg/diff"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+type Options struct {
+	// IncludeDenied also grants the calls AWS refused. By default they are
+	// left out, as the principal evidently did without them.
+	IncludeDenied bool
+	// MaxResources, when positive, replaces the resources of an action with
+	// the wildcard of their longest common prefix once there are more of them,
+	// such as the objects of a bucket. This grants more than was observed.
+	MaxResources int
+	// Catalog checks the actions of the events and the resource types they
+	// act on. The bundled catalog is used when it is nil.
+	Catalog *catalog.Catalog
+}
+
+// Policy returns the statements that allow exactly the actions of events on
+// the resources the events list, and the problems of the events whose action
+// the catalog reports as no IAM action, which are left out. Of the resources
+// of an event only those of a resource type the action acts on are allowed,
+// such as the object and not the bucket of s3:GetObject. Actions seen on
+// events without such resources, which CloudTrail does not record for many
+// management calls, are allowed on every resource. Filter the events with
+// cloudtrail.Filter first.
+func Policy(events []cloudtrail.Event, opts Options) (policies []*policy.Policy, unmapped []error) {
+	c := opts.Catalog
+	if c == nil {
+		c = catalog.Default()
+	}
+	resources := map[string]map[string]bool{} // action to resources
+	for _, e := range events {
+		if e.Denied() && !opts.IncludeDenied {
+			continue
+		}
+		action, err := e.CatalogAction(c)
+		if err != nil {
+			unmapped = append(unmapped, err)
+			continue
+		}
+		if resources[action] == nil {
+			resources[action] = map[string]bool{}
+		}
+		applies := false
+		for _, r := range e.Resources {
+			if c.AppliesTo(action, r) {
+				resources[action][r] = true
+				applies = true
+			}
+		}
+		if !applies {
+			resources[action]["<.*>"] = true
+		}
+	}
+
+	var out []*policy.Policy
+	for action, set := range resources {
+		var list []string
+		for r := range set {
+			list = append(list, r)
+		}
+		sort.Strings(list)
+		if set["<.*>"] {
+			list = []string{"<.*>"}
+		} else if opts.MaxResources > 0 && len(list) > opts.MaxResources {
+			list = []string{commonPrefix(list) + "<.*>"}
+		}
+		out = append(out, &policy.Policy{
+			Version:   "2012-10-17",
+			Allowed:   true,
+			Actions:   []string{action},
+			Resources: list,
+		})
+	}
+	out = policy.Normalize(out)
+	for _, p := range out {
+		p.Id = ""
+	}
+	return out, unmapped
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// Unused diffs the policies a principal has now against a generated policy.
+// The permissions the diff removes are those the principal did not use.
+func Unused(current, generated []*policy.Policy) *diff.Result {
+	return diff.Policies(current, generated)
+}
@@ -0,0 +1,112 @@
+package generate
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/cloudtrail"
+)
+
+func readEvents(t *testing.T, f cloudtrail.Filter) []cloudtrail.Event {
+	t.Helper()
+	data, err := os.ReadFile("../cloudtrail/testdata/trail.json")
+	require.NoError(t, err)
+	all, err := cloudtrail.Read(bytes.NewReader(data))
+	require.NoError(t, err)
+	var events []cloudtrail.Event
+	for _, e := range all {
+		if f.Match(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestPolicy(t *testing.T) {
+	events := readEvents(t, cloudtrail.Filter{
+		Principal: "arn:aws:iam::111122223333:role/deploy",
+		Start:     time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:       time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+	})
+	policies, unmapped := Policy(events, Options{})
+	require.Empty(t, unmapped)
+	require.Len(t, policies, 3)
+
+	byAction := map[string][]string{}
+	for _, p := range policies {
+		require.True(t, p.Allowed)
+		for _, a := range p.Actions {
+			byAction[a] = p.Resources
+		}
+	}
+	require.Equal(t, map[string][]string{
+		"cloudwatch:PutMetricData":  {"<.*>"},
+		"lambda:UpdateFunctionCode": {"arn:aws:lambda:us-east-1:111122223333:function:app"},
+		// The event also lists the bucket, which s3:GetObject does not act on.
+		"s3:GetObject": {"arn:aws:s3:::artifacts/app.zip"},
+	}, byAction)
+
+	// The denied iam:CreateUser lists no resources and joins PutMetricData.
+	withDenied, _ := Policy(events, Options{IncludeDenied: true})
+	require.Len(t, withDenied, 3)
+	require.Equal(t, []string{"cloudwatch:PutMetricData", "iam:CreateUser"}, withDenied[0].Actions)
+}
+
+func TestPolicy_MaxResources(t *testing.T) {
+	var events []cloudtrail.Event
+	for _, key := range []string{"a/1", "a/2", "a/3"} {
+		events = append(events, cloudtrail.Event{Source: "s3.amazonaws.com", Name: "GetObject", Resources: []string{"arn:aws:s3:::bucket/" + key}})
+	}
+	policies, _ := Policy(events, Options{})
+	require.Len(t, policies[0].Resources, 3)
+	policies, _ = Policy(events, Options{MaxResources: 2})
+	require.Equal(t, []string{"arn:aws:s3:::bucket/a/<.*>"}, policies[0].Resources)
+}
+
+func TestPolicy_Mapping(t *testing.T) {
+	events := []cloudtrail.Event{
+		{Source: "s3.amazonaws.com", Name: "ListObjectsV2", Resources: []string{"arn:aws:s3:::bucket"}},
+		{Source: "s3.amazonaws.com", Name: "HeadObject", Resources: []string{"arn:aws:s3:::bucket/key", "arn:aws:s3:::bucket"}},
+		{Source: "s3.amazonaws.com", Name: "GetObjects", Resources: []string{"arn:aws:s3:::bucket/key"}},
+		// PutMetricData only supports *.
+		{Source: "monitoring.amazonaws.com", Name: "PutMetricData", Resources: []string{"arn:aws:cloudwatch:us-east-1:111122223333:alarm:high"}},
+	}
+	policies, unmapped := Policy(events, Options{})
+	byAction := map[string][]string{}
+	for _, p := range policies {
+		for _, a := range p.Actions {
+			byAction[a] = p.Resources
+		}
+	}
+	require.Equal(t, map[string][]string{
+		"cloudwatch:PutMetricData": {"<.*>"},
+		"s3:GetObject":             {"arn:aws:s3:::bucket/key"},
+		"s3:ListBucket":            {"arn:aws:s3:::bucket"},
+	}, byAction)
+	require.Len(t, unmapped, 1)
+	require.EqualError(t, unmapped[0], "s3.amazonaws.com event GetObjects: s3:GetObjects is not an action of s3, did you mean s3:GetObject?")
+}
+
+func TestUnused(t *testing.T) {
+	events := readEvents(t, cloudtrail.Filter{Principal: "arn:aws:iam::111122223333:role/deploy"})
+	generated, _ := Policy(events, Options{})
+	current := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": ["s3:GetObject", "s3:PutObject", "s3:DeleteObject"], "Resource": "arn:aws:s3:::artifacts/*"},
+		{"Effect": "Allow", "Action": "lambda:UpdateFunctionCode", "Resource": "arn:aws:lambda:us-east-1:111122223333:function:app"}]}`)
+
+	result := Unused(current, generated)
+	var removed []string
+	for _, p := range result.Removed {
+		removed = append(removed, p.Action)
+	}
+	require.Equal(t, []string{"s3:DeleteObject", "s3:GetObject", "s3:PutObject"}, removed)
+	require.NotEmpty(t, result.Access.OnlyA)
+	for _, req := range result.Access.OnlyA {
+		require.Contains(t, []string{"s3:DeleteObject", "s3:GetObject", "s3:PutObject"}, req.Action)
+	}
+}
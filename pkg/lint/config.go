@@ -0,0 +1,110 @@
+package lint
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Config selects the rules a Linter runs and the findings it reports. Rule
+// patterns are rule IDs, or a prefix followed by *, such as privesc/*. When
+// several severity patterns match a rule, the longest one applies.
+type Config struct {
+	Disable  []string            `json:"disable" yaml:"disable" mapstructure:"disable"`    // rules not to run
+	Severity map[string]Severity `json:"severity" yaml:"severity" mapstructure:"severity"` // severity overrides by rule pattern
+	Suppress []Suppression       `json:"suppress" yaml:"suppress" mapstructure:"suppress"`
+}
+
+// Suppression silences the findings of a rule, optionally only those that
+// involve a given document or statement.
+type Suppression struct {
+	Rule      string `json:"rule" yaml:"rule" mapstructure:"rule"`                // rule pattern
+	Document  string `json:"document" yaml:"document" mapstructure:"document"`    // document name, empty for any
+	Statement string `json:"statement" yaml:"statement" mapstructure:"statement"` // statement Sid, empty for any
+	Reason    string `json:"reason" yaml:"reason" mapstructure:"reason"`          // why the finding is accepted
+}
+
+// LoadConfig reads a YAML linter configuration, for example:
+//
+//	disable: [missing-sid]
+//	severity:
+//	  privesc/*: warning
+//	suppress:
+//	  - rule: public-principal
+//	    document: bucket-policy.json
+//	    statement: PublicRead
+//	    reason: static website
+func LoadConfig(r io.Reader) (Config, error) {
+	var cfg Config
+	if err := yaml.NewDecoder(r).Decode(&cfg); err != nil && err != io.EOF {
+		return Config{}, fmt.Errorf("error reading lint config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate reports severity overrides that are not error, warning or info.
+func (c Config) Validate() error {
+	for pattern, s := range c.Severity {
+		if _, ok := severityRank[s]; !ok {
+			return fmt.Errorf("lint config: unknown severity %q for %s, want error, warning or info", s, pattern)
+		}
+	}
+	return nil
+}
+
+func (c Config) disabled(rule string) bool {
+	for _, pattern := range c.Disable {
+		if matchRule(pattern, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// severity returns the severity of r. An exact ID wins over a pattern, and a
+// longer pattern, such as privesc/iam-*, over a shorter one, such as
+// privesc/*.
+func (c Config) severity(r Rule) Severity {
+	if s, ok := c.Severity[r.ID]; ok {
+		return s
+	}
+	best, severity := "", r.Severity
+	for pattern, s := range c.Severity {
+		if !matchRule(pattern, r.ID) {
+			continue
+		}
+		if best == "" || len(pattern) > len(best) || (len(pattern) == len(best) && pattern < best) {
+			best, severity = pattern, s
+		}
+	}
+	return severity
+}
+
+func (c Config) suppressed(f Finding) bool {
+	for _, s := range c.Suppress {
+		if !matchRule(s.Rule, f.Rule) {
+			continue
+		}
+		if s.Document == "" && s.Statement == "" {
+			return true
+		}
+		for _, ref := range f.Statements {
+			if (s.Document == "" || s.Document == ref.Document) && (s.Statement == "" || s.Statement == ref.Id) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchRule(pattern, rule string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(rule, prefix)
+	}
+	return pattern == rule
+}
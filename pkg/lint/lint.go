@@ -0,0 +1,147 @@
+// Package lint finds risky patterns in parsed AWS policies. A Linter runs a
+// set of Rules over one or more documents and reports Findings, filtered by a
+// Config that can disable rules, change their severity and suppress single
+// findings.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Document is a named set of parsed statements, such as one policy document.
+// Pass every document attached to a principal together to find problems that
+// span them.
+type Document struct {
+	Name      string            `json:"name" yaml:"name"`
+	Policies  []*policy.Policy  `json:"policies" yaml:"policies"`
+	Positions []policy.Position `json:"positions,omitempty" yaml:"positions,omitempty"` // where each statement starts, when known
+}
+
+// ref returns a reference to statement i of the document.
+func (d Document) ref(i int) StatementRef {
+	r := StatementRef{Document: d.Name, Index: i}
+	if i < len(d.Policies) && d.Policies[i] != nil {
+		r.Id = d.Policies[i].Id
+	}
+	if i < len(d.Positions) {
+		r.Line = d.Positions[i].Line
+		r.Column = d.Positions[i].Column
+	}
+	return r
+}
+
+// StatementRef points at a statement of a Document.
+type StatementRef struct {
+	Document string `json:"document" yaml:"document"`
+	Index    int    `json:"index" yaml:"index"` // index of the statement in Document.Policies
+	Id       string `json:"id" yaml:"id"`
+	Line     int    `json:"line,omitempty" yaml:"line,omitempty"`
+	Column   int    `json:"column,omitempty" yaml:"column,omitempty"`
+}
+
+func (r StatementRef) String() string {
+	if r.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d (%s)", r.Document, r.Line, r.Column, r.Id)
+	}
+	return fmt.Sprintf("%s#%d (%s)", r.Document, r.Index, r.Id)
+}
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+type Finding struct {
+	Rule        string         `json:"rule" yaml:"rule"`
+	Severity    Severity       `json:"severity" yaml:"severity"`
+	Message     string         `json:"message" yaml:"message"`
+	Remediation string         `json:"remediation" yaml:"remediation"`
+	Statements  []StatementRef `json:"statements" yaml:"statements"` // statements involved in the finding
+}
+
+func (f Finding) String() string {
+	refs := make([]string, 0, len(f.Statements))
+	for _, r := range f.Statements {
+		refs = append(refs, r.String())
+	}
+	return fmt.Sprintf("%s: %s [%s]", f.Rule, f.Message, strings.Join(refs, ", "))
+}
+
+// Rule is a check run by a Linter. Check returns findings with the Message and
+// Statements set; the Linter fills in the rest from the rule.
+type Rule struct {
+	ID          string
+	Severity    Severity
+	Description string
+	Remediation string
+	Check       func(docs []Document) []Finding
+}
+
+// Linter runs rules over documents.
+type Linter struct {
+	Rules  []Rule
+	Config Config
+}
+
+// New returns a Linter with the built-in rules and cfg.
+func New(cfg Config) *Linter {
+	return &Linter{Rules: Rules(), Config: cfg}
+}
+
+// Lint runs the built-in rules with the default configuration.
+func Lint(docs ...Document) []Finding {
+	return New(Config{}).Run(docs...)
+}
+
+// Run runs the enabled rules over docs and returns the findings that are not
+// suppressed, most severe first.
+func (l *Linter) Run(docs ...Document) []Finding {
+	var out []Finding
+	for _, r := range l.Rules {
+		if l.Config.disabled(r.ID) {
+			continue
+		}
+		for _, f := range r.Check(docs) {
+			f.Rule = r.ID
+			f.Severity = l.Config.severity(r)
+			f.Remediation = r.Remediation
+			if l.Config.suppressed(f) {
+				continue
+			}
+			out = append(out, f)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return severityRank[out[i].Severity] < severityRank[out[j].Severity]
+	})
+	return out
+}
+
+var severityRank = map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+
+// perStatement builds a Check that runs check on every statement of every
+// document. check returns the finding message, or false when the statement is
+// fine.
+func perStatement(check func(doc Document, i int, p *policy.Policy) (string, bool)) func([]Document) []Finding {
+	return func(docs []Document) []Finding {
+		var out []Finding
+		for _, doc := range docs {
+			for i, p := range doc.Policies {
+				if p == nil {
+					continue
+				}
+				if message, ok := check(doc, i, p); ok {
+					out = append(out, Finding{Message: message, Statements: []StatementRef{doc.ref(i)}})
+				}
+			}
+		}
+		return out
+	}
+}
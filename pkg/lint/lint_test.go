@@ -0,0 +1,123 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/pkg/parser"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+const bucketPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "PublicRead",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::website/*"
+    },
+    {"Effect": "Allow", "Principal": {"AWS": "123456789012"}, "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::website"}
+  ]
+}`
+
+func bucketDocument(t *testing.T) Document {
+	p, err := parser.NewParser(parser.Aws, bucketPolicy, false)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	policies, err := p.GetPolicy()
+	require.NoError(t, err)
+	return Document{Name: "bucket.json", Policies: policies, Positions: parser.Positions(p)}
+}
+
+func TestLint(t *testing.T) {
+	findings := Lint(bucketDocument(t))
+	require.Len(t, findings, 2)
+
+	public := findings[0]
+	require.Equal(t, "public-principal", public.Rule)
+	require.Equal(t, SeverityError, public.Severity)
+	require.NotEmpty(t, public.Remediation)
+	require.Equal(t, []StatementRef{{Document: "bucket.json", Index: 0, Id: "PublicRead", Line: 5, Column: 7}}, public.Statements)
+	require.Equal(t, "public-principal: Allow statement grants access to any principal without conditions [bucket.json:5:7 (PublicRead)]", public.String())
+
+	require.Equal(t, "missing-sid", findings[1].Rule)
+	require.Equal(t, SeverityInfo, findings[1].Severity)
+}
+
+func TestLinter_Config(t *testing.T) {
+	doc := bucketDocument(t)
+
+	cfg, err := LoadConfig(strings.NewReader(`
+disable: [missing-*]
+severity:
+  public-principal: warning
+`))
+	require.NoError(t, err)
+	findings := New(cfg).Run(doc)
+	require.Len(t, findings, 1)
+	require.Equal(t, SeverityWarning, findings[0].Severity)
+
+	cfg, err = LoadConfig(strings.NewReader(`
+suppress:
+  - rule: public-principal
+    document: bucket.json
+    statement: PublicRead
+    reason: static website
+  - rule: missing-sid
+    document: other.json
+`))
+	require.NoError(t, err)
+	require.Equal(t, "static website", cfg.Suppress[0].Reason)
+	findings = New(cfg).Run(doc)
+	require.Len(t, findings, 1)
+	require.Equal(t, "missing-sid", findings[0].Rule)
+
+	findings = New(Config{Suppress: []Suppression{{Rule: "*"}}}).Run(doc)
+	require.Empty(t, findings)
+
+	cfg, err = LoadConfig(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Empty(t, cfg.Disable)
+
+	_, err = LoadConfig(strings.NewReader("severity:\n  public-principal: warn\n"))
+	require.ErrorContains(t, err, `unknown severity "warn" for public-principal`)
+	_, err = LoadConfig(strings.NewReader("disable: {"))
+	require.Error(t, err)
+}
+
+func TestConfig_OverlappingSeverity(t *testing.T) {
+	cfg := Config{Severity: map[string]Severity{
+		"*":                SeverityInfo,
+		"privesc/*":        SeverityWarning,
+		"privesc/iam-*":    SeverityError,
+		"privesc/iam-pass": SeverityInfo,
+	}}
+	// Map order varies between runs; the longest pattern must win every time.
+	for range 20 {
+		require.Equal(t, SeverityError, cfg.severity(Rule{ID: "privesc/iam-create-key", Severity: SeverityInfo}))
+		require.Equal(t, SeverityWarning, cfg.severity(Rule{ID: "privesc/lambda", Severity: SeverityError}))
+		require.Equal(t, SeverityInfo, cfg.severity(Rule{ID: "privesc/iam-pass", Severity: SeverityError}))
+		require.Equal(t, SeverityInfo, cfg.severity(Rule{ID: "missing-sid", Severity: SeverityError}))
+	}
+	require.Equal(t, SeverityWarning, Config{}.severity(Rule{ID: "missing-sid", Severity: SeverityWarning}))
+}
+
+func TestLinter_CustomRule(t *testing.T) {
+	rule := Rule{
+		ID:          "custom/no-s3",
+		Severity:    SeverityWarning,
+		Description: "S3 is not allowed here",
+		Remediation: "Use the storage service instead.",
+		Check: perStatement(func(_ Document, _ int, p *policy.Policy) (string, bool) {
+			return "statement uses S3", len(p.Actions) > 0 && strings.HasPrefix(p.Actions[0], "s3:")
+		}),
+	}
+	findings := (&Linter{Rules: []Rule{rule}}).Run(bucketDocument(t))
+	require.Len(t, findings, 2)
+	require.Equal(t, "custom/no-s3", findings[0].Rule)
+	require.Equal(t, "Use the storage service instead.", findings[0].Remediation)
+}
@@ -0,0 +1,205 @@
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// escalation is a combination of actions that lets a principal gain more
+// privileges than it was given. See the Rhino Security Labs research on AWS
+// privilege escalation for the background of each path.
+type escalation struct {
+	id          string
+	actions     []string
+	anyResource bool // the actions must be allowed on every resource
+	message     string
+}
+
+var escalations = []escalation{
+	{id: "privesc/create-policy-version", actions: []string{"iam:CreatePolicyVersion"},
+		message: "can publish a new default version of a managed policy with any permissions"},
+	{id: "privesc/set-default-policy-version", actions: []string{"iam:SetDefaultPolicyVersion"},
+		message: "can make an older, possibly broader, version of a managed policy the default"},
+	{id: "privesc/create-access-key", actions: []string{"iam:CreateAccessKey"},
+		message: "can create access keys for other users"},
+	{id: "privesc/create-login-profile", actions: []string{"iam:CreateLoginProfile"},
+		message: "can set a console password for users that have none"},
+	{id: "privesc/update-login-profile", actions: []string{"iam:UpdateLoginProfile"},
+		message: "can change the console password of other users"},
+	{id: "privesc/attach-user-policy", actions: []string{"iam:AttachUserPolicy"},
+		message: "can attach any managed policy, such as AdministratorAccess, to a user"},
+	{id: "privesc/attach-group-policy", actions: []string{"iam:AttachGroupPolicy"},
+		message: "can attach any managed policy to a group it belongs to"},
+	{id: "privesc/attach-role-policy", actions: []string{"iam:AttachRolePolicy"},
+		message: "can attach any managed policy to a role it can use"},
+	{id: "privesc/put-user-policy", actions: []string{"iam:PutUserPolicy"},
+		message: "can write an inline policy with any permissions for a user"},
+	{id: "privesc/put-group-policy", actions: []string{"iam:PutGroupPolicy"},
+		message: "can write an inline policy with any permissions for a group"},
+	{id: "privesc/put-role-policy", actions: []string{"iam:PutRolePolicy"},
+		message: "can write an inline policy with any permissions for a role"},
+	{id: "privesc/add-user-to-group", actions: []string{"iam:AddUserToGroup"},
+		message: "can add a user to a more privileged group"},
+	{id: "privesc/update-assume-role-policy", actions: []string{"iam:UpdateAssumeRolePolicy", "sts:AssumeRole"},
+		message: "can rewrite the trust policy of a role and then assume it"},
+	{id: "privesc/assume-any-role", actions: []string{"sts:AssumeRole"}, anyResource: true,
+		message: "can assume any role that trusts the account"},
+	{id: "privesc/passrole-ec2", actions: []string{"iam:PassRole", "ec2:RunInstances"},
+		message: "can launch an instance with a privileged instance profile and use its credentials"},
+	{id: "privesc/passrole-lambda-invoke", actions: []string{"iam:PassRole", "lambda:CreateFunction", "lambda:InvokeFunction"},
+		message: "can create a function running as a privileged role and invoke it"},
+	{id: "privesc/passrole-lambda-event-source", actions: []string{"iam:PassRole", "lambda:CreateFunction", "lambda:CreateEventSourceMapping"},
+		message: "can create a function running as a privileged role and trigger it from an event source"},
+	{id: "privesc/update-function-code", actions: []string{"lambda:UpdateFunctionCode"},
+		message: "can replace the code of an existing function and act as its role"},
+	{id: "privesc/passrole-glue", actions: []string{"iam:PassRole", "glue:CreateDevEndpoint"},
+		message: "can create a Glue development endpoint with a privileged role and log in to it"},
+	{id: "privesc/update-glue-dev-endpoint", actions: []string{"glue:UpdateDevEndpoint"},
+		message: "can add an SSH key to an existing Glue development endpoint and use its role"},
+	{id: "privesc/passrole-cloudformation", actions: []string{"iam:PassRole", "cloudformation:CreateStack"},
+		message: "can create a stack that provisions resources as a privileged role"},
+	{id: "privesc/passrole-datapipeline", actions: []string{"iam:PassRole", "datapipeline:CreatePipeline", "datapipeline:PutPipelineDefinition"},
+		message: "can create a pipeline that runs commands as a privileged role"},
+	{id: "privesc/passrole-codebuild", actions: []string{"iam:PassRole", "codebuild:CreateProject", "codebuild:StartBuild"},
+		message: "can run a build as a privileged role"},
+	{id: "privesc/passrole-sagemaker", actions: []string{"iam:PassRole", "sagemaker:CreateNotebookInstance", "sagemaker:CreatePresignedNotebookInstanceUrl"},
+		message: "can create a notebook instance with a privileged role and open it"},
+	{id: "privesc/sagemaker-presigned-url", actions: []string{"sagemaker:CreatePresignedNotebookInstanceUrl"},
+		message: "can open existing notebook instances and use their roles"},
+	{id: "privesc/passrole-ecs", actions: []string{"iam:PassRole", "ecs:RegisterTaskDefinition", "ecs:RunTask"},
+		message: "can run a task with a privileged task role"},
+	{id: "privesc/ssm-send-command", actions: []string{"ssm:SendCommand"},
+		message: "can run commands on instances and use their instance profile credentials"},
+	{id: "privesc/ssm-start-session", actions: []string{"ssm:StartSession"},
+		message: "can open a shell on instances and use their instance profile credentials"},
+}
+
+// grant records which statements allow an action.
+type grant struct {
+	statements  []StatementRef
+	anyResource bool // one of the statements allows the action on every resource
+}
+
+// PrivilegeEscalation reports the known privilege escalation paths that the
+// Allow statements of docs open up together. Actions are expanded with the
+// bundled catalog, so wildcards and NotAction are taken into account, and an
+// unconditional Deny on every resource closes the paths that need the action.
+func PrivilegeEscalation(docs ...Document) []Finding {
+	return (&Linter{Rules: privescRules()}).Run(docs...)
+}
+
+// privescRules returns a rule for every escalation path.
+func privescRules() []Rule {
+	rules := make([]Rule, 0, len(escalations))
+	for _, e := range escalations {
+		remediation := "Grant these actions only to administrators, or restrict them to resources that cannot be used to gain privileges."
+		if slices.Contains(e.actions, "iam:PassRole") {
+			remediation = "Restrict iam:PassRole to the roles the principal needs to pass, with the iam:PassedToService condition key."
+		}
+		rules = append(rules, Rule{
+			ID:          e.id,
+			Severity:    SeverityError,
+			Description: "privilege escalation: " + strings.Join(e.actions, " + "),
+			Remediation: remediation,
+			Check:       e.check,
+		})
+	}
+	return rules
+}
+
+func (e escalation) check(docs []Document) []Finding {
+	grants := allowedActions(catalog.Default(), docs)
+	var refs []StatementRef
+	for _, action := range e.actions {
+		g, ok := grants[strings.ToLower(action)]
+		if !ok || (e.anyResource && !g.anyResource) {
+			return nil
+		}
+		refs = append(refs, g.statements...)
+	}
+	return []Finding{{Message: e.describe(), Statements: uniqueRefs(refs)}}
+}
+
+func (e escalation) describe() string {
+	actions := strings.Join(e.actions, " + ")
+	if e.anyResource {
+		actions += " on every resource"
+	}
+	return fmt.Sprintf("%s: %s", actions, e.message)
+}
+
+func allowedActions(c *catalog.Catalog, docs []Document) map[string]*grant {
+	grants := map[string]*grant{}
+	denied := map[string]bool{}
+	for _, doc := range docs {
+		for i, p := range doc.Policies {
+			if p == nil {
+				continue
+			}
+			actions, _ := c.Classify(p)
+			if !p.Allowed {
+				if len(p.Condition) == 0 && coversEveryResource(p) {
+					for _, a := range actions {
+						denied[strings.ToLower(a.Action)] = true
+					}
+				}
+				continue
+			}
+			ref := doc.ref(i)
+			for _, a := range actions {
+				key := strings.ToLower(a.Action)
+				g, ok := grants[key]
+				if !ok {
+					g = &grant{}
+					grants[key] = g
+				}
+				g.statements = append(g.statements, ref)
+				g.anyResource = g.anyResource || coversEveryResource(p)
+			}
+		}
+	}
+	for action := range denied {
+		delete(grants, action)
+	}
+	return grants
+}
+
+// coversEveryResource reports whether the statement applies to any resource:
+// its Resource is a wildcard, or it has no Resource or NotResource at all.
+func coversEveryResource(p *policy.Policy) bool {
+	if len(p.NotResources) > 0 {
+		return false
+	}
+	if len(p.Resources) == 0 {
+		return true
+	}
+	for _, r := range p.Resources {
+		if r == "<.*>" || r == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueRefs(refs []StatementRef) []StatementRef {
+	seen := map[StatementRef]bool{}
+	var out []StatementRef
+	for _, r := range refs {
+		if !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Document != out[j].Document {
+			return out[i].Document < out[j].Document
+		}
+		return out[i].Index < out[j].Index
+	})
+	return out
+}
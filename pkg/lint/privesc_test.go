@@ -0,0 +1,89 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+)
+
+func rules(findings []Finding) []string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.Rule)
+	}
+	return out
+}
+
+func TestPrivilegeEscalation_SingleDocument(t *testing.T) {
+	doc := Document{Name: "deploy", Policies: testutil.Parse(t, `{
+		"Statement": [
+			{"Sid": "Pass", "Effect": "Allow", "Action": "iam:PassRole", "Resource": "arn:aws:iam::123456789012:role/app"},
+			{"Sid": "Launch", "Effect": "Allow", "Action": "ec2:Run*", "Resource": "*"},
+			{"Sid": "Versions", "Effect": "Allow", "Action": "iam:CreatePolicyVersion", "Resource": "*"},
+			{"Sid": "Assume", "Effect": "Allow", "Action": "sts:AssumeRole", "Resource": "arn:aws:iam::123456789012:role/app"}
+		]
+	}`)}
+
+	findings := PrivilegeEscalation(doc)
+	require.Equal(t, []string{"privesc/create-policy-version", "privesc/passrole-ec2"}, rules(findings))
+
+	passRole := findings[1]
+	require.Equal(t, []StatementRef{
+		{Document: "deploy", Index: 0, Id: "Pass"},
+		{Document: "deploy", Index: 1, Id: "Launch"},
+	}, passRole.Statements)
+	require.Equal(t, "privesc/passrole-ec2: iam:PassRole + ec2:RunInstances: can launch an instance with a privileged "+
+		"instance profile and use its credentials [deploy#0 (Pass), deploy#1 (Launch)]", passRole.String())
+}
+
+func TestPrivilegeEscalation_AcrossDocuments(t *testing.T) {
+	functions := Document{Name: "functions", Policies: testutil.Parse(t, `{
+		"Statement": [{"Effect": "Allow", "Action": ["lambda:CreateFunction", "lambda:InvokeFunction"], "Resource": "*"}]
+	}`)}
+	roles := Document{Name: "roles", Policies: testutil.Parse(t, `{
+		"Statement": [{"Sid": "Pass", "Effect": "Allow", "Action": "iam:PassRole", "Resource": "*"}]
+	}`)}
+
+	require.Empty(t, PrivilegeEscalation(functions))
+	require.Empty(t, PrivilegeEscalation(roles))
+
+	findings := PrivilegeEscalation(functions, roles)
+	require.Equal(t, []string{"privesc/passrole-lambda-invoke"}, rules(findings))
+	require.Len(t, findings[0].Statements, 2)
+	require.Equal(t, "functions", findings[0].Statements[0].Document)
+	require.Equal(t, "roles", findings[0].Statements[1].Document)
+}
+
+func TestPrivilegeEscalation_AssumeAnyRole(t *testing.T) {
+	findings := PrivilegeEscalation(Document{Name: "d", Policies: testutil.Parse(t, `{
+		"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole", "Resource": "*"}]
+	}`)})
+	require.Equal(t, []string{"privesc/assume-any-role"}, rules(findings))
+	require.Contains(t, findings[0].Message, "sts:AssumeRole on every resource")
+}
+
+func TestPrivilegeEscalation_NotActionAndDeny(t *testing.T) {
+	admin := testutil.Parse(t, `{
+		"Statement": [
+			{"Effect": "Allow", "NotAction": ["iam:*", "sts:*"], "Resource": "*"},
+			{"Effect": "Allow", "Action": "iam:PassRole", "Resource": "*"}
+		]
+	}`)
+	findings := PrivilegeEscalation(Document{Name: "poweruser", Policies: admin})
+	require.Contains(t, rules(findings), "privesc/passrole-ec2")
+	require.Contains(t, rules(findings), "privesc/update-function-code")
+	require.NotContains(t, rules(findings), "privesc/attach-user-policy")
+
+	guardrail := testutil.Parse(t, `{
+		"Statement": [
+			{"Effect": "Deny", "Action": "iam:PassRole", "Resource": "*"},
+			{"Effect": "Deny", "Action": "lambda:UpdateFunctionCode", "Resource": "*",
+				"Condition": {"Bool": {"aws:MultiFactorAuthPresent": "false"}}}
+		]
+	}`)
+	findings = PrivilegeEscalation(Document{Name: "poweruser", Policies: admin}, Document{Name: "guardrail", Policies: guardrail})
+	require.NotContains(t, rules(findings), "privesc/passrole-ec2")
+	require.Contains(t, rules(findings), "privesc/update-function-code")
+}
@@ -0,0 +1,230 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Rules returns the built-in rules.
+func Rules() []Rule {
+	rules := []Rule{
+		{
+			ID:          "allow-not-action",
+			Severity:    SeverityWarning,
+			Description: "Allow statement with NotAction",
+			Remediation: "List the allowed actions in Action; NotAction in an Allow statement also grants every action added to AWS later.",
+			Check: perStatement(func(_ Document, _ int, p *policy.Policy) (string, bool) {
+				return "Allow statement uses NotAction", p.Allowed && len(p.NotActions) > 0
+			}),
+		},
+		{
+			ID:          "wildcard-resource-write",
+			Severity:    SeverityWarning,
+			Description: "write actions allowed on every resource",
+			Remediation: "Restrict Resource to the ARNs the write actions need.",
+			Check:       perStatement(checkWildcardResourceWrite),
+		},
+		{
+			ID:          "public-principal",
+			Severity:    SeverityError,
+			Description: "Principal * without conditions",
+			Remediation: "Name the principals that need access, or add conditions such as aws:SourceAccount, aws:SourceArn or aws:PrincipalOrgID.",
+			Check: perStatement(func(_ Document, _ int, p *policy.Policy) (string, bool) {
+				public := slices.Contains(p.Subjects, "<.*>") || slices.Contains(p.Subjects, "*")
+				return "Allow statement grants access to any principal without conditions", p.Allowed && public && len(p.Condition) == 0
+			}),
+		},
+		{
+			ID:          "redundant-statement",
+			Severity:    SeverityWarning,
+			Description: "statement duplicates an earlier statement",
+			Remediation: "Remove the duplicate statement.",
+			Check:       checkRedundantStatements,
+		},
+		{
+			ID:          "shadowed-statement",
+			Severity:    SeverityWarning,
+			Description: "statement can never affect the outcome of a request",
+			Remediation: "Remove the shadowed statement, or narrow the shadowing statement if it grants or denies more than intended.",
+			Check:       checkShadowedStatements,
+		},
+		{
+			ID:          "empty-condition",
+			Severity:    SeverityWarning,
+			Description: "empty Condition block or condition operator",
+			Remediation: "Remove the empty block, or add the condition keys it was meant to check.",
+			Check:       perStatement(checkEmptyCondition),
+		},
+		{
+			ID:          "missing-sid",
+			Severity:    SeverityInfo,
+			Description: "statement has no Sid",
+			Remediation: "Add a Sid that describes the purpose of the statement.",
+			Check: perStatement(func(_ Document, _ int, p *policy.Policy) (string, bool) {
+				// The parser names statements without a Sid <policy id>:<index>;
+				// a Sid cannot contain a colon.
+				return "statement has no Sid", p.Id == "" || strings.Contains(p.Id, ":")
+			}),
+		},
+		{
+			ID:          "invalid-action",
+			Severity:    SeverityError,
+			Description: "action that does not exist, such as a misspelled one",
+			Remediation: "Fix the spelling of the action; IAM accepts unknown actions but they grant nothing.",
+			Check: catalogCheck((*catalog.Catalog).Validate, func(d policy.Diagnostic) bool {
+				return d.Operator == ""
+			}),
+		},
+		{
+			ID:          "condition-key",
+			Severity:    SeverityWarning,
+			Description: "condition key that is mis-cased, compared with the wrong operator type or not supported by the actions",
+			Remediation: "Spell the key as the Service Authorization Reference does, use an operator of its type, and only use it with actions that support it.",
+			Check: catalogCheck((*catalog.Catalog).Validate, func(d policy.Diagnostic) bool {
+				return d.Operator != ""
+			}),
+		},
+		{
+			ID:          "not-in-catalog",
+			Severity:    SeverityInfo,
+			Description: "action, service or condition key missing from the bundled catalog",
+			Remediation: "Check the name against the Service Authorization Reference; the bundled catalog does not list every action.",
+			Check:       catalogCheck((*catalog.Catalog).Unknown, func(policy.Diagnostic) bool { return true }),
+		},
+		{
+			ID:          "deprecated-version",
+			Severity:    SeverityWarning,
+			Description: "policy Version is missing or 2008-10-17",
+			Remediation: `Set "Version": "2012-10-17"; older versions do not support policy variables.`,
+			Check:       checkVersion,
+		},
+	}
+	return append(rules, privescRules()...)
+}
+
+var writeLevels = []catalog.AccessLevel{catalog.Write, catalog.PermissionsManagement, catalog.Tagging}
+
+func checkWildcardResourceWrite(_ Document, _ int, p *policy.Policy) (string, bool) {
+	if !p.Allowed || !(slices.Contains(p.Resources, "<.*>") || slices.Contains(p.Resources, "*")) {
+		return "", false
+	}
+	actions, _ := catalog.Default().Classify(p)
+	var writes []string
+	for _, a := range actions {
+		if slices.Contains(writeLevels, a.Level) {
+			writes = append(writes, a.Action)
+		}
+	}
+	if len(writes) == 0 {
+		return "", false
+	}
+	examples := strings.Join(writes[:min(3, len(writes))], ", ")
+	if len(writes) > 3 {
+		examples += ", ..."
+	}
+	return fmt.Sprintf("statement allows %d write actions on every resource: %s", len(writes), examples), true
+}
+
+// catalogCheck builds a Check that runs a validation of the bundled catalog on
+// every statement and reports the diagnostics keep accepts.
+func catalogCheck(validate func(*catalog.Catalog, []*policy.Policy) []policy.Diagnostic, keep func(policy.Diagnostic) bool) func([]Document) []Finding {
+	return func(docs []Document) []Finding {
+		var out []Finding
+		for _, doc := range docs {
+			for i, p := range doc.Policies {
+				if p == nil {
+					continue
+				}
+				for _, d := range validate(catalog.Default(), []*policy.Policy{p}) {
+					if keep(d) {
+						out = append(out, Finding{Message: d.Message, Statements: []StatementRef{doc.ref(i)}})
+					}
+				}
+			}
+		}
+		return out
+	}
+}
+
+func checkEmptyCondition(_ Document, _ int, p *policy.Policy) (string, bool) {
+	if p.Condition != nil && len(p.Condition) == 0 {
+		return "Condition block is empty", true
+	}
+	for _, c := range p.Condition {
+		if len(c.Key) == 0 {
+			return fmt.Sprintf("condition operator %s has no keys", c.Operation), true
+		}
+	}
+	return "", false
+}
+
+func checkVersion(docs []Document) []Finding {
+	var out []Finding
+	for _, doc := range docs {
+		for i, p := range doc.Policies {
+			if p == nil {
+				continue
+			}
+			switch p.Version {
+			case "":
+				out = append(out, Finding{Message: "policy has no Version and defaults to 2008-10-17", Statements: []StatementRef{doc.ref(i)}})
+			case "2008-10-17":
+				out = append(out, Finding{Message: "policy uses Version 2008-10-17", Statements: []StatementRef{doc.ref(i)}})
+			}
+			// The Version belongs to the document, report it once.
+			break
+		}
+	}
+	return out
+}
+
+func checkRedundantStatements(docs []Document) []Finding {
+	var out []Finding
+	for _, doc := range docs {
+		seen := map[string]int{}
+		for i, p := range doc.Policies {
+			if p == nil {
+				continue
+			}
+			key := statementKey(p)
+			if first, ok := seen[key]; ok {
+				out = append(out, Finding{
+					Message:    fmt.Sprintf("statement is identical to statement %d", first),
+					Statements: []StatementRef{doc.ref(first), doc.ref(i)},
+				})
+				continue
+			}
+			seen[key] = i
+		}
+	}
+	return out
+}
+
+// statementKey identifies a statement by everything but its Id. Element
+// entries are compared as sets, actions ignoring case.
+func statementKey(p *policy.Policy) string {
+	set := func(values []string, fold bool) []string {
+		out := make([]string, 0, len(values))
+		for _, v := range values {
+			if fold {
+				v = strings.ToLower(v)
+			}
+			out = append(out, v)
+		}
+		slices.Sort(out)
+		return slices.Compact(out)
+	}
+	key, _ := json.Marshal([]any{
+		p.Allowed,
+		set(p.Subjects, false), set(p.NotSubjects, false),
+		set(p.Actions, true), set(p.NotActions, true),
+		set(p.Resources, false), set(p.NotResources, false),
+		p.Condition,
+	})
+	return string(key)
+}
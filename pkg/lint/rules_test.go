@@ -0,0 +1,139 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		document string
+		messages []string
+	}{
+		{
+			name: "Allow NotAction", rule: "allow-not-action",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "NotAction": "iam:*", "Resource": "*"},
+				{"Sid": "B", "Effect": "Deny", "NotAction": "iam:*", "Resource": "*"}]}`,
+			messages: []string{"Allow statement uses NotAction"},
+		},
+		{
+			name: "Write On Every Resource", rule: "wildcard-resource-write",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": ["s3:PutObject", "s3:GetObject"], "Resource": "*"},
+				{"Sid": "B", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"},
+				{"Sid": "C", "Effect": "Allow", "Action": "s3:PutObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+			messages: []string{"statement allows 1 write actions on every resource: s3:PutObject"},
+		},
+		{
+			name: "Public Principal", rule: "public-principal",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::b/*"},
+				{"Sid": "B", "Effect": "Allow", "Principal": {"AWS": "*"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::b/*",
+					"Condition": {"StringEquals": {"aws:PrincipalOrgID": "o-123"}}},
+				{"Sid": "C", "Effect": "Allow", "Principal": {"AWS": "123456789012"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::b/*"}]}`,
+			messages: []string{"Allow statement grants access to any principal without conditions"},
+		},
+		{
+			name: "Redundant Statement", rule: "redundant-statement",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": "*"},
+				{"Sid": "B", "Effect": "Allow", "Action": ["S3:ListBucket", "s3:GetObject"], "Resource": "*"},
+				{"Sid": "C", "Effect": "Deny", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": "*"}]}`,
+			messages: []string{"statement is identical to statement 0"},
+		},
+		{
+			name: "Empty Condition", rule: "empty-condition",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*", "Condition": {}},
+				{"Sid": "B", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*", "Condition": {"Bool": {}}},
+				{"Sid": "C", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`,
+			messages: []string{"Condition block is empty", "condition operator Bool has no keys"},
+		},
+		{
+			name: "Missing Sid", rule: "missing-sid",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"},
+				{"Sid": "Named", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`,
+			messages: []string{"statement has no Sid"},
+		},
+		{
+			name: "Invalid Action", rule: "invalid-action",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": ["s3:GetObjects", "s3:GetObject", "athena:StartQueryExecution"], "Resource": "*"},
+				{"Sid": "B", "Effect": "Deny", "NotAction": "iam:PasRole", "Resource": "*",
+					"Condition": {"NumericEquals": {"aws:SourceIp": "1"}}}]}`,
+			messages: []string{
+				"s3:GetObjects is not an action of s3, did you mean s3:GetObject?",
+				"iam:PasRole is not an action of iam, did you mean iam:PassRole?",
+			},
+		},
+		{
+			name: "Condition Key", rule: "condition-key",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*",
+					"Condition": {"IpAddress": {"aws:sourceip": "10.0.0.0/8"}, "StringLike": {"s3:prefix": "home/*"}}},
+				{"Sid": "B", "Effect": "Allow", "Action": "s3:ListBucket", "Resource": "*",
+					"Condition": {"NumericEquals": {"aws:PrincipalTag/team": "1"}, "StringLike": {"s3:prefix": "home/*"}}}]}`,
+			messages: []string{
+				"condition key aws:sourceip is spelled aws:SourceIp",
+				"condition key s3:prefix is not supported by s3:GetObject",
+				"operator NumericEquals does not suit condition key aws:PrincipalTag/team of type String",
+			},
+		},
+		{
+			name: "Not In Catalog", rule: "not-in-catalog",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": ["s3:GetObjects", "s3:GetObjectAttributes", "athena:StartQueryExecution"], "Resource": "*"}]}`,
+			messages: []string{"s3:GetObjectAttributes is not in the catalog", "service athena is not in the catalog"},
+		},
+		{
+			name: "Old Version", rule: "deprecated-version",
+			document: `{"Version": "2008-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"},
+				{"Sid": "B", "Effect": "Allow", "Action": "s3:ListBucket", "Resource": "*"}]}`,
+			messages: []string{"policy uses Version 2008-10-17"},
+		},
+		{
+			name: "No Version", rule: "deprecated-version",
+			document: `{"Statement": [{"Sid": "A", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`,
+			messages: []string{"policy has no Version and defaults to 2008-10-17"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule Rule
+			for _, r := range Rules() {
+				if r.ID == tt.rule {
+					rule = r
+				}
+			}
+			require.Equal(t, tt.rule, rule.ID)
+
+			var messages []string
+			for _, f := range rule.Check([]Document{{Name: "doc", Policies: testutil.Parse(t, tt.document)}}) {
+				messages = append(messages, f.Message)
+			}
+			require.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestRules_Metadata(t *testing.T) {
+	ids := map[string]bool{}
+	for _, r := range Rules() {
+		require.NotEmpty(t, r.ID)
+		require.False(t, ids[r.ID], "duplicate rule %s", r.ID)
+		ids[r.ID] = true
+		require.Contains(t, []Severity{SeverityError, SeverityWarning, SeverityInfo}, r.Severity)
+		require.NotEmpty(t, r.Description)
+		require.NotEmpty(t, r.Remediation)
+		require.NotNil(t, r.Check)
+	}
+}
@@ -0,0 +1,128 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/compare"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Shadowed reports the statements of each of docs that can never affect the
+// outcome of a request: duplicates, statements another statement of the same
+// document and effect covers, and Allow statements a Deny statement of the
+// same document negates entirely. Each finding names the shadowing statement
+// first.
+func Shadowed(docs ...Document) []Finding {
+	var rules []Rule
+	for _, r := range Rules() {
+		if r.ID == "redundant-statement" || r.ID == "shadowed-statement" {
+			rules = append(rules, r)
+		}
+	}
+	return (&Linter{Rules: rules}).Run(docs...)
+}
+
+// located is a statement with its reference.
+type located struct {
+	ref StatementRef
+	p   *policy.Policy
+}
+
+// checkShadowedStatements compares every statement with every other one of
+// the same document, as redundant-statement does. Identical statements are
+// left to redundant-statement.
+func checkShadowedStatements(docs []Document) []Finding {
+	var out []Finding
+	for _, doc := range docs {
+		var all []located
+		for i, p := range doc.Policies {
+			if p != nil {
+				all = append(all, located{ref: doc.ref(i), p: p})
+			}
+		}
+		for i, s := range all {
+			if f, ok := shadowedBy(all, i); ok {
+				f.Statements = append(f.Statements, s.ref)
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}
+
+// shadowedBy returns the finding for statement i with the shadowing statement
+// as its only reference, preferring a Deny that negates an Allow.
+func shadowedBy(all []located, i int) (Finding, bool) {
+	s := all[i]
+	if s.p.Allowed {
+		for _, d := range all {
+			if d.p.Allowed || !mayCover(d.p, s.p) {
+				continue
+			}
+			// The Allow statement together with the Deny allows nothing.
+			res := compare.Compare([]*policy.Policy{s.p, d.p}, nil)
+			if res.Exhaustive && res.Relation == compare.Equivalent {
+				return Finding{
+					Message:    fmt.Sprintf("Allow statement is denied entirely by %s", d.ref),
+					Statements: []StatementRef{d.ref},
+				}, true
+			}
+		}
+	}
+
+	for j, t := range all {
+		if i == j || t.p.Allowed != s.p.Allowed || statementKey(t.p) == statementKey(s.p) || !mayCover(t.p, s.p) {
+			continue
+		}
+		// Deny statements are compared by what they would allow.
+		res := compare.Compare([]*policy.Policy{asAllow(s.p)}, []*policy.Policy{asAllow(t.p)})
+		if !res.Exhaustive {
+			continue
+		}
+		// Of two equivalent statements the later one is reported.
+		if res.Relation == compare.Subset || (res.Relation == compare.Equivalent && j < i) {
+			return Finding{
+				Message:    fmt.Sprintf("statement is covered by %s", t.ref),
+				Statements: []StatementRef{t.ref},
+			}, true
+		}
+	}
+	return Finding{}, false
+}
+
+func asAllow(p *policy.Policy) *policy.Policy {
+	allow := *p
+	allow.Allowed = true
+	return &allow
+}
+
+// mayCover is a quick test that t can cover s: every entry of an Action or
+// Resource element of s must start with the literal text before the first
+// wildcard of some entry of t. It avoids comparing unrelated statements.
+func mayCover(t, s *policy.Policy) bool {
+	return mayCoverEntries(t.Actions, s.Actions, true) && mayCoverEntries(t.Resources, s.Resources, false)
+}
+
+func mayCoverEntries(t, s []string, fold bool) bool {
+	if len(t) == 0 || len(s) == 0 {
+		return true
+	}
+	for _, entry := range s {
+		covered := false
+		for _, pattern := range t {
+			prefix := pattern
+			if i := strings.IndexAny(pattern, "*?<"); i >= 0 {
+				prefix = pattern[:i]
+			}
+			if strings.HasPrefix(entry, prefix) || (fold && strings.HasPrefix(strings.ToLower(entry), strings.ToLower(prefix))) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+)
+
+func TestShadowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string // findings as rule: message [statements]
+	}{
+		{
+			name: "Allow Covered By Earlier Allow",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "Read", "Effect": "Allow", "Action": "s3:Get*", "Resource": "arn:aws:s3:::bucket/*"},
+				{"Sid": "Object", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/logs/*"}]}`,
+			want: []string{"shadowed-statement: statement is covered by doc#0 (Read) [doc#0 (Read), doc#1 (Object)]"},
+		},
+		{
+			name: "Allow Covered By Later Allow",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "Object", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*",
+				 "Condition": {"Bool": {"aws:SecureTransport": "true"}}},
+				{"Sid": "Read", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+			want: []string{"shadowed-statement: statement is covered by doc#1 (Read) [doc#1 (Read), doc#0 (Object)]"},
+		},
+		{
+			name: "Condition Keeps Statement",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "Secure", "Effect": "Allow", "Action": "s3:Get*", "Resource": "*", "Condition": {"Bool": {"aws:SecureTransport": "true"}}},
+				{"Sid": "Object", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}]}`,
+		},
+		{
+			name: "Allow Negated By Deny",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "Delete", "Effect": "Allow", "Action": ["s3:DeleteObject", "s3:DeleteObjectVersion"], "Resource": "arn:aws:s3:::bucket/*"},
+				{"Sid": "NoDelete", "Effect": "Deny", "Action": "s3:Delete*", "Resource": "*"}]}`,
+			want: []string{"shadowed-statement: Allow statement is denied entirely by doc#1 (NoDelete) [doc#1 (NoDelete), doc#0 (Delete)]"},
+		},
+		{
+			name: "Conditional Deny Keeps Allow",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "Delete", "Effect": "Allow", "Action": "s3:DeleteObject", "Resource": "arn:aws:s3:::bucket/*"},
+				{"Sid": "NoMfa", "Effect": "Deny", "Action": "s3:Delete*", "Resource": "*", "Condition": {"BoolIfExists": {"aws:MultiFactorAuthPresent": "false"}}}]}`,
+		},
+		{
+			name: "Deny Covered By Deny",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "NoIam", "Effect": "Deny", "Action": "iam:*", "Resource": "*"},
+				{"Sid": "NoUsers", "Effect": "Deny", "Action": "iam:CreateUser", "Resource": "*"}]}`,
+			want: []string{"shadowed-statement: statement is covered by doc#0 (NoIam) [doc#0 (NoIam), doc#1 (NoUsers)]"},
+		},
+		{
+			name: "Duplicates With Different Sids",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": "ec2:DescribeInstances", "Resource": "*"},
+				{"Sid": "B", "Effect": "Allow", "Action": "EC2:DescribeInstances", "Resource": "*"}]}`,
+			want: []string{"redundant-statement: statement is identical to statement 0 [doc#0 (A), doc#1 (B)]"},
+		},
+		{
+			name: "Equivalent Statements",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": "s3:*", "Resource": "*"},
+				{"Sid": "B", "Effect": "Allow", "Action": ["s3:*", "s3:GetObject"], "Resource": "*"}]}`,
+			want: []string{"shadowed-statement: statement is covered by doc#0 (A) [doc#0 (A), doc#1 (B)]"},
+		},
+		{
+			name: "Unrelated Statements",
+			document: `{"Version": "2012-10-17", "Statement": [
+				{"Sid": "A", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"},
+				{"Sid": "B", "Effect": "Allow", "Action": "ec2:DescribeInstances", "Resource": "*"},
+				{"Sid": "C", "Effect": "Deny", "Action": "s3:PutObject", "Resource": "*"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range Shadowed(Document{Name: "doc", Policies: testutil.Parse(t, tt.document)}) {
+				got = append(got, f.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestShadowed_AcrossDocuments(t *testing.T) {
+	boundary := Document{Name: "guardrail", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "NoIam", "Effect": "Deny", "Action": "iam:*", "Resource": "*"},
+		{"Sid": "Buckets", "Effect": "Allow", "Action": "s3:*", "Resource": "*"}]}`)}
+	admin := Document{Name: "admin", Policies: testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Users", "Effect": "Allow", "Action": "iam:*User*", "Resource": "*"},
+		{"Sid": "Buckets", "Effect": "Allow", "Action": "s3:*", "Resource": "*"},
+		{"Sid": "Objects", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]}`)}
+
+	// Statements are only compared with those of the same document.
+	findings := Shadowed(boundary, admin)
+	require.Len(t, findings, 1)
+	require.Equal(t, []StatementRef{
+		{Document: "admin", Index: 1, Id: "Buckets"},
+		{Document: "admin", Index: 2, Id: "Objects"},
+	}, findings[0].Statements)
+}
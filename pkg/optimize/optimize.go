@@ -0,0 +1,241 @@
+// Package optimize makes policies smaller without changing the access they
+// grant, and splits policies that are still too large across several
+// documents that each fit an IAM size quota.
+package optimize
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/paullesiak/policyparser/pkg/render"
+)
+
+// IAM policy size quotas, in characters without whitespace. The inline quotas
+// apply to the sum of all inline policies of the user, group or role.
+const (
+	ManagedPolicyLimit = 6144
+	UserInlineLimit    = 2048
+	GroupInlineLimit   = 5120
+	RoleInlineLimit    = 10240
+)
+
+type Options struct {
+	// Limit is the largest document size to produce; zero means
+	// ManagedPolicyLimit.
+	Limit int
+	// Catalog decides which action lists a wildcard can replace; nil means
+	// catalog.Default().
+	Catalog *catalog.Catalog
+	// CollapseActions replaces action lists with wildcards even when the
+	// catalog is partial, as the bundled one is. A wildcard then also matches
+	// the actions of the service the catalog does not list. Action lists are
+	// always collapsed with a Complete catalog.
+	CollapseActions bool
+}
+
+// Document is one policy document of the optimized result.
+type Document struct {
+	Policies []*policy.Policy    `json:"policies" yaml:"policies"`
+	Aws      *render.AwsDocument `json:"document" yaml:"document"`
+	Size     int                 `json:"size" yaml:"size"` // characters without whitespace
+}
+
+type Result struct {
+	Documents    []Document `json:"documents" yaml:"documents"`
+	OriginalSize int        `json:"original-size" yaml:"original-size"` // size of the input as a single document
+	Size         int        `json:"size" yaml:"size"`                   // total size of the documents
+}
+
+// Optimize normalizes policies, which drops duplicate and covered entries and
+// merges statements that differ in one element only. With a Complete catalog,
+// or when asked to, it replaces the action lists of Allow statements with
+// wildcards that match no other action of the catalog, see CollapseActions,
+// and merges the statements that then become alike. When the result exceeds
+// the limit, its statements are split across documents, and statements that
+// are too large on their own are split by their Action or Resource entries.
+//
+// A partial catalog cannot tell which actions a wildcard matches, so its
+// action lists are kept unless Options.CollapseActions is set: the wildcard
+// could grant more than the original policies.
+//
+// The documents are meant to be attached to the same principal or resource
+// together: a Deny statement in one of them still applies to the Allow
+// statements of the others.
+func Optimize(policies []*policy.Policy, opts Options) (*Result, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = ManagedPolicyLimit
+	}
+	if opts.Catalog == nil {
+		opts.Catalog = catalog.Default()
+	}
+
+	res := &Result{OriginalSize: render.Aws(policies).Size()}
+	statements := policy.Normalize(policies)
+	if opts.Catalog.Complete || opts.CollapseActions {
+		for _, p := range statements {
+			if p.Allowed {
+				p.Actions = CollapseActions(opts.Catalog, p.Actions)
+			}
+		}
+		statements = policy.Normalize(statements)
+	}
+	for _, p := range statements {
+		// Sids only cost characters here; the normalized Ids are not meaningful.
+		p.Id = ""
+	}
+
+	docs, err := split(statements, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		doc := render.Aws(d)
+		res.Documents = append(res.Documents, Document{Policies: d, Aws: doc, Size: doc.Size()})
+		res.Size += doc.Size()
+	}
+	return res, nil
+}
+
+// CollapseActions replaces the actions of known services with the shortest
+// prefix wildcards that match no other action of the catalog, such as
+// s3:GetObject*, when a wildcard replaces at least two entries. Wildcard
+// entries and actions the catalog does not know are kept as they are. Like any
+// wildcard, the result also matches actions AWS adds to the service later, and
+// with a partial catalog the actions it does not list.
+func CollapseActions(c *catalog.Catalog, actions []string) []string {
+	covered := map[string]bool{} // lowercased full names
+	var kept []string
+	for _, a := range actions {
+		expanded := c.Expand(a)
+		if len(expanded) == 0 {
+			kept = append(kept, a)
+			continue
+		}
+		if strings.ContainsAny(a, "*?") || strings.Contains(a, "<.*>") {
+			kept = append(kept, a)
+		}
+		for _, e := range expanded {
+			covered[strings.ToLower(e)] = true
+		}
+	}
+
+	// For every concrete entry find the shortest prefix whose wildcard
+	// matches only covered actions.
+	patterns := map[string][]string{} // wildcard to the entries it replaces
+	var concrete []string
+	for _, a := range actions {
+		if strings.ContainsAny(a, "*?") || strings.Contains(a, "<.*>") || len(c.Expand(a)) == 0 {
+			continue
+		}
+		concrete = append(concrete, a)
+		action, _ := c.Action(a)
+		svc, _ := c.Service(strings.SplitN(a, ":", 2)[0])
+		name := action.Name
+		for n := 0; n <= len(name); n++ {
+			prefix := strings.ToLower(name[:n])
+			complete := true
+			for _, other := range svc.Actions {
+				if strings.HasPrefix(strings.ToLower(other.Name), prefix) && !covered[strings.ToLower(other.FullName())] {
+					complete = false
+					break
+				}
+			}
+			if !complete {
+				continue
+			}
+			pattern := svc.Prefix + ":" + name[:n] + "<.*>"
+			if n == len(name) {
+				pattern = action.FullName()
+			}
+			patterns[pattern] = append(patterns[pattern], a)
+			break
+		}
+	}
+
+	replaced := map[string]bool{}
+	var out []string
+	for pattern, entries := range patterns {
+		if len(entries) < 2 || !strings.HasSuffix(pattern, "<.*>") {
+			continue
+		}
+		out = append(out, pattern)
+		for _, e := range entries {
+			replaced[e] = true
+		}
+	}
+	for _, a := range concrete {
+		if !replaced[a] {
+			out = append(out, a)
+		}
+	}
+	out = append(out, kept...)
+	sort.Strings(out)
+	return slices.Compact(out)
+}
+
+// split packs statements into as few documents of at most limit characters
+// as it can, largest statements first.
+func split(statements []*policy.Policy, limit int) ([][]*policy.Policy, error) {
+	if render.Aws(statements).Size() <= limit {
+		return [][]*policy.Policy{statements}, nil
+	}
+
+	var pieces []*policy.Policy
+	for _, p := range statements {
+		fitted, err := fit(p, limit)
+		if err != nil {
+			return nil, err
+		}
+		pieces = append(pieces, fitted...)
+	}
+	size := func(p *policy.Policy) int { return render.Aws([]*policy.Policy{p}).Size() }
+	sort.SliceStable(pieces, func(i, j int) bool { return size(pieces[i]) > size(pieces[j]) })
+
+	var docs [][]*policy.Policy
+	for _, p := range pieces {
+		placed := false
+		for i, d := range docs {
+			if render.Aws(append(slices.Clone(d), p)).Size() <= limit {
+				docs[i] = append(d, p)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			docs = append(docs, []*policy.Policy{p})
+		}
+	}
+	return docs, nil
+}
+
+// fit splits a statement that does not fit a document on its own in two by
+// its Action or Resource entries, whichever has more, until every part fits.
+func fit(p *policy.Policy, limit int) ([]*policy.Policy, error) {
+	if render.Aws([]*policy.Policy{p}).Size() <= limit {
+		return []*policy.Policy{p}, nil
+	}
+	first, second := *p, *p
+	switch {
+	case len(p.Actions) >= len(p.Resources) && len(p.Actions) > 1:
+		half := len(p.Actions) / 2
+		first.Actions, second.Actions = p.Actions[:half], p.Actions[half:]
+	case len(p.Resources) > 1:
+		half := len(p.Resources) / 2
+		first.Resources, second.Resources = p.Resources[:half], p.Resources[half:]
+	default:
+		return nil, fmt.Errorf("statement for %s does not fit in %d characters", strings.Join(slices.Concat(p.Actions, p.NotActions), ", "), limit)
+	}
+	a, err := fit(&first, limit)
+	if err != nil {
+		return nil, err
+	}
+	b, err := fit(&second, limit)
+	if err != nil {
+		return nil, err
+	}
+	return append(a, b...), nil
+}
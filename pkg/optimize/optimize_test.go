@@ -0,0 +1,139 @@
+package optimize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/compare"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+func TestCollapseActions(t *testing.T) {
+	c := catalog.Default()
+	require.Equal(t, []string{"sqs:<.*>"}, CollapseActions(c, c.Expand("sqs:*")))
+
+	getObject := c.Expand("s3:GetObject*")
+	require.Greater(t, len(getObject), 2)
+	collapsed := CollapseActions(c, append(getObject, "s3:PutObject", "custom:Action", "ec2:Describe<.*>"))
+	require.Contains(t, collapsed, "s3:PutObject")
+	require.Contains(t, collapsed, "custom:Action")
+	require.Contains(t, collapsed, "ec2:Describe<.*>")
+	require.Len(t, collapsed, 4)
+	for _, a := range collapsed {
+		if strings.HasPrefix(a, "s3:") && strings.HasSuffix(a, "<.*>") {
+			// The wildcard matches nothing that was not listed.
+			for _, e := range c.Expand(a) {
+				require.Contains(t, getObject, e)
+			}
+		}
+	}
+
+	// A single action is not worth a wildcard.
+	require.Equal(t, []string{"s3:GetObject"}, CollapseActions(c, []string{"s3:GetObject"}))
+}
+
+func TestOptimize_ActionWildcards(t *testing.T) {
+	original := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Effect": "Allow", "Action": ["ec2:DescribeInstances", "ec2:DescribeImages"], "Resource": "*"},
+		{"Effect": "Allow", "Action": ["s3:GetObject", "s3:GetObjectAcl", "s3:GetObjectTagging", "s3:GetObjectVersion"], "Resource": "arn:aws:s3:::a/*"}]}`)
+	wildcards := func(res *Result) []string {
+		var out []string
+		for _, p := range res.Documents[0].Policies {
+			for _, a := range p.Actions {
+				if strings.Contains(a, "<.*>") {
+					out = append(out, a)
+				}
+			}
+		}
+		return out
+	}
+
+	// The bundled catalog is partial: ec2:DescribeI* may also grant
+	// ec2:DescribeInstanceStatus, which it does not list.
+	res, err := Optimize(original, Options{})
+	require.NoError(t, err)
+	require.Empty(t, wildcards(res))
+	require.True(t, compare.Equal(original, res.Documents[0].Policies))
+
+	complete := *catalog.Default()
+	complete.Complete = true
+	res, err = Optimize(original, Options{Catalog: &complete})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ec2:DescribeI<.*>", "s3:GetO<.*>"}, wildcards(res))
+	require.Less(t, res.Size, res.OriginalSize)
+
+	res, err = Optimize(original, Options{CollapseActions: true})
+	require.NoError(t, err)
+	require.NotEmpty(t, wildcards(res))
+}
+
+func TestOptimize(t *testing.T) {
+	original := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "A", "Effect": "Allow", "Action": ["s3:GetObject", "S3:GetObject", "s3:ListBucket"], "Resource": "arn:aws:s3:::a/*"},
+		{"Sid": "B", "Effect": "Allow", "Action": ["s3:GetObject", "s3:ListBucket"], "Resource": "arn:aws:s3:::b/*"},
+		{"Sid": "C", "Effect": "Deny", "Action": "s3:DeleteObject", "Resource": "*"}]}`)
+	res, err := Optimize(original, Options{})
+	require.NoError(t, err)
+	require.Len(t, res.Documents, 1)
+	doc := res.Documents[0]
+	require.Len(t, doc.Policies, 2)
+	require.Less(t, res.Size, res.OriginalSize)
+	require.Equal(t, res.Size, doc.Size)
+
+	data, err := doc.Aws.Json()
+	require.NoError(t, err)
+	optimized := testutil.Parse(t, string(data))
+	require.Equal(t, compare.Equivalent, compare.Compare(original, optimized).Relation)
+
+	compact, err := json.Marshal(doc.Aws)
+	require.NoError(t, err)
+	require.Equal(t, len(compact), doc.Size)
+}
+
+func TestOptimize_Split(t *testing.T) {
+	var statements []string
+	for i := range 40 {
+		statements = append(statements, fmt.Sprintf(
+			`{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket-%02d/*", "Condition": {"StringEquals": {"aws:ResourceTag/team": "team-%02d"}}}`, i, i))
+	}
+	original := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [`+strings.Join(statements, ",")+`]}`)
+
+	res, err := Optimize(original, Options{Limit: 2048})
+	require.NoError(t, err)
+	require.Greater(t, len(res.Documents), 1)
+	var all []*policy.Policy
+	for _, d := range res.Documents {
+		require.LessOrEqual(t, d.Size, 2048)
+		all = append(all, d.Policies...)
+	}
+	require.True(t, compare.Equal(original, all))
+}
+
+func TestOptimize_SplitStatement(t *testing.T) {
+	var resources []string
+	for i := range 300 {
+		resources = append(resources, fmt.Sprintf(`"arn:aws:s3:::bucket-%03d/*"`, i))
+	}
+	original := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": [`+strings.Join(resources, ",")+`]}]}`)
+
+	res, err := Optimize(original, Options{Limit: ManagedPolicyLimit})
+	require.NoError(t, err)
+	require.Greater(t, len(res.Documents), 1)
+	count := 0
+	for _, d := range res.Documents {
+		require.LessOrEqual(t, d.Size, ManagedPolicyLimit)
+		for _, p := range d.Policies {
+			count += len(p.Resources)
+		}
+	}
+	require.Equal(t, 300, count)
+
+	_, err = Optimize(original, Options{Limit: 50})
+	require.ErrorContains(t, err, "does not fit in 50 characters")
+}
@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/paullesiak/policyparser/internal/aws"
+)
+
+type AuthorizationDetails = aws.AuthorizationDetails
+
+type Entity = aws.Entity
+
+// ImportAuthorizationDetails parses every policy in a saved
+// `aws iam get-account-authorization-details` dump.
+func ImportAuthorizationDetails(filename string) (_ *AuthorizationDetails, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open authorization details %q: %w", filename, err)
+	}
+	defer func() {
+		closeErr := file.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("close authorization details %q: %w", filename, closeErr)
+		}
+	}()
+
+	return aws.ImportAuthorizationDetails(file)
+}
@@ -0,0 +1,190 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Document is a single policy document handed to ParseBatch.
+type Document struct {
+	Name     string `json:"name" yaml:"name"`         // identifies the document in results, usually a file path
+	Provider string `json:"provider" yaml:"provider"` // aws, azure, gcp or auto, defaults to BatchOptions.Provider
+	Text     string `json:"text" yaml:"text"`         // policy text
+	Escaped  bool   `json:"escaped" yaml:"escaped"`   // policy text is URL escaped
+}
+
+type BatchOptions struct {
+	Workers  int    // size of the worker pool, defaults to GOMAXPROCS
+	Provider string // provider for documents that do not set one, defaults to auto
+	Escaped  bool   // used by ParseFiles for every file it reads
+}
+
+// Result is the outcome of parsing one document. Results are reported in
+// input order.
+type Result struct {
+	Name     string           `json:"name" yaml:"name"`
+	Policies []*policy.Policy `json:"policies" yaml:"policies"`
+	Err      error            `json:"-" yaml:"-"`
+	Error    string           `json:"error,omitempty" yaml:"error,omitempty"` // text of Err, for reports
+	Duration time.Duration    `json:"duration" yaml:"duration"`
+}
+
+type BatchReport struct {
+	Results []Result      `json:"results" yaml:"results"`
+	Elapsed time.Duration `json:"elapsed" yaml:"elapsed"` // wall clock time of the whole batch
+	Parsing time.Duration `json:"parsing" yaml:"parsing"` // sum of the per document durations
+	Failed  int           `json:"failed" yaml:"failed"`   // number of documents with an error
+}
+
+// Errors returns the errors of all failed documents, keyed by document name.
+func (r *BatchReport) Errors() map[string]error {
+	errs := map[string]error{}
+	for _, res := range r.Results {
+		if res.Err != nil {
+			errs[res.Name] = res.Err
+		}
+	}
+	return errs
+}
+
+// ParseBatch parses docs concurrently with a bounded worker pool. Documents that
+// have not started when ctx is cancelled are reported with ctx.Err().
+func ParseBatch(ctx context.Context, docs []Document, opts BatchOptions) *BatchReport {
+	names := make([]string, len(docs))
+	for i, doc := range docs {
+		names[i] = doc.Name
+	}
+	return runBatch(ctx, names, opts, func(i int) Result {
+		return parseDocument(docs[i], opts)
+	})
+}
+
+// ParseFiles parses every file matched by pattern. Pattern is either a
+// directory, which is walked recursively for *.json files, or a glob.
+func ParseFiles(ctx context.Context, pattern string, opts BatchOptions) (*BatchReport, error) {
+	files, err := expandPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return runBatch(ctx, files, opts, func(i int) Result {
+		text, err := os.ReadFile(files[i])
+		if err != nil {
+			return Result{Name: files[i], Err: fmt.Errorf("read policy file %q: %w", files[i], err)}
+		}
+		return parseDocument(Document{Name: files[i], Text: string(text), Escaped: opts.Escaped}, opts)
+	}), nil
+}
+
+func runBatch(ctx context.Context, names []string, opts BatchOptions, parse func(int) Result) *BatchReport {
+	n := len(names)
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, n)
+
+	start := time.Now()
+	report := &BatchReport{Results: make([]Result, n)}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					report.Results[i] = Result{Name: names[i], Err: err}
+					continue
+				}
+				report.Results[i] = parseRecovered(names[i], parse, i)
+			}
+		})
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.Elapsed = time.Since(start)
+	for i, res := range report.Results {
+		report.Parsing += res.Duration
+		if res.Err != nil {
+			report.Results[i].Error = res.Err.Error()
+			report.Failed++
+		}
+	}
+	return report
+}
+
+// parseRecovered runs parse for document i and reports a panic, such as one
+// raised by a parser on an unexpected document, as the document's error so
+// that the rest of the batch still runs.
+func parseRecovered(name string, parse func(int) Result, i int) (res Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = Result{Name: name, Err: fmt.Errorf("parser panic: %v", r)}
+		}
+	}()
+	return parse(i)
+}
+
+func parseDocument(doc Document, opts BatchOptions) (res Result) {
+	start := time.Now()
+	res.Name = doc.Name
+	defer func() { res.Duration = time.Since(start) }()
+
+	provider := doc.Provider
+	if provider == "" {
+		provider = opts.Provider
+	}
+	if provider == "" {
+		provider = Auto
+	}
+
+	p, err := NewParser(provider, doc.Text, doc.Escaped)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if err := p.Parse(); err != nil {
+		res.Err = err
+		return res
+	}
+	res.Policies, res.Err = p.GetPolicy()
+	return res
+}
+
+func expandPattern(pattern string) ([]string, error) {
+	info, err := os.Stat(pattern)
+	if err == nil && info.IsDir() {
+		var files []string
+		err := filepath.WalkDir(pattern, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".json") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk %q: %w", pattern, err)
+		}
+		return files, nil
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob %q: %w", pattern, err)
+	}
+	return files, nil
+}
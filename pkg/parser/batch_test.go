@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const batchPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{"Sid": "%s", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]
+}`
+
+func TestParseBatch(t *testing.T) {
+	var docs []Document
+	for i := range 50 {
+		docs = append(docs, Document{Name: fmt.Sprintf("doc%d", i), Provider: Aws, Text: fmt.Sprintf(batchPolicy, fmt.Sprintf("S%d", i))})
+	}
+	docs = append(docs, Document{Name: "broken", Provider: Aws, Text: `{"Statement": [`})
+
+	report := ParseBatch(context.Background(), docs, BatchOptions{Workers: 4})
+	require.Len(t, report.Results, len(docs))
+	require.Equal(t, 1, report.Failed)
+	require.Positive(t, report.Elapsed)
+	require.Positive(t, report.Parsing)
+
+	for i := range 50 {
+		res := report.Results[i]
+		require.Equal(t, fmt.Sprintf("doc%d", i), res.Name)
+		require.NoError(t, res.Err)
+		require.Len(t, res.Policies, 1)
+		require.Equal(t, fmt.Sprintf("S%d", i), res.Policies[0].Id)
+	}
+
+	require.Equal(t, "broken", report.Results[50].Name)
+	require.Error(t, report.Results[50].Err)
+	require.Contains(t, report.Errors(), "broken")
+}
+
+func TestParseBatchBadDocuments(t *testing.T) {
+	docs := []Document{
+		{Name: "ok", Provider: Aws, Text: fmt.Sprintf(batchPolicy, "Ok")},
+		{Name: "id", Provider: Aws, Text: `{"Id": [{"Effect": "Allow"}], "Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]}`},
+	}
+	report := ParseBatch(context.Background(), docs, BatchOptions{Workers: 2})
+	require.Equal(t, 1, report.Failed)
+	require.ErrorContains(t, report.Results[1].Err, "policy Id is not a string")
+
+	// A panicking parser fails its document only.
+	report = runBatch(context.Background(), []string{"a", "b"}, BatchOptions{Workers: 1}, func(i int) Result {
+		if i == 0 {
+			panic("boom")
+		}
+		return Result{Name: "b"}
+	})
+	require.Equal(t, 1, report.Failed)
+	require.Equal(t, "a", report.Results[0].Name)
+	require.ErrorContains(t, report.Results[0].Err, "boom")
+	require.NoError(t, report.Results[1].Err)
+
+	// Errors survive JSON reports.
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"error":"parser panic: boom"`)
+}
+
+func TestParseBatchDefaultsToAutoDetection(t *testing.T) {
+	docs := []Document{{Name: "aws", Text: fmt.Sprintf(batchPolicy, "Auto")}}
+
+	report := ParseBatch(context.Background(), docs, BatchOptions{})
+	require.Zero(t, report.Failed)
+	require.Len(t, report.Results[0].Policies, 1)
+}
+
+func TestParseBatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	docs := []Document{{Name: "a", Provider: Aws, Text: fmt.Sprintf(batchPolicy, "A")}}
+	report := ParseBatch(ctx, docs, BatchOptions{Workers: 1})
+	require.Equal(t, 1, report.Failed)
+	require.Equal(t, "a", report.Results[0].Name)
+	require.ErrorIs(t, report.Results[0].Err, context.Canceled)
+}
+
+func TestParseFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"), fmt.Appendf(nil, batchPolicy, "A"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "b.json"), fmt.Appendf(nil, batchPolicy, "B"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a policy"), 0644))
+
+	t.Run("Directory", func(t *testing.T) {
+		report, err := ParseFiles(context.Background(), dir, BatchOptions{Provider: Aws})
+		require.NoError(t, err)
+		require.Len(t, report.Results, 2)
+		require.Zero(t, report.Failed)
+		require.Equal(t, "A", report.Results[0].Policies[0].Id)
+		require.Equal(t, "B", report.Results[1].Policies[0].Id)
+	})
+
+	t.Run("Glob", func(t *testing.T) {
+		report, err := ParseFiles(context.Background(), filepath.Join(dir, "*.json"), BatchOptions{Provider: Aws})
+		require.NoError(t, err)
+		require.Len(t, report.Results, 1)
+		require.Equal(t, filepath.Join(dir, "a.json"), report.Results[0].Name)
+	})
+
+	t.Run("Bad Glob", func(t *testing.T) {
+		_, err := ParseFiles(context.Background(), "[", BatchOptions{})
+		require.Error(t, err)
+	})
+}
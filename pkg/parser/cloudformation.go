@@ -0,0 +1,19 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/paullesiak/policyparser/internal/aws"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// ExtractCloudFormation parses the IAM policies embedded in a CloudFormation
+// template file, in JSON or YAML.
+func ExtractCloudFormation(filename string) ([]*policy.Policy, error) {
+	template, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read template %q: %w", filename, err)
+	}
+	return aws.ExtractCloudFormation(template)
+}
@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+)
+
+// Document kinds reported by Detect.
+const (
+	KindIdentityPolicy   = "identity-policy"
+	KindResourcePolicy   = "resource-policy"
+	KindTrustPolicy      = "trust-policy"
+	KindIamPolicy        = "iam-policy"
+	KindRoleDefinition   = "role-definition"
+	KindPolicyDefinition = "policy-definition"
+)
+
+// Detection is the result of sniffing a policy document.
+type Detection struct {
+	Provider   string  `json:"provider" yaml:"provider"`     // aws, azure or gcp
+	Kind       string  `json:"kind" yaml:"kind"`             // one of the Kind* constants
+	Confidence float64 `json:"confidence" yaml:"confidence"` // 0 to 1
+}
+
+// Detect guesses the cloud provider and document kind of policyText.
+// URL-escaped documents are unescaped before they are inspected. Documents that
+// are not valid JSON fall back to a keyword scan with a lower confidence.
+func Detect(policyText string) (Detection, error) {
+	text := unescapeForDetection(policyText)
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(text), &doc); err == nil {
+		if d, ok := detectDocument(doc); ok {
+			d.Confidence = math.Round(d.Confidence*100) / 100
+			return d, nil
+		}
+		return Detection{}, fmt.Errorf("unable to detect policy format")
+	}
+
+	if d, ok := detectKeywords(text); ok {
+		return d, nil
+	}
+	return Detection{}, fmt.Errorf("unable to detect policy format")
+}
+
+func detectDocument(doc map[string]any) (Detection, bool) {
+	// Azure ARM exports wrap the interesting part in "properties".
+	if props, ok := lookup(doc, "properties").(map[string]any); ok {
+		if d, ok := detectAzure(props); ok {
+			return d, true
+		}
+	}
+
+	candidates := []Detection{detectAwsDocument(doc), detectGcp(doc)}
+	if d, ok := detectAzure(doc); ok {
+		candidates = append(candidates, d)
+	}
+
+	best := Detection{}
+	for _, c := range candidates {
+		if c.Confidence > best.Confidence {
+			best = c
+		}
+	}
+	return best, best.Confidence > 0
+}
+
+func detectAwsDocument(doc map[string]any) Detection {
+	d := Detection{Provider: Aws, Kind: KindIdentityPolicy}
+
+	statement, ok := doc["Statement"]
+	if !ok {
+		return Detection{}
+	}
+	d.Confidence = 0.6
+
+	var statements []map[string]any
+	switch s := statement.(type) {
+	case []any:
+		for _, item := range s {
+			if m, ok := item.(map[string]any); ok {
+				statements = append(statements, m)
+			}
+		}
+	case map[string]any:
+		statements = append(statements, s)
+	}
+
+	hasEffect := false
+	hasPrincipal := false
+	assumesRole := false
+	for _, s := range statements {
+		if _, ok := s["Effect"]; ok {
+			hasEffect = true
+		}
+		_, principal := s["Principal"]
+		_, notPrincipal := s["NotPrincipal"]
+		if principal || notPrincipal {
+			hasPrincipal = true
+		}
+		for _, action := range stringValues(s["Action"]) {
+			if strings.HasPrefix(strings.ToLower(action), "sts:assumerole") {
+				assumesRole = true
+			}
+		}
+	}
+	if hasEffect {
+		d.Confidence += 0.3
+	}
+	if v, ok := doc["Version"].(string); ok && (v == "2012-10-17" || v == "2008-10-17") {
+		d.Confidence += 0.1
+	}
+
+	switch {
+	case hasPrincipal && assumesRole:
+		d.Kind = KindTrustPolicy
+	case hasPrincipal:
+		d.Kind = KindResourcePolicy
+	}
+	return d
+}
+
+func detectGcp(doc map[string]any) Detection {
+	bindings, ok := lookup(doc, "bindings").([]any)
+	if !ok {
+		return Detection{}
+	}
+	d := Detection{Provider: Gcp, Kind: KindIamPolicy, Confidence: 0.6}
+
+	hasMembers := false
+	hasRole := false
+	for _, b := range bindings {
+		m, ok := b.(map[string]any)
+		if !ok {
+			continue
+		}
+		if lookup(m, "members") != nil {
+			hasMembers = true
+		}
+		if lookup(m, "role") != nil {
+			hasRole = true
+		}
+	}
+	if hasMembers {
+		d.Confidence += 0.3
+	}
+	if hasRole {
+		d.Confidence += 0.1
+	}
+	return d
+}
+
+func detectAzure(doc map[string]any) (Detection, bool) {
+	if lookup(doc, "policyRule") != nil {
+		d := Detection{Provider: Azure, Kind: KindPolicyDefinition, Confidence: 0.9}
+		if rule, ok := lookup(doc, "policyRule").(map[string]any); ok && lookup(rule, "if") != nil && lookup(rule, "then") != nil {
+			d.Confidence = 1.0
+		}
+		return d, true
+	}
+
+	hasActions := lookup(doc, "actions") != nil || lookup(doc, "notActions") != nil
+	if perms, ok := lookup(doc, "permissions").([]any); ok {
+		for _, p := range perms {
+			if m, ok := p.(map[string]any); ok && (lookup(m, "actions") != nil || lookup(m, "dataActions") != nil) {
+				hasActions = true
+			}
+		}
+	}
+	hasScopes := lookup(doc, "assignableScopes") != nil
+
+	d := Detection{Provider: Azure, Kind: KindRoleDefinition}
+	if hasScopes {
+		d.Confidence += 0.5
+	}
+	if hasActions {
+		d.Confidence += 0.4
+	}
+	if hasScopes && hasActions {
+		d.Confidence += 0.1
+	}
+	return d, d.Confidence > 0
+}
+
+// detectKeywords is the fallback for documents that do not decode as JSON, for
+// example truncated or hand-edited files.
+func detectKeywords(text string) (Detection, bool) {
+	has := func(key string) bool {
+		return strings.Contains(strings.ToLower(text), strings.ToLower(`"`+key+`"`))
+	}
+
+	switch {
+	case has("Statement") && has("Effect"):
+		kind := KindIdentityPolicy
+		if has("Principal") || has("NotPrincipal") {
+			kind = KindResourcePolicy
+			if strings.Contains(strings.ToLower(text), "sts:assumerole") {
+				kind = KindTrustPolicy
+			}
+		}
+		return Detection{Provider: Aws, Kind: kind, Confidence: 0.45}, true
+	case has("bindings") && has("members"):
+		return Detection{Provider: Gcp, Kind: KindIamPolicy, Confidence: 0.45}, true
+	case has("policyRule"):
+		return Detection{Provider: Azure, Kind: KindPolicyDefinition, Confidence: 0.45}, true
+	case has("AssignableScopes") && has("Actions"):
+		return Detection{Provider: Azure, Kind: KindRoleDefinition, Confidence: 0.45}, true
+	case has("Statement"):
+		return Detection{Provider: Aws, Kind: KindIdentityPolicy, Confidence: 0.25}, true
+	}
+	return Detection{}, false
+}
+
+// unescapeForDetection URL-decodes text until it stops changing, unless it
+// already looks like a JSON object.
+func unescapeForDetection(text string) string {
+	for !strings.HasPrefix(strings.TrimSpace(text), "{") {
+		unescaped, err := url.QueryUnescape(text)
+		if err != nil || unescaped == text {
+			break
+		}
+		text = unescaped
+	}
+	return text
+}
+
+// lookup returns the value of key in m, matching the key case-insensitively.
+// Azure documents are seen both in PascalCase and camelCase.
+func lookup(m map[string]any, key string) any {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func stringValues(v any) []string {
+	switch x := v.(type) {
+	case string:
+		return []string{x}
+	case []any:
+		var out []string
+		for _, item := range x {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name          string
+		policyText    string
+		provider      string
+		kind          string
+		minConfidence float64
+		expectError   bool
+	}{
+		{
+			name: "AWS Identity Policy",
+			policyText: `{
+				"Version": "2012-10-17",
+				"Statement": [{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}]
+			}`,
+			provider:      Aws,
+			kind:          KindIdentityPolicy,
+			minConfidence: 1.0,
+		},
+		{
+			name: "AWS Resource Policy",
+			policyText: `{
+				"Statement": [{
+					"Effect": "Allow",
+					"Principal": {"AWS": "arn:aws:iam::111122223333:root"},
+					"Action": "s3:GetObject",
+					"Resource": "arn:aws:s3:::bucket/*"
+				}]
+			}`,
+			provider:      Aws,
+			kind:          KindResourcePolicy,
+			minConfidence: 0.9,
+		},
+		{
+			name: "AWS Trust Policy",
+			policyText: `{
+				"Version": "2012-10-17",
+				"Statement": [{
+					"Effect": "Allow",
+					"Principal": {"Service": "ec2.amazonaws.com"},
+					"Action": "sts:AssumeRole"
+				}]
+			}`,
+			provider:      Aws,
+			kind:          KindTrustPolicy,
+			minConfidence: 1.0,
+		},
+		{
+			name: "GCP IAM Policy",
+			policyText: `{
+				"bindings": [{"role": "roles/viewer", "members": ["user:alice@example.com"]}],
+				"etag": "BwWWja0YfJA=",
+				"version": 1
+			}`,
+			provider:      Gcp,
+			kind:          KindIamPolicy,
+			minConfidence: 1.0,
+		},
+		{
+			name: "Azure Role Definition",
+			policyText: `{
+				"Name": "Reader Plus",
+				"Actions": ["*/read"],
+				"NotActions": [],
+				"AssignableScopes": ["/subscriptions/00000000-0000-0000-0000-000000000000"]
+			}`,
+			provider:      Azure,
+			kind:          KindRoleDefinition,
+			minConfidence: 1.0,
+		},
+		{
+			name: "Azure ARM Role Definition",
+			policyText: `{
+				"properties": {
+					"roleName": "Reader Plus",
+					"permissions": [{"actions": ["*/read"], "notActions": []}],
+					"assignableScopes": ["/"]
+				}
+			}`,
+			provider:      Azure,
+			kind:          KindRoleDefinition,
+			minConfidence: 1.0,
+		},
+		{
+			name: "Azure Policy Definition",
+			policyText: `{
+				"properties": {
+					"displayName": "Allowed locations",
+					"policyRule": {
+						"if": {"not": {"field": "location", "in": ["eastus"]}},
+						"then": {"effect": "deny"}
+					}
+				}
+			}`,
+			provider:      Azure,
+			kind:          KindPolicyDefinition,
+			minConfidence: 1.0,
+		},
+		{
+			name:          "URL Escaped AWS Policy",
+			policyText:    url.QueryEscape(`{"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]}`),
+			provider:      Aws,
+			kind:          KindIdentityPolicy,
+			minConfidence: 0.9,
+		},
+		{
+			name:          "Invalid JSON Falls Back To Keywords",
+			policyText:    `{"Statement": [{"Effect": "Allow", "Action": "*",}]`,
+			provider:      Aws,
+			kind:          KindIdentityPolicy,
+			minConfidence: 0.4,
+		},
+		{
+			name:        "Empty Document",
+			policyText:  "{}",
+			expectError: true,
+		},
+		{
+			name:        "Not A Policy",
+			policyText:  "hello world",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Detect(tt.policyText)
+			if tt.expectError {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "unable to detect policy format")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.provider, d.Provider)
+			require.Equal(t, tt.kind, d.Kind)
+			require.GreaterOrEqual(t, d.Confidence, tt.minConfidence)
+			require.LessOrEqual(t, d.Confidence, 1.0)
+		})
+	}
+}
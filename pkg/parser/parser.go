@@ -10,9 +10,11 @@ import (
 )
 
 const (
-	Aws   = "aws"
-	Azure = "azure"
-	Gcp   = "gcp"
+	Aws      = "aws"
+	AwsTrust = "aws-trust" // AWS role trust policy; see aws.NewAwsTrustPolicyParser
+	Azure    = "azure"
+	Gcp      = "gcp"
+	Auto     = "auto"
 )
 
 type Parser interface {
@@ -23,9 +25,21 @@ type Parser interface {
 }
 
 func NewParser(p, policyText string, escaped bool) (Parser, error) {
+	if p == Auto {
+		d, err := Detect(policyText)
+		if err != nil {
+			return nil, err
+		}
+		p = d.Provider
+		if p == Aws && d.Kind == KindTrustPolicy {
+			p = AwsTrust
+		}
+	}
 	switch p {
 	case Aws:
 		return aws.NewAwsPolicyParser(policyText, escaped)
+	case AwsTrust:
+		return aws.NewAwsTrustPolicyParser(policyText, escaped)
 	case Azure:
 		return azure.NewAzurePolicyParser(policyText, escaped)
 	case Gcp:
@@ -33,3 +47,21 @@ func NewParser(p, policyText string, escaped bool) (Parser, error) {
 	}
 	return nil, fmt.Errorf("%s is not a supported cloud provider", p)
 }
+
+// Diagnostics returns the non fatal problems p found while parsing, for parsers
+// that report them.
+func Diagnostics(p Parser) []policy.Diagnostic {
+	if d, ok := p.(interface{ Diagnostics() []policy.Diagnostic }); ok {
+		return d.Diagnostics()
+	}
+	return nil
+}
+
+// Positions returns where each parsed statement starts in the policy text, for
+// parsers that record it.
+func Positions(p Parser) []policy.Position {
+	if d, ok := p.(interface{ Positions() []policy.Position }); ok {
+		return d.Positions()
+	}
+	return nil
+}
@@ -22,6 +22,13 @@ func TestNewParser(t *testing.T) {
 			escaped:     false,
 			expectError: false,
 		},
+		{
+			name:        "AWS Trust Parser",
+			provider:    AwsTrust,
+			policyText:  "{}",
+			escaped:     false,
+			expectError: false,
+		},
 		{
 			name:        "Azure Parser",
 			provider:    Azure,
@@ -36,6 +43,21 @@ func TestNewParser(t *testing.T) {
 			escaped:     false,
 			expectError: false,
 		},
+		{
+			name:        "Auto Detected Parser",
+			provider:    Auto,
+			policyText:  `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*"}]}`,
+			escaped:     false,
+			expectError: false,
+		},
+		{
+			name:        "Auto Detection Failure",
+			provider:    Auto,
+			policyText:  "{}",
+			escaped:     false,
+			expectError: true,
+			errorMsg:    "unable to detect policy format",
+		},
 		{
 			name:        "Unsupported Provider",
 			provider:    "invalid",
@@ -61,3 +83,28 @@ func TestNewParser(t *testing.T) {
 		})
 	}
 }
+
+func TestDiagnostics(t *testing.T) {
+	p, err := NewParser(Aws, `{"Statement": [{"Effect": "Allow", "Action": "*", "Resource": "*",
+		"Condition": {"NumericEquals": {"s3:max-keys": "ten"}}}]}`, false)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	require.Len(t, Diagnostics(p), 1)
+
+	// Auto detection parses trust policies in trust mode.
+	p, err = NewParser(Auto, `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow",
+		"Principal": {"Service": "ec2.amazonaws.com"}, "Action": "sts:AssumeRole", "Resource": "*"}]}`, false)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	require.Equal(t, "statement 0: trust policy statement has a Resource element", Diagnostics(p)[0].String())
+
+	p, err = NewParser(Auto, `{"Version": "2012-10-17", "Statement": [{"Effect": "Allow",
+		"Principal": {"AWS": "111122223333"}, "Action": "s3:GetObject", "Resource": "*"}]}`, false)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse())
+	require.Empty(t, Diagnostics(p))
+
+	p, err = NewParser(Gcp, "{}", false)
+	require.NoError(t, err)
+	require.Nil(t, Diagnostics(p))
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// terraformJson covers both `terraform show -json` formats: a plan carries
+// resource_changes, a state carries values.
+type terraformJson struct {
+	Values          *terraformValues `json:"values"`
+	PlannedValues   *terraformValues `json:"planned_values"`
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Mode    string `json:"mode"`
+		Type    string `json:"type"`
+		Change  struct {
+			Actions []string       `json:"actions"`
+			After   map[string]any `json:"after"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+type terraformValues struct {
+	RootModule terraformModule `json:"root_module"`
+}
+
+type terraformModule struct {
+	Resources []terraformResource `json:"resources"`
+	Children  []terraformModule   `json:"child_modules"`
+}
+
+type terraformResource struct {
+	Address string         `json:"address"`
+	Mode    string         `json:"mode"`
+	Type    string         `json:"type"`
+	Values  map[string]any `json:"values"`
+}
+
+// terraformDocument is a policy document found in a Terraform resource.
+type terraformDocument struct {
+	provider string
+	relation string
+	text     string
+}
+
+// ImportTerraform parses the IAM policies found in the output of
+// `terraform show -json`, for either a plan or a state file. Every policy carries
+// the Terraform address of its resource in Source.Address. Documents that fail
+// to parse, and the Google and Azure documents whose parsers do not produce
+// statements yet, are skipped and their errors joined into the returned error;
+// the policies of the other resources are returned all the same. Callers that
+// only want AWS policies can ignore errors that are ErrUnsupportedCloud.
+func ImportTerraform(filename string) ([]*policy.Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read terraform json %q: %w", filename, err)
+	}
+	return ParseTerraform(data)
+}
+
+// ParseTerraform is ImportTerraform for JSON that is already in memory.
+func ParseTerraform(data []byte) ([]*policy.Policy, error) {
+	var tf terraformJson
+	if err := json.Unmarshal(data, &tf); err != nil {
+		return nil, fmt.Errorf("error decoding terraform json: %w", err)
+	}
+
+	var resources []terraformResource
+	switch {
+	case len(tf.ResourceChanges) > 0:
+		for _, rc := range tf.ResourceChanges {
+			// Resources that are being destroyed have no after state.
+			if rc.Change.After == nil {
+				continue
+			}
+			resources = append(resources, terraformResource{Address: rc.Address, Mode: rc.Mode, Type: rc.Type, Values: rc.Change.After})
+		}
+	case tf.Values != nil:
+		resources = tf.Values.RootModule.flatten()
+	case tf.PlannedValues != nil:
+		resources = tf.PlannedValues.RootModule.flatten()
+	}
+
+	var policies []*policy.Policy
+	var errs []error
+	for _, r := range resources {
+		if r.Mode != "" && r.Mode != "managed" {
+			continue
+		}
+		entity := r.Address
+		if arn, ok := r.Values["arn"].(string); ok && arn != "" {
+			entity = arn
+		}
+		for _, doc := range terraformDocuments(r) {
+			ps, err := parseTerraformDocument(doc)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s policy of %s: %w", doc.relation, r.Address, err))
+				continue
+			}
+			for _, p := range ps {
+				p.Source = &policy.Source{Entity: entity, EntityType: r.Type, Relation: doc.relation, PolicyName: stringAttr(r.Values, "name"), Address: r.Address}
+				policies = append(policies, p)
+			}
+		}
+	}
+	return policies, errors.Join(errs...)
+}
+
+// ErrUnsupportedCloud is returned for documents of clouds whose parser does not
+// produce statements yet, so that they are reported rather than dropped.
+var ErrUnsupportedCloud = errors.New("unsupported cloud")
+
+func parseTerraformDocument(doc terraformDocument) ([]*policy.Policy, error) {
+	if doc.provider != Aws {
+		return nil, fmt.Errorf("%w %s: its policies cannot be parsed yet", ErrUnsupportedCloud, doc.provider)
+	}
+	p, err := NewParser(doc.provider, doc.text, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Parse(); err != nil {
+		return nil, err
+	}
+	return p.GetPolicy()
+}
+
+func (m terraformModule) flatten() []terraformResource {
+	resources := append([]terraformResource{}, m.Resources...)
+	for _, c := range m.Children {
+		resources = append(resources, c.flatten()...)
+	}
+	return resources
+}
+
+// terraformDocuments returns the policy documents held by r, routed to the
+// parser of the resource's provider.
+func terraformDocuments(r terraformResource) []terraformDocument {
+	var docs []terraformDocument
+	addAws := func(attr, relation string) {
+		if text := stringAttr(r.Values, attr); text != "" {
+			docs = append(docs, terraformDocument{provider: Aws, relation: relation, text: text})
+		}
+	}
+
+	switch r.Type {
+	case "aws_iam_policy":
+		addAws("policy", policy.RelationManaged)
+	case "aws_iam_role_policy", "aws_iam_user_policy", "aws_iam_group_policy":
+		addAws("policy", policy.RelationInline)
+	case "aws_iam_role":
+		addAws("assume_role_policy", policy.RelationTrust)
+		inline, _ := r.Values["inline_policy"].([]any)
+		for _, item := range inline {
+			if m, ok := item.(map[string]any); ok && stringAttr(m, "policy") != "" {
+				docs = append(docs, terraformDocument{provider: Aws, relation: policy.RelationInline, text: stringAttr(m, "policy")})
+			}
+		}
+	case "aws_s3_bucket_policy", "aws_sqs_queue_policy", "aws_sns_topic_policy":
+		addAws("policy", policy.RelationResource)
+	case "google_project_iam_policy":
+		if text := stringAttr(r.Values, "policy_data"); text != "" {
+			docs = append(docs, terraformDocument{provider: Gcp, relation: policy.RelationResource, text: text})
+		}
+	case "google_project_iam_binding", "google_project_iam_member":
+		members, _ := r.Values["members"].([]any)
+		if member := stringAttr(r.Values, "member"); member != "" {
+			members = append(members, member)
+		}
+		binding := map[string]any{"role": stringAttr(r.Values, "role"), "members": members}
+		if cond, ok := r.Values["condition"].([]any); ok && len(cond) > 0 {
+			binding["condition"] = cond[0]
+		}
+		if text, err := json.Marshal(map[string]any{"bindings": []any{binding}}); err == nil {
+			docs = append(docs, terraformDocument{provider: Gcp, relation: policy.RelationResource, text: string(text)})
+		}
+	case "azurerm_role_definition":
+		def := map[string]any{
+			"Name":             stringAttr(r.Values, "name"),
+			"Description":      stringAttr(r.Values, "description"),
+			"AssignableScopes": r.Values["assignable_scopes"],
+		}
+		permissions, _ := r.Values["permissions"].([]any)
+		for _, item := range permissions {
+			perm, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			for attr, key := range map[string]string{
+				"actions":          "Actions",
+				"not_actions":      "NotActions",
+				"data_actions":     "DataActions",
+				"not_data_actions": "NotDataActions",
+			} {
+				if v, ok := perm[attr].([]any); ok {
+					existing, _ := def[key].([]any)
+					def[key] = append(existing, v...)
+				}
+			}
+		}
+		if text, err := json.Marshal(def); err == nil {
+			docs = append(docs, terraformDocument{provider: Azure, relation: policy.RelationManaged, text: string(text)})
+		}
+	}
+	return docs
+}
+
+func stringAttr(values map[string]any, attr string) string {
+	s, _ := values[attr].(string)
+	return s
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportTerraformPlan(t *testing.T) {
+	// The plan also holds Google and Azure policies, which are reported as
+	// unsupported rather than dropped.
+	policies, err := ImportTerraform("testdata/terraform-plan.json")
+	require.ErrorIs(t, err, ErrUnsupportedCloud)
+	require.ErrorContains(t, err, "google_project_iam_member.viewer")
+	require.ErrorContains(t, err, "azurerm_role_definition.reader")
+	require.Len(t, policies, 3)
+
+	require.Equal(t, "aws_iam_role.app", policies[0].Source.Address)
+	require.Equal(t, policy.RelationTrust, policies[0].Source.Relation)
+	require.Equal(t, "aws_iam_role", policies[0].Source.EntityType)
+	require.Equal(t, []string{"ec2.amazonaws.com"}, policies[0].Subjects)
+
+	require.Equal(t, "aws_iam_role.app", policies[1].Source.Address)
+	require.Equal(t, policy.RelationInline, policies[1].Source.Relation)
+	require.Equal(t, []string{"sqs:SendMessage"}, policies[1].Actions)
+
+	require.Equal(t, "module.storage.aws_iam_policy.read", policies[2].Source.Address)
+	require.Equal(t, "arn:aws:iam::123456789012:policy/read", policies[2].Source.Entity)
+	require.Equal(t, policy.RelationManaged, policies[2].Source.Relation)
+	require.Equal(t, "Read", policies[2].Id)
+}
+
+func TestImportTerraformState(t *testing.T) {
+	policies, err := ImportTerraform("testdata/terraform-state.json")
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+
+	require.Equal(t, "aws_s3_bucket_policy.public", policies[0].Source.Address)
+	require.Equal(t, policy.RelationResource, policies[0].Source.Relation)
+	require.Equal(t, []string{"<.*>"}, policies[0].Subjects)
+
+	require.Equal(t, "module.iam.aws_iam_role_policy.inline", policies[1].Source.Address)
+	require.Equal(t, "inline", policies[1].Source.PolicyName)
+}
+
+func TestTerraformDocumentsRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource terraformResource
+		provider string
+		text     string
+		actions  []string // actions of the parsed statements; nil when the cloud is unsupported
+	}{
+		{
+			name: "AWS Role Policy",
+			resource: terraformResource{Type: "aws_iam_role_policy", Values: map[string]any{
+				"policy": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`,
+			}},
+			provider: Aws,
+			text:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`,
+			actions:  []string{"s3:GetObject"},
+		},
+		{
+			name: "Google Project IAM Member",
+			resource: terraformResource{Type: "google_project_iam_member", Values: map[string]any{
+				"role": "roles/viewer", "member": "user:alice@example.com",
+			}},
+			provider: Gcp,
+			text:     `{"bindings":[{"members":["user:alice@example.com"],"role":"roles/viewer"}]}`,
+		},
+		{
+			name: "Google Project IAM Policy",
+			resource: terraformResource{Type: "google_project_iam_policy", Values: map[string]any{
+				"policy_data": `{"bindings":[]}`,
+			}},
+			provider: Gcp,
+			text:     `{"bindings":[]}`,
+		},
+		{
+			name: "Azure Role Definition",
+			resource: terraformResource{Type: "azurerm_role_definition", Values: map[string]any{
+				"name":              "reader",
+				"assignable_scopes": []any{"/"},
+				"permissions":       []any{map[string]any{"actions": []any{"*/read"}}},
+			}},
+			provider: Azure,
+			text:     `{"Actions":["*/read"],"AssignableScopes":["/"],"Description":"","Name":"reader"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := terraformDocuments(tt.resource)
+			require.Len(t, docs, 1)
+			require.Equal(t, tt.provider, docs[0].provider)
+			require.JSONEq(t, tt.text, docs[0].text)
+
+			policies, err := parseTerraformDocument(docs[0])
+			if tt.actions == nil {
+				require.ErrorIs(t, err, ErrUnsupportedCloud)
+				require.ErrorContains(t, err, "unsupported cloud "+tt.provider)
+				require.Nil(t, policies)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, policies, 1)
+			require.Equal(t, tt.actions, policies[0].Actions)
+		})
+	}
+}
+
+func TestParseTerraformUnsupportedCloud(t *testing.T) {
+	policies, err := ParseTerraform([]byte(`{"values": {"root_module": {"resources": [
+		{"address": "google_project_iam_member.viewer", "mode": "managed", "type": "google_project_iam_member",
+			"values": {"role": "roles/viewer", "member": "user:alice@example.com"}},
+		{"address": "aws_iam_policy.read", "mode": "managed", "type": "aws_iam_policy",
+			"values": {"policy": "{\"Statement\": [{\"Effect\": \"Allow\", \"Action\": \"s3:GetObject\", \"Resource\": \"*\"}]}"}}
+	]}}}`))
+	require.ErrorIs(t, err, ErrUnsupportedCloud)
+	require.ErrorContains(t, err, "google_project_iam_member.viewer")
+	require.Len(t, policies, 1)
+	require.Equal(t, "aws_iam_policy.read", policies[0].Source.Address)
+}
+
+func TestParseTerraformErrors(t *testing.T) {
+	_, err := ParseTerraform([]byte("{"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error decoding terraform json")
+
+	_, err = ImportTerraform("testdata/missing.json")
+	require.Error(t, err)
+
+	policies, err := ParseTerraform([]byte(`{"values": {"root_module": {"resources": [
+		{"address": "aws_iam_policy.bad", "mode": "managed", "type": "aws_iam_policy", "values": {"policy": "{\"Version\": \"2012-10-17\"}"}}
+	]}}}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "managed policy of aws_iam_policy.bad")
+	require.Empty(t, policies)
+}
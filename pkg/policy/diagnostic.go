@@ -0,0 +1,25 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic is a problem found in a document that did not stop it from
+// parsing, such as a condition value that does not fit its operator.
+type Diagnostic struct {
+	Statement int    `json:"statement" yaml:"statement"` // index of the statement in the document
+	Operator  string `json:"operator" yaml:"operator"`
+	Key       string `json:"key" yaml:"key"`
+	Value     string `json:"value" yaml:"value"`
+	Message   string `json:"message" yaml:"message"`
+}
+
+func (d Diagnostic) String() string {
+	subject := strings.TrimSpace(d.Operator + " " + d.Key)
+	if subject == "" {
+		// Problems of a whole statement, such as those of a trust policy.
+		return fmt.Sprintf("statement %d: %s", d.Statement, d.Message)
+	}
+	return fmt.Sprintf("statement %d: %s: %s", d.Statement, subject, d.Message)
+}
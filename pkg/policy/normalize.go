@@ -0,0 +1,334 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Normalize returns the canonical form of a set of statements, which grants
+// the same access:
+//   - service prefixes of actions are lowercased;
+//   - element entries are sorted, and entries another entry of the same
+//     element covers are dropped, such as s3:GetObject next to s3:*;
+//   - condition operators, keys and values are sorted;
+//   - statements that differ only in their Action, Resource or Principal are
+//     merged, and identical statements are dropped;
+//   - statements are sorted, and their Ids are derived from their content.
+//
+// The Ids do not depend on Sids or the order of statements, so canonical
+// statements can be hashed and diffed. Normalize does not modify policies.
+func Normalize(policies []*Policy) []*Policy {
+	var out []*Policy
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		out = append(out, normalizeStatement(p))
+	}
+	out = mergeStatements(out)
+	sort.Slice(out, func(i, j int) bool { return statementKey(out[i]) < statementKey(out[j]) })
+	for _, p := range out {
+		sum := sha256.Sum256([]byte(statementKey(p)))
+		p.Id = hex.EncodeToString(sum[:6])
+	}
+	return out
+}
+
+// Hash returns a fingerprint of the access policies grant. Policies that
+// normalize to the same statements have the same hash, whatever their Sids,
+// statement order or Source.
+func Hash(policies []*Policy) string {
+	h := sha256.New()
+	for _, p := range Normalize(policies) {
+		h.Write([]byte(statementKey(p)))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func normalizeStatement(p *Policy) *Policy {
+	n := &Policy{
+		Id:           p.Id,
+		Version:      p.Version,
+		Resources:    normalizeEntries(p.Resources, false),
+		NotResources: normalizeEntries(p.NotResources, false),
+		Actions:      normalizeEntries(lowerPrefixes(p.Actions), true),
+		NotActions:   normalizeEntries(lowerPrefixes(p.NotActions), true),
+		Allowed:      p.Allowed,
+		Condition:    normalizeConditions(p.Condition),
+		Source:       p.Source,
+	}
+	n.Subjects, n.SubjectTypes = untypedSubjects(normalizeEntries(typedSubjects(p.Subjects, p.SubjectTypes), false))
+	n.NotSubjects, n.NotSubjectTypes = untypedSubjects(normalizeEntries(typedSubjects(p.NotSubjects, p.NotSubjectTypes), false))
+	return n
+}
+
+// typedSubjects prefixes each subject with its principal type and a NUL, so
+// that subjects of different types sort, compare and merge apart. Subjects
+// without types are returned as they are.
+func typedSubjects(subjects, types []string) []string {
+	if len(types) != len(subjects) {
+		return subjects
+	}
+	out := make([]string, 0, len(subjects))
+	for i, s := range subjects {
+		out = append(out, types[i]+"\x00"+s)
+	}
+	return out
+}
+
+// untypedSubjects reverses typedSubjects. The types are nil when no entry
+// has one.
+func untypedSubjects(entries []string) ([]string, []string) {
+	subjects := make([]string, 0, len(entries))
+	var types []string
+	typed := false
+	for _, e := range entries {
+		t, s, ok := strings.Cut(e, "\x00")
+		if !ok {
+			t, s = "", e
+		}
+		typed = typed || ok
+		subjects = append(subjects, s)
+		types = append(types, t)
+	}
+	if !typed {
+		types = nil
+	}
+	return subjects, types
+}
+
+func lowerPrefixes(actions []string) []string {
+	out := make([]string, 0, len(actions))
+	for _, a := range actions {
+		if prefix, name, ok := strings.Cut(a, ":"); ok {
+			a = strings.ToLower(prefix) + ":" + name
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// normalizeEntries sorts entries and drops those another entry covers. Actions
+// compare ignoring case; of two entries that differ only in case the first in
+// sort order is kept. An absent element becomes an empty list, as the parsers
+// produce.
+func normalizeEntries(entries []string, fold bool) []string {
+	sorted := slices.Clone(entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		if fold && !strings.EqualFold(sorted[i], sorted[j]) {
+			return strings.ToLower(sorted[i]) < strings.ToLower(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	out := []string{}
+	for i, e := range sorted {
+		covered := false
+		for j, other := range sorted {
+			if i == j || !patternCovers(other, e, fold) {
+				continue
+			}
+			// Of two entries that cover each other, keep the first.
+			if !patternCovers(e, other, fold) || j < i {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+type patternToken struct {
+	wildcard byte // 0 for a literal, '?' or '*'
+	ch       byte
+}
+
+func tokenize(pattern string, fold bool) []patternToken {
+	if fold {
+		pattern = strings.ToLower(pattern)
+	}
+	var out []patternToken
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "<.*>"):
+			out = append(out, patternToken{wildcard: '*'})
+			i += len("<.*>")
+		case pattern[i] == '*' || pattern[i] == '?':
+			out = append(out, patternToken{wildcard: pattern[i]})
+			i++
+		default:
+			out = append(out, patternToken{ch: pattern[i]})
+			i++
+		}
+	}
+	return out
+}
+
+// patternCovers reports whether every value pattern b matches is also matched
+// by pattern a. It is conservative: a * in a covers any part of b, a ? in a
+// covers one character or ? of b, and literals must be equal.
+func patternCovers(a, b string, fold bool) bool {
+	if !strings.ContainsAny(a, "*?") {
+		// Also covers <.*>, which contains a *.
+		return a == b || (fold && strings.EqualFold(a, b))
+	}
+	if fold {
+		a, b = strings.ToLower(a), strings.ToLower(b)
+	}
+	// The literal text before the first and after the last wildcard of a must
+	// be literal text of b as well.
+	prefix := a[:strings.IndexAny(a, "*?<")]
+	suffix := a[strings.LastIndexAny(a, "*?>")+1:]
+	if !strings.HasPrefix(b, prefix) || !strings.HasSuffix(b, suffix) {
+		return false
+	}
+	at, bt := tokenize(a, fold), tokenize(b, fold)
+	// covers[i][j] reports whether at[i:] covers bt[j:].
+	covers := make([][]bool, len(at)+1)
+	for i := range covers {
+		covers[i] = make([]bool, len(bt)+1)
+	}
+	covers[len(at)][len(bt)] = true
+	for i := len(at) - 1; i >= 0; i-- {
+		for j := len(bt); j >= 0; j-- {
+			switch at[i].wildcard {
+			case '*':
+				covers[i][j] = covers[i+1][j] || (j < len(bt) && covers[i][j+1])
+			case '?':
+				covers[i][j] = j < len(bt) && bt[j].wildcard != '*' && covers[i+1][j+1]
+			default:
+				covers[i][j] = j < len(bt) && bt[j].wildcard == 0 && bt[j].ch == at[i].ch && covers[i+1][j+1]
+			}
+		}
+	}
+	return covers[0][0]
+}
+
+// normalizeConditions sorts operators, the keys of each operator and the
+// values of each key, and drops duplicate values. Operators without keys and
+// empty Condition blocks restrict nothing and are dropped.
+func normalizeConditions(conditions []Condition) []Condition {
+	var out []Condition
+	for _, c := range conditions {
+		if len(c.Key) == 0 {
+			continue
+		}
+		order := make([]int, len(c.Key))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return c.Key[order[i]] < c.Key[order[j]] })
+
+		n := Condition{Operation: c.Operation, Key: []string{}, Value: []any{}, Type: []string{}}
+		for _, i := range order {
+			n.Key = append(n.Key, c.Key[i])
+			if i < len(c.Value) {
+				n.Value = append(n.Value, sortedValues(c.Value[i]))
+			}
+			if i < len(c.Type) {
+				n.Type = append(n.Type, c.Type[i])
+			}
+		}
+		out = append(out, n)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Operation < out[j].Operation })
+	return out
+}
+
+func sortedValues(raw any) any {
+	switch v := raw.(type) {
+	case []string:
+		s := slices.Clone(v)
+		slices.Sort(s)
+		return slices.Compact(s)
+	case []int64:
+		s := slices.Clone(v)
+		slices.Sort(s)
+		return slices.Compact(s)
+	case []bool:
+		s := slices.Clone(v)
+		slices.SortFunc(s, func(a, b bool) int {
+			switch {
+			case a == b:
+				return 0
+			case !a:
+				return -1
+			}
+			return 1
+		})
+		return slices.Compact(s)
+	}
+	return raw
+}
+
+// dimension is an element statements can be merged on.
+type dimension struct {
+	get func(*Policy) []string
+	set func(*Policy, []string)
+	// fold compares entries ignoring case.
+	fold bool
+}
+
+var dimensions = []dimension{
+	{get: func(p *Policy) []string { return p.Actions }, set: func(p *Policy, v []string) { p.Actions = v }, fold: true},
+	{get: func(p *Policy) []string { return p.Resources }, set: func(p *Policy, v []string) { p.Resources = v }},
+	{get: func(p *Policy) []string { return typedSubjects(p.Subjects, p.SubjectTypes) }, set: func(p *Policy, v []string) {
+		p.Subjects, p.SubjectTypes = untypedSubjects(v)
+	}},
+}
+
+// mergeStatements merges statements that are equal except for the entries of
+// one dimension, until no more statements can be merged. Statements without
+// the element are only merged with identical statements: a missing Resource or
+// Principal does not mean that no entries are listed.
+func mergeStatements(policies []*Policy) []*Policy {
+	for changed := true; changed; {
+		changed = false
+		for _, d := range dimensions {
+			var out []*Policy
+			groups := map[string]*Policy{}
+			for _, p := range policies {
+				entries := d.get(p)
+				rest := *p
+				d.set(&rest, nil)
+				key := statementKey(&rest)
+				if len(entries) == 0 {
+					key = "absent|" + key
+				}
+				if first, ok := groups[key]; ok {
+					if len(entries) > 0 {
+						d.set(first, normalizeEntries(append(slices.Clone(d.get(first)), entries...), d.fold))
+					}
+					changed = true
+					continue
+				}
+				merged := *p
+				groups[key] = &merged
+				out = append(out, &merged)
+			}
+			policies = out
+		}
+	}
+	return policies
+}
+
+// statementKey serializes everything of a statement but its Id and Source.
+// Principal types are part of the subjects.
+func statementKey(p *Policy) string {
+	key, _ := json.Marshal([]any{
+		p.Version, p.Allowed,
+		typedSubjects(p.Subjects, p.SubjectTypes), typedSubjects(p.NotSubjects, p.NotSubjectTypes),
+		p.Actions, p.NotActions,
+		p.Resources, p.NotResources,
+		p.Condition,
+	})
+	return string(key)
+}
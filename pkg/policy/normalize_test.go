@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	policies := []*Policy{
+		{
+			Id: "Write", Allowed: true,
+			Actions:   []string{"S3:PutObject", "s3:GetObject", "s3:<.*>", "S3:GetObject"},
+			Resources: []string{"arn:aws:s3:::b/<.*>", "arn:aws:s3:::b/logs/<.*>"},
+			Condition: []Condition{
+				{Operation: "StringEquals", Key: []string{"aws:SourceVpce", "aws:PrincipalOrgID"}, Value: []any{[]string{"vpce-2", "vpce-1", "vpce-2"}, []string{"o-1"}}, Type: []string{"string", "string"}},
+				{Operation: "Bool", Key: []string{"aws:SecureTransport"}, Value: []any{[]bool{true}}, Type: []string{"bool"}},
+			},
+		},
+		{Id: "doc:1", Allowed: true, Actions: []string{"ec2:DescribeInstances"}, Resources: []string{"<.*>"}},
+		{Id: "doc:2", Allowed: true, Actions: []string{"EC2:DescribeVolumes"}, Resources: []string{"<.*>"}},
+		{Id: "Copy", Allowed: true, Actions: []string{"ec2:DescribeVolumes"}, Resources: []string{"<.*>"}},
+		nil,
+	}
+
+	out := Normalize(policies)
+	require.Len(t, out, 2)
+	require.Equal(t, []string{"ec2:DescribeInstances", "ec2:DescribeVolumes"}, out[0].Actions)
+	require.Equal(t, []string{"<.*>"}, out[0].Resources)
+	require.Equal(t, []string{"s3:<.*>"}, out[1].Actions)
+	require.Equal(t, []string{"arn:aws:s3:::b/<.*>"}, out[1].Resources)
+	require.Equal(t, []Condition{
+		{Operation: "Bool", Key: []string{"aws:SecureTransport"}, Value: []any{[]bool{true}}, Type: []string{"bool"}},
+		{Operation: "StringEquals", Key: []string{"aws:PrincipalOrgID", "aws:SourceVpce"}, Value: []any{[]string{"o-1"}, []string{"vpce-1", "vpce-2"}}, Type: []string{"string", "string"}},
+	}, out[1].Condition)
+	require.Len(t, out[0].Id, 12)
+	require.NotEqual(t, out[0].Id, out[1].Id)
+
+	// The input is left alone.
+	require.Equal(t, "Write", policies[0].Id)
+	require.Len(t, policies[0].Actions, 4)
+
+	// Normalizing is idempotent.
+	require.Equal(t, out, Normalize(out))
+}
+
+func TestNormalize_Merge(t *testing.T) {
+	out := Normalize([]*Policy{
+		{Allowed: true, Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::a/<.*>"}},
+		{Allowed: true, Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::b/<.*>"}},
+		{Allowed: true, Actions: []string{"s3:PutObject"}, Resources: []string{"arn:aws:s3:::a/<.*>", "arn:aws:s3:::b/<.*>"}},
+		// A different effect, or a missing element, keeps statements apart.
+		{Allowed: false, Actions: []string{"s3:DeleteObject"}, Resources: []string{"arn:aws:s3:::a/<.*>", "arn:aws:s3:::b/<.*>"}},
+		{Allowed: true, Actions: []string{"sts:AssumeRole"}, Subjects: []string{"arn:aws:iam::111122223333:root"}},
+		{Allowed: true, Actions: []string{"sts:AssumeRole"}, Subjects: []string{"arn:aws:iam::444455556666:root"}},
+		{Allowed: true, Actions: []string{"sts:TagSession"}},
+	})
+	require.Len(t, out, 4)
+	var merged *Policy
+	for _, p := range out {
+		if len(p.Subjects) > 0 {
+			merged = p
+		}
+	}
+	require.Equal(t, []string{"arn:aws:iam::111122223333:root", "arn:aws:iam::444455556666:root"}, merged.Subjects)
+	require.Equal(t, []string{"sts:AssumeRole"}, merged.Actions)
+	for _, p := range out {
+		if p.Allowed && len(p.Resources) > 0 {
+			require.Equal(t, []string{"s3:GetObject", "s3:PutObject"}, p.Actions)
+			require.Equal(t, []string{"arn:aws:s3:::a/<.*>", "arn:aws:s3:::b/<.*>"}, p.Resources)
+		}
+	}
+}
+
+func TestNormalize_SubjectTypes(t *testing.T) {
+	// Subjects of different principal types are kept apart and keep their
+	// types when statements merge.
+	out := Normalize([]*Policy{
+		{Allowed: true, Actions: []string{"sts:AssumeRole"}, Subjects: []string{"<.*>"}, SubjectTypes: []string{SubjectAws}},
+		{Allowed: true, Actions: []string{"sts:AssumeRole"}, Subjects: []string{"arn:aws:iam::111122223333:root", "ec2.amazonaws.com"},
+			SubjectTypes: []string{SubjectAws, SubjectService}},
+	})
+	require.Len(t, out, 1)
+	require.Equal(t, []string{"<.*>", "ec2.amazonaws.com"}, out[0].Subjects)
+	require.Equal(t, []string{SubjectAws, SubjectService}, out[0].SubjectTypes)
+
+	untyped := Normalize([]*Policy{{Allowed: true, Actions: []string{"sts:AssumeRole"}, Subjects: []string{"b", "a"}}})
+	require.Equal(t, []string{"a", "b"}, untyped[0].Subjects)
+	require.Nil(t, untyped[0].SubjectTypes)
+}
+
+func TestHash(t *testing.T) {
+	a := []*Policy{
+		{Id: "A", Version: "2012-10-17", Allowed: true, Actions: []string{"s3:GetObject", "s3:ListBucket"}, Resources: []string{"<.*>"}},
+	}
+	b := []*Policy{
+		{Id: "doc:1", Version: "2012-10-17", Allowed: true, Actions: []string{"S3:ListBucket"}, Resources: []string{"<.*>"}, Source: &Source{Entity: "role"}},
+		{Id: "doc:0", Version: "2012-10-17", Allowed: true, Actions: []string{"s3:GetObject"}, Resources: []string{"<.*>"}},
+	}
+	c := []*Policy{
+		{Id: "A", Version: "2012-10-17", Allowed: true, Actions: []string{"s3:GetObject", "s3:ListBuckets"}, Resources: []string{"<.*>"}},
+	}
+	require.Equal(t, Hash(a), Hash(b))
+	require.NotEqual(t, Hash(a), Hash(c))
+	require.Len(t, Hash(nil), 64)
+}
+
+func TestPatternCovers(t *testing.T) {
+	tests := []struct {
+		a, b string
+		fold bool
+		want bool
+	}{
+		{"s3:<.*>", "s3:GetObject", true, true},
+		{"s3:Get<.*>", "S3:GETOBJECT", true, true},
+		{"s3:Get<.*>", "S3:GETOBJECT", false, false},
+		{"<.*>", "s3:Get<.*>", false, true},
+		{"s3:Get<.*>", "s3:<.*>", false, false},
+		{"s3:Get?bject", "s3:GetObject", false, true},
+		{"s3:Get?bject", "s3:Get<.*>", false, false},
+		{"arn:aws:s3:::b/<.*>", "arn:aws:s3:::b/logs/<.*>", false, true},
+		{"arn:aws:s3:::b/<.*>/x", "arn:aws:s3:::b/<.*>/<.*>/x", false, true},
+		{"arn:aws:s3:::b", "arn:aws:s3:::b/<.*>", false, false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, patternCovers(tt.a, tt.b, tt.fold), "%s covers %s", tt.a, tt.b)
+	}
+}
@@ -1,16 +1,37 @@
 package policy
 
 type Policy struct {
-	Id           string      `json:"id" yaml:"id"`                       // policy Id
-	Version      string      `json:"version" yaml:"version"`             // policy Version
-	Subjects     []string    `json:"subjects" yaml:"subjects"`           // list of subjects included
-	NotSubjects  []string    `json:"not-subjects" yaml:"not-subjects"`   // list of subjects excluded
-	Resources    []string    `json:"resources" yaml:"resources"`         // list of resources included
-	NotResources []string    `json:"not-resources" yaml:"not-resources"` // list of resources excluded
-	Actions      []string    `json:"actions" yaml:"actions"`             // list of actions included
-	NotActions   []string    `json:"not-actions" yaml:"not-actions"`     // list of actions excluded
-	Allowed      bool        `json:"allowed" yaml:"allowed"`             // effect of a policy match
-	Condition    []Condition `json:"conditions" yaml:"conditions"`       // map key is the operator
+	Id              string      `json:"id" yaml:"id"`                                                   // policy Id
+	Version         string      `json:"version" yaml:"version"`                                         // policy Version
+	Subjects        []string    `json:"subjects" yaml:"subjects"`                                       // list of subjects included
+	NotSubjects     []string    `json:"not-subjects" yaml:"not-subjects"`                               // list of subjects excluded
+	SubjectTypes    []string    `json:"subject-types,omitempty" yaml:"subject-types,omitempty"`         // principal type of each subject, when the syntax records it
+	NotSubjectTypes []string    `json:"not-subject-types,omitempty" yaml:"not-subject-types,omitempty"` // principal type of each excluded subject
+	Resources       []string    `json:"resources" yaml:"resources"`                                     // list of resources included
+	NotResources    []string    `json:"not-resources" yaml:"not-resources"`                             // list of resources excluded
+	Actions         []string    `json:"actions" yaml:"actions"`                                         // list of actions included
+	NotActions      []string    `json:"not-actions" yaml:"not-actions"`                                 // list of actions excluded
+	Allowed         bool        `json:"allowed" yaml:"allowed"`                                         // effect of a policy match
+	Condition       []Condition `json:"conditions" yaml:"conditions"`                                   // map key is the operator
+	Source          *Source     `json:"source,omitempty" yaml:"source,omitempty"`                       // where the policy was imported from
+}
+
+// Principal types of AWS subjects, the keys of a Principal element. A subject
+// of Principal "*" has no type.
+const (
+	SubjectAws       = "AWS"
+	SubjectService   = "Service"
+	SubjectFederated = "Federated"
+	SubjectCanonical = "CanonicalUser"
+)
+
+// SubjectType returns the principal type of Subjects[i], "" when it is not
+// recorded.
+func (p *Policy) SubjectType(i int) string {
+	if len(p.SubjectTypes) != len(p.Subjects) || i >= len(p.SubjectTypes) {
+		return ""
+	}
+	return p.SubjectTypes[i]
 }
 
 type Condition struct {
@@ -19,3 +40,32 @@ type Condition struct {
 	Value     []any    `json:"values" yaml:"values"`         // is a list of either string, int64 or bool
 	Type      []string `json:"value-type" yaml:"value-type"` // string, int64, bool
 }
+
+// Position locates a statement in the text it was parsed from.
+type Position struct {
+	Line   int `json:"line" yaml:"line"`
+	Column int `json:"column" yaml:"column"`
+}
+
+// Relations between a Source entity and the document a policy was parsed from.
+const (
+	RelationInline              = "inline"               // inline policy embedded in the entity
+	RelationManaged             = "managed"              // managed policy attached to the entity
+	RelationTrust               = "trust"                // trust policy of a role
+	RelationResource            = "resource"             // resource-based policy of the entity
+	RelationPermissionsBoundary = "permissions-boundary" // managed policy used as the entity's boundary
+	RelationPolicyVersion       = "policy-version"       // non-default version of a managed policy
+	RelationUnattached          = "unattached"           // managed policy that is not attached to anything
+)
+
+// Source records where a policy came from when it was imported from a larger
+// document, such as an account authorization details dump.
+type Source struct {
+	Entity        string `json:"entity" yaml:"entity"`                                     // ARN or logical name of the user, group, role, policy or resource
+	EntityType    string `json:"entity-type,omitempty" yaml:"entity-type,omitempty"`       // type of the entity, such as role or AWS::S3::BucketPolicy
+	Relation      string `json:"relation" yaml:"relation"`                                 // one of the Relation* constants
+	PolicyName    string `json:"policy-name,omitempty" yaml:"policy-name,omitempty"`       // name of the inline or managed policy
+	PolicyArn     string `json:"policy-arn,omitempty" yaml:"policy-arn,omitempty"`         // ARN of the managed policy
+	PolicyVersion string `json:"policy-version,omitempty" yaml:"policy-version,omitempty"` // version id of the managed policy
+	Address       string `json:"address,omitempty" yaml:"address,omitempty"`               // Terraform address of the resource
+}
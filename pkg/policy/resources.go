@@ -0,0 +1,48 @@
+package policy
+
+import "github.com/paullesiak/policyparser/pkg/arn"
+
+// StructuredResources parses the Resources of the statement as ARNs. Resources
+// that are not ARNs, such as GCP or Azure resource names, are skipped.
+func (p *Policy) StructuredResources() []arn.ARN {
+	var out []arn.ARN
+	for _, r := range p.Resources {
+		if a, err := arn.Parse(r); err == nil {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// StatementsTouchingAccount returns the statements with a resource that can
+// belong to account, including wildcard resources.
+func StatementsTouchingAccount(policies []*Policy, account string) []*Policy {
+	return statementsWhere(policies, func(a arn.ARN) bool {
+		return a.MatchesAccount(account)
+	})
+}
+
+// StatementsTouchingResourceType returns the statements with a resource that
+// can be of the given service and resource type, such as s3 and bucket. An
+// empty resourceType matches every resource of the service.
+func StatementsTouchingResourceType(policies []*Policy, service, resourceType string) []*Policy {
+	return statementsWhere(policies, func(a arn.ARN) bool {
+		return a.MatchesService(service, resourceType)
+	})
+}
+
+func statementsWhere(policies []*Policy, match func(arn.ARN) bool) []*Policy {
+	var out []*Policy
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		for _, a := range p.StructuredResources() {
+			if match(a) {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredResources(t *testing.T) {
+	p := &Policy{Resources: []string{"arn:aws:s3:::my-bucket/<.*>", "projects/my-project", "<.*>"}}
+	resources := p.StructuredResources()
+	require.Len(t, resources, 2)
+	require.Equal(t, "s3", resources[0].Service)
+	require.Equal(t, "object", resources[0].ResourceType)
+	require.Equal(t, "my-bucket/*", resources[0].ResourceId)
+	require.Equal(t, "*", resources[1].Account)
+}
+
+func TestStatementsTouching(t *testing.T) {
+	role := &Policy{Id: "role", Resources: []string{"arn:aws:iam::123456789012:role/Admin"}}
+	otherAccount := &Policy{Id: "other", Resources: []string{"arn:aws:iam::210987654321:user/bob"}}
+	bucket := &Policy{Id: "bucket", Resources: []string{"arn:aws:s3:::my-bucket"}}
+	objects := &Policy{Id: "objects", Resources: []string{"arn:aws:s3:::my-bucket/<.*>"}}
+	everything := &Policy{Id: "everything", Resources: []string{"<.*>"}}
+	noResource := &Policy{Id: "trust", Subjects: []string{"arn:aws:iam::123456789012:root"}}
+	policies := []*Policy{role, otherAccount, bucket, objects, everything, noResource, nil}
+
+	require.Equal(t, []*Policy{role, everything}, StatementsTouchingAccount(policies, "123456789012"))
+	require.Equal(t, []*Policy{bucket, everything}, StatementsTouchingResourceType(policies, "s3", "bucket"))
+	require.Equal(t, []*Policy{bucket, objects, everything}, StatementsTouchingResourceType(policies, "s3", ""))
+	require.Equal(t, []*Policy{otherAccount, everything}, StatementsTouchingResourceType(policies, "iam", "user"))
+}
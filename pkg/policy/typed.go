@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Family groups condition operators by the type of value they compare.
+type Family string
+
+const (
+	FamilyUnknown Family = ""
+	FamilyString  Family = "string"
+	FamilyNumeric Family = "numeric"
+	FamilyDate    Family = "date"
+	FamilyBool    Family = "bool"
+	FamilyBinary  Family = "binary"
+	FamilyIp      Family = "ip"
+	FamilyArn     Family = "arn"
+	FamilyNull    Family = "null"
+)
+
+// operatorFamilies maps the condition operators, without qualifiers and the
+// IfExists suffix, to their family.
+var operatorFamilies = map[string]Family{
+	"StringEquals":              FamilyString,
+	"StringNotEquals":           FamilyString,
+	"StringEqualsIgnoreCase":    FamilyString,
+	"StringNotEqualsIgnoreCase": FamilyString,
+	"StringLike":                FamilyString,
+	"StringNotLike":             FamilyString,
+	"NumericEquals":             FamilyNumeric,
+	"NumericNotEquals":          FamilyNumeric,
+	"NumericLessThan":           FamilyNumeric,
+	"NumericLessThanEquals":     FamilyNumeric,
+	"NumericGreaterThan":        FamilyNumeric,
+	"NumericGreaterThanEquals":  FamilyNumeric,
+	"DateEquals":                FamilyDate,
+	"DateNotEquals":             FamilyDate,
+	"DateLessThan":              FamilyDate,
+	"DateLessThanEquals":        FamilyDate,
+	"DateGreaterThan":           FamilyDate,
+	"DateGreaterThanEquals":     FamilyDate,
+	"Bool":                      FamilyBool,
+	"BinaryEquals":              FamilyBinary,
+	"IpAddress":                 FamilyIp,
+	"NotIpAddress":              FamilyIp,
+	"ArnEquals":                 FamilyArn,
+	"ArnNotEquals":              FamilyArn,
+	"ArnLike":                   FamilyArn,
+	"ArnNotLike":                FamilyArn,
+}
+
+// OperatorFamily returns the family of a condition operator, ignoring the
+// ForAllValues:/ForAnyValue: qualifiers and the IfExists suffix. Operators
+// AWS does not define belong to FamilyUnknown.
+func OperatorFamily(op string) Family {
+	if i := strings.Index(op, ":"); i >= 0 {
+		op = op[i+1:]
+	}
+	if op == "Null" {
+		return FamilyNull
+	}
+	return operatorFamilies[strings.TrimSuffix(op, "IfExists")]
+}
+
+// TypedValue is a condition value parsed according to its operator family.
+// Only the field matching Family is set.
+type TypedValue struct {
+	Family Family
+	Raw    string
+	Number *big.Float
+	Time   time.Time
+	Bool   bool
+	Prefix netip.Prefix // single addresses become a /32 or /128 prefix
+	Binary []byte
+}
+
+// ParseTypedValue parses raw as a value of family. String and ARN values are
+// kept as text, but ARNs must have the six arn:partition:service:region:account:resource
+// segments. Values containing policy variables are not validated.
+func ParseTypedValue(family Family, raw string) (TypedValue, error) {
+	v := TypedValue{Family: family, Raw: raw}
+	if strings.Contains(raw, "${") {
+		return v, nil
+	}
+	s := strings.TrimSpace(raw)
+
+	switch family {
+	case FamilyNumeric:
+		n, ok := new(big.Float).SetString(s)
+		if !ok {
+			return v, fmt.Errorf("%q is not a number", raw)
+		}
+		v.Number = n
+	case FamilyDate:
+		t, ok := ParseDate(s)
+		if !ok {
+			return v, fmt.Errorf("%q is not an ISO 8601 date or epoch time", raw)
+		}
+		v.Time = t
+	case FamilyBool, FamilyNull:
+		if !strings.EqualFold(s, "true") && !strings.EqualFold(s, "false") {
+			return v, fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.Bool = strings.EqualFold(s, "true")
+	case FamilyBinary:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return v, fmt.Errorf("%q is not base64: %w", raw, err)
+		}
+		v.Binary = b
+	case FamilyIp:
+		p, err := ParseIpPrefix(s)
+		if err != nil {
+			return v, err
+		}
+		v.Prefix = p
+	case FamilyArn:
+		if s != "*" && !isArnLike(s) {
+			return v, fmt.Errorf("%q is not an ARN", raw)
+		}
+	}
+	return v, nil
+}
+
+// TypedValues parses the values of the key at index i of c.
+func (c Condition) TypedValues(i int) ([]TypedValue, error) {
+	if i < 0 || i >= len(c.Value) {
+		return nil, fmt.Errorf("condition %s has no value at index %d", c.Operation, i)
+	}
+	family := OperatorFamily(c.Operation)
+	var out []TypedValue
+	for _, raw := range ValueStrings(c.Value[i]) {
+		v, err := ParseTypedValue(family, raw)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// ValueStrings flattens a condition value, one of []string, []int64 or []bool,
+// into strings.
+func ValueStrings(raw any) []string {
+	switch v := raw.(type) {
+	case []string:
+		return v
+	case []int64:
+		out := make([]string, 0, len(v))
+		for _, n := range v {
+			out = append(out, strconv.FormatInt(n, 10))
+		}
+		return out
+	case []bool:
+		out := make([]string, 0, len(v))
+		for _, b := range v {
+			out = append(out, strconv.FormatBool(b))
+		}
+		return out
+	case string:
+		return []string{v}
+	}
+	return nil
+}
+
+// ParseDate parses the date formats accepted by the Date condition operators:
+// ISO 8601 dates and times, and epoch seconds.
+func ParseDate(s string) (time.Time, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0).UTC(), true
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseIpPrefix parses a CIDR block or a single IPv4 or IPv6 address.
+func ParseIpPrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%q is not an IP address or CIDR block", s)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func isArnLike(s string) bool {
+	parts := strings.SplitN(s, ":", 6)
+	return len(parts) == 6 && (parts[0] == "arn" || parts[0] == "*") && parts[2] != "" && parts[5] != ""
+}
@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperatorFamily(t *testing.T) {
+	tests := map[string]Family{
+		"StringEquals":                   FamilyString,
+		"ForAnyValue:StringLikeIfExists": FamilyString,
+		"NumericLessThanEquals":          FamilyNumeric,
+		"DateGreaterThan":                FamilyDate,
+		"Bool":                           FamilyBool,
+		"BoolIfExists":                   FamilyBool,
+		"BinaryEquals":                   FamilyBinary,
+		"IpAddress":                      FamilyIp,
+		"NotIpAddressIfExists":           FamilyIp,
+		"ArnNotLike":                     FamilyArn,
+		"ForAllValues:ArnEquals":         FamilyArn,
+		"Null":                           FamilyNull,
+		"StringEqualsButNotReally":       FamilyUnknown,
+		"Bogus":                          FamilyUnknown,
+	}
+	for op, family := range tests {
+		t.Run(op, func(t *testing.T) {
+			require.Equal(t, family, OperatorFamily(op))
+		})
+	}
+}
+
+func TestParseTypedValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		family Family
+		raw    string
+		check  func(t *testing.T, v TypedValue)
+		errMsg string
+	}{
+		{name: "Number", family: FamilyNumeric, raw: "10.5", check: func(t *testing.T, v TypedValue) {
+			f, _ := v.Number.Float64()
+			require.Equal(t, 10.5, f)
+		}},
+		{name: "Not A Number", family: FamilyNumeric, raw: "ten", errMsg: "is not a number"},
+		{name: "ISO Date", family: FamilyDate, raw: "2024-01-01T00:00:00Z", check: func(t *testing.T, v TypedValue) {
+			require.True(t, v.Time.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		}},
+		{name: "Day", family: FamilyDate, raw: "2024-01-01", check: func(t *testing.T, v TypedValue) {
+			require.Equal(t, 2024, v.Time.Year())
+		}},
+		{name: "Epoch", family: FamilyDate, raw: "1700000000", check: func(t *testing.T, v TypedValue) {
+			require.Equal(t, int64(1700000000), v.Time.Unix())
+		}},
+		{name: "Bad Date", family: FamilyDate, raw: "yesterday", errMsg: "is not an ISO 8601 date"},
+		{name: "Bool", family: FamilyBool, raw: "TRUE", check: func(t *testing.T, v TypedValue) {
+			require.True(t, v.Bool)
+		}},
+		{name: "Bad Bool", family: FamilyBool, raw: "1", errMsg: "is not a boolean"},
+		{name: "Null Value", family: FamilyNull, raw: "false", check: func(t *testing.T, v TypedValue) {
+			require.False(t, v.Bool)
+		}},
+		{name: "CIDR", family: FamilyIp, raw: "192.0.2.7/24", check: func(t *testing.T, v TypedValue) {
+			require.Equal(t, netip.MustParsePrefix("192.0.2.0/24"), v.Prefix)
+		}},
+		{name: "Single IP", family: FamilyIp, raw: "2001:db8::1", check: func(t *testing.T, v TypedValue) {
+			require.Equal(t, netip.MustParsePrefix("2001:db8::1/128"), v.Prefix)
+		}},
+		{name: "Bad CIDR", family: FamilyIp, raw: "192.0.2.0/33", errMsg: "is not an IP address or CIDR block"},
+		{name: "Binary", family: FamilyBinary, raw: "QmluYXJ5", check: func(t *testing.T, v TypedValue) {
+			require.Equal(t, []byte("Binary"), v.Binary)
+		}},
+		{name: "Bad Binary", family: FamilyBinary, raw: "not base64!", errMsg: "is not base64"},
+		{name: "ARN", family: FamilyArn, raw: "arn:aws:sns:*:123456789012:*", check: func(t *testing.T, v TypedValue) {
+			require.Equal(t, "arn:aws:sns:*:123456789012:*", v.Raw)
+		}},
+		{name: "Bad ARN", family: FamilyArn, raw: "sns:topic", errMsg: "is not an ARN"},
+		{name: "Variable Is Not Validated", family: FamilyArn, raw: "${aws:SourceArn}"},
+		{name: "String", family: FamilyString, raw: "anything"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ParseTypedValue(tt.family, tt.raw)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.family, v.Family)
+			require.Equal(t, tt.raw, v.Raw)
+			if tt.check != nil {
+				tt.check(t, v)
+			}
+		})
+	}
+}
+
+func TestConditionTypedValues(t *testing.T) {
+	c := Condition{
+		Operation: "NumericLessThan",
+		Key:       []string{"s3:max-keys", "aws:MultiFactorAuthAge"},
+		Value:     []any{[]int64{10}, []string{"3600", "x"}},
+		Type:      []string{"int64", "string"},
+	}
+
+	values, err := c.TypedValues(0)
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+
+	values, err = c.TypedValues(1)
+	require.Error(t, err)
+	require.Len(t, values, 1)
+
+	_, err = c.TypedValues(2)
+	require.Error(t, err)
+}
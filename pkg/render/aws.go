@@ -0,0 +1,235 @@
+// Package render turns parsed statements back into policy documents of the
+// cloud they came from.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// AwsVersion is the policy language version of rendered documents. Policy
+// variables need it.
+const AwsVersion = "2012-10-17"
+
+// AwsDocument is an IAM policy document. JSON encodes it.
+type AwsDocument struct {
+	Version   string         `json:"Version"`
+	Statement []AwsStatement `json:"Statement"`
+}
+
+// AwsStatement holds the elements of one statement. Elements with a single
+// entry are rendered as a string, like AWS does.
+type AwsStatement struct {
+	Sid          string                    `json:"Sid,omitempty"`
+	Effect       string                    `json:"Effect"`
+	Principal    any                       `json:"Principal,omitempty"`
+	NotPrincipal any                       `json:"NotPrincipal,omitempty"`
+	Action       any                       `json:"Action,omitempty"`
+	NotAction    any                       `json:"NotAction,omitempty"`
+	Resource     any                       `json:"Resource,omitempty"`
+	NotResource  any                       `json:"NotResource,omitempty"`
+	Condition    map[string]map[string]any `json:"Condition,omitempty"`
+}
+
+// Aws renders statements as one IAM policy document.
+func Aws(policies []*policy.Policy) *AwsDocument {
+	doc := &AwsDocument{Version: AwsVersion, Statement: []AwsStatement{}}
+	for _, p := range policies {
+		if p != nil {
+			doc.Statement = append(doc.Statement, AwsStatementOf(p))
+		}
+	}
+	return doc
+}
+
+var sidPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// AwsStatementOf renders one statement. The Id becomes the Sid when it is a
+// valid Sid; the parsers name statements without a Sid <policy id>:<index>.
+func AwsStatementOf(p *policy.Policy) AwsStatement {
+	s := AwsStatement{
+		Effect:       "Deny",
+		Principal:    principal(p.Subjects, p.SubjectTypes),
+		NotPrincipal: principal(p.NotSubjects, p.NotSubjectTypes),
+		Action:       element(p.Actions),
+		NotAction:    element(p.NotActions),
+		Resource:     element(p.Resources),
+		NotResource:  element(p.NotResources),
+		Condition:    conditions(p.Condition),
+	}
+	if p.Allowed {
+		s.Effect = "Allow"
+	}
+	if sidPattern.MatchString(p.Id) {
+		s.Sid = p.Id
+	}
+	return s
+}
+
+// element renders the entries of an element, nil when there are none.
+func element(entries []string) any {
+	switch len(entries) {
+	case 0:
+		return nil
+	case 1:
+		return wildcard(entries[0])
+	}
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, wildcard(e))
+	}
+	return out
+}
+
+func wildcard(s string) string {
+	return strings.ReplaceAll(s, "<.*>", "*")
+}
+
+// principal groups subjects by principal type, the recorded one or else the
+// one PrincipalType guesses. A lone untyped wildcard is rendered as "*",
+// which stands for every principal, including anonymous ones.
+func principal(subjects, types []string) any {
+	if len(subjects) == 0 {
+		return nil
+	}
+	typed := len(types) == len(subjects)
+	if len(subjects) == 1 && subjects[0] == "<.*>" && (!typed || types[0] == "") {
+		return "*"
+	}
+	byType := map[string][]string{}
+	for i, s := range subjects {
+		t := PrincipalType(s)
+		if typed && types[i] != "" {
+			t = types[i]
+		}
+		byType[t] = append(byType[t], s)
+	}
+	out := map[string]any{}
+	for t, entries := range byType {
+		out[t] = element(entries)
+	}
+	return out
+}
+
+// Principal types, the keys of a Principal element.
+const (
+	PrincipalAws       = policy.SubjectAws
+	PrincipalService   = policy.SubjectService
+	PrincipalFederated = policy.SubjectFederated
+	PrincipalCanonical = policy.SubjectCanonical
+)
+
+// federatedProviders are identity providers that are not ARNs.
+var federatedProviders = []string{
+	"cognito-identity.amazonaws.com",
+	"www.amazon.com",
+	"graph.facebook.com",
+	"accounts.google.com",
+}
+
+// PrincipalType guesses the type of a subject from its form, for statements
+// that do not record it: service principals end in amazonaws.com or
+// amazon.com, identity providers are SAML or OIDC provider ARNs or well known
+// hosts, and canonical users are 64 hex digits.
+func PrincipalType(subject string) string {
+	switch {
+	case slices.Contains(federatedProviders, subject),
+		strings.Contains(subject, ":saml-provider/"),
+		strings.Contains(subject, ":oidc-provider/"):
+		return PrincipalFederated
+	case strings.HasSuffix(subject, ".amazonaws.com"), strings.HasSuffix(subject, ".amazonaws.com.cn"),
+		strings.HasSuffix(subject, ".amazon.com"):
+		return PrincipalService
+	case isCanonicalUser(subject):
+		return PrincipalCanonical
+	}
+	return PrincipalAws
+}
+
+func isCanonicalUser(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.Is(unicode.ASCII_Hex_Digit, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// conditions renders a Condition block. Values keep their parsed type.
+func conditions(block []policy.Condition) map[string]map[string]any {
+	if len(block) == 0 {
+		return nil
+	}
+	out := map[string]map[string]any{}
+	for _, c := range block {
+		keys, ok := out[c.Operation]
+		if !ok {
+			keys = map[string]any{}
+			out[c.Operation] = keys
+		}
+		for i, key := range c.Key {
+			var raw any
+			if i < len(c.Value) {
+				raw = c.Value[i]
+			}
+			keys[key] = conditionValue(raw)
+		}
+	}
+	return out
+}
+
+func conditionValue(raw any) any {
+	switch v := raw.(type) {
+	case []string:
+		if len(v) == 1 {
+			return v[0]
+		}
+	case []int64:
+		if len(v) == 1 {
+			return v[0]
+		}
+	case []bool:
+		if len(v) == 1 {
+			return v[0]
+		}
+	}
+	return raw
+}
+
+// Size returns the number of characters of the document as IAM counts them
+// against its size quotas, that is without whitespace outside strings.
+func (d *AwsDocument) Size() int {
+	data, err := d.marshal("")
+	if err != nil {
+		return 0
+	}
+	return utf8.RuneCount(data)
+}
+
+// Json returns the document indented for humans.
+func (d *AwsDocument) Json() ([]byte, error) {
+	return d.marshal("  ")
+}
+
+// marshal encodes the document without escaping <, > and &, which IAM would
+// count as six characters each.
+func (d *AwsDocument) marshal(indent string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
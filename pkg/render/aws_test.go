@@ -0,0 +1,68 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+func TestAws(t *testing.T) {
+	policies := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Trust", "Effect": "Allow", "Action": "sts:AssumeRole",
+			"Principal": {"AWS": ["arn:aws:iam::111122223333:root", "444455556666"], "Service": "ec2.amazonaws.com"}},
+		{"Effect": "Deny", "NotAction": ["iam:*", "sts:*"], "NotResource": "arn:aws:s3:::b/*",
+			"Condition": {"NumericLessThan": {"s3:max-keys": 10}, "Bool": {"aws:SecureTransport": false},
+				"StringLike": {"aws:PrincipalTag/team": ["a*", "b?"]}}}]}`)
+
+	doc := Aws(policies)
+	data, err := doc.Json()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Trust", "Effect": "Allow", "Action": "sts:AssumeRole",
+			"Principal": {"AWS": ["arn:aws:iam::111122223333:root", "444455556666"], "Service": "ec2.amazonaws.com"}},
+		{"Effect": "Deny", "NotAction": ["iam:*", "sts:*"], "NotResource": "arn:aws:s3:::b/*",
+			"Condition": {"NumericLessThan": {"s3:max-keys": 10}, "Bool": {"aws:SecureTransport": false},
+				"StringLike": {"aws:PrincipalTag/team": ["a*", "b?"]}}}]}`, string(data))
+
+	// Parsing the rendered document gives the same statements.
+	again := testutil.Parse(t, string(data))
+	require.Len(t, again, 2)
+	require.Equal(t, policies[0].Subjects, again[0].Subjects)
+	require.Equal(t, policies[1].NotActions, again[1].NotActions)
+	require.Equal(t, policy.Hash(policies), policy.Hash(again))
+}
+
+func TestAws_Principal(t *testing.T) {
+	doc := Aws([]*policy.Policy{
+		{Allowed: true, Subjects: []string{"<.*>"}, Actions: []string{"s3:GetObject"}, Resources: []string{"<.*>"}},
+		{Allowed: true, Subjects: []string{"cognito-identity.amazonaws.com"}, Actions: []string{"sts:AssumeRoleWithWebIdentity"}},
+	})
+	require.Equal(t, "*", doc.Statement[0].Principal)
+	require.Equal(t, "*", doc.Statement[0].Resource)
+	require.Equal(t, map[string]any{PrincipalFederated: "cognito-identity.amazonaws.com"}, doc.Statement[1].Principal)
+
+	// Recorded principal types win over the guess, so {"AWS": "*"} stays an
+	// AWS principal rather than becoming "*".
+	policies := testutil.Parse(t, `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole",
+		"Principal": {"AWS": "*", "Federated": "accounts.google.com"}}]}`)
+	doc = Aws(policies)
+	require.Equal(t, map[string]any{PrincipalAws: "*", PrincipalFederated: "accounts.google.com"}, doc.Statement[0].Principal)
+}
+
+func TestPrincipalType(t *testing.T) {
+	require.Equal(t, PrincipalAws, PrincipalType("arn:aws:iam::111122223333:role/app"))
+	require.Equal(t, PrincipalAws, PrincipalType("111122223333"))
+	require.Equal(t, PrincipalService, PrincipalType("lambda.amazonaws.com"))
+	require.Equal(t, PrincipalFederated, PrincipalType("arn:aws:iam::111122223333:saml-provider/okta"))
+	require.Equal(t, PrincipalFederated, PrincipalType("accounts.google.com"))
+	require.Equal(t, PrincipalCanonical, PrincipalType("79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be"))
+}
+
+func TestSize(t *testing.T) {
+	doc := Aws([]*policy.Policy{{Allowed: true, Actions: []string{"s3:GetObject"}, Resources: []string{"arn:aws:s3:::a&b/<.*>"}}})
+	const compact = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::a&b/*"}]}`
+	require.Equal(t, len(compact), doc.Size())
+}
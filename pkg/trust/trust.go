@@ -0,0 +1,297 @@
+// Package trust checks role trust policies for the mistakes that let callers
+// other than the intended ones assume a role: GitHub Actions trust that every
+// repository can use, third-party trust without an external id, and wildcard
+// federated principals. It relies on the principal types the AWS parser
+// records, so that identity providers are not mistaken for accounts.
+package trust
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/paullesiak/policyparser/pkg/arn"
+	"github.com/paullesiak/policyparser/pkg/eval"
+	"github.com/paullesiak/policyparser/pkg/policy"
+	"github.com/paullesiak/policyparser/pkg/render"
+)
+
+// Config names the account that owns the role and the accounts, such as those
+// of its organization, that are not third parties. Without an Account every
+// account principal is treated as a third party.
+type Config struct {
+	Account         string   `json:"account" yaml:"account"`
+	TrustedAccounts []string `json:"trusted-accounts" yaml:"trusted-accounts"`
+}
+
+type Check string
+
+const (
+	GithubSubject       Check = "github-subject"        // GitHub Actions trust that does not limit the repositories
+	WebIdentityAudience Check = "web-identity-audience" // OIDC trust without a condition on the token claims
+	SamlAudience        Check = "saml-audience"         // SAML trust without a SAML:aud condition
+	ExternalId          Check = "external-id"           // third-party trust without sts:ExternalId
+	WildcardFederated   Check = "wildcard-federated"    // Federated principal with a wildcard
+	WildcardPrincipal   Check = "wildcard-principal"    // any AWS principal without a condition on the caller
+	ActionMismatch      Check = "action-mismatch"       // federated principal without the action its users call
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+type Finding struct {
+	Statement  int      `json:"statement" yaml:"statement"` // index of the statement
+	Id         string   `json:"id" yaml:"id"`
+	Check      Check    `json:"check" yaml:"check"`
+	Severity   Severity `json:"severity" yaml:"severity"`
+	Principals []string `json:"principals" yaml:"principals"`
+	Reason     string   `json:"reason" yaml:"reason"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("statement %d (%s): %s %s for %s: %s", f.Statement, f.Id, f.Severity, f.Check, strings.Join(f.Principals, ", "), f.Reason)
+}
+
+// githubHost is the OIDC provider of GitHub Actions.
+const githubHost = "token.actions.githubusercontent.com"
+
+var (
+	// thirdPartyKeys are condition keys that protect trust in another
+	// account: the external id, or membership of an organization.
+	thirdPartyKeys = []string{"sts:ExternalId", "aws:PrincipalOrgID", "aws:PrincipalOrgPaths"}
+	// callerKeys are condition keys that limit which AWS principals can
+	// assume a role.
+	callerKeys = append([]string{"aws:PrincipalAccount", "aws:PrincipalArn"}, thirdPartyKeys...)
+)
+
+// Analyze reports the problems of the Allow statements of a trust policy.
+// Deny statements are not taken into account.
+func Analyze(policies []*policy.Policy, cfg Config) []Finding {
+	var out []Finding
+	for i, p := range policies {
+		if p == nil || !p.Allowed || len(p.Subjects) == 0 {
+			continue
+		}
+		for _, f := range analyzeStatement(p, cfg) {
+			f.Statement = i
+			f.Id = p.Id
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func analyzeStatement(p *policy.Policy, cfg Config) []Finding {
+	assume := allows(p, "sts:AssumeRole")
+	var out []Finding
+	var anyone, thirdParty []string
+	for i, s := range p.Subjects {
+		switch subjectType(p, i) {
+		case policy.SubjectFederated:
+			out = append(out, checkFederated(p, s)...)
+		case policy.SubjectAws, "":
+			account, ok := accountOf(s)
+			switch {
+			case !ok:
+				anyone = append(anyone, display(s))
+			case account != cfg.Account && !slices.Contains(cfg.TrustedAccounts, account):
+				thirdParty = append(thirdParty, display(s))
+			}
+		}
+	}
+
+	if len(anyone) > 0 && assume && !hasCondition(p, keyIn(callerKeys)) {
+		out = append(out, Finding{Check: WildcardPrincipal, Severity: SeverityError, Principals: anyone,
+			Reason: "principals of any AWS account can assume the role"})
+	}
+	if len(thirdParty) > 0 && assume && !hasCondition(p, keyIn(thirdPartyKeys)) {
+		out = append(out, Finding{Check: ExternalId, Severity: SeverityWarning, Principals: thirdParty,
+			Reason: "third-party accounts can assume the role without an sts:ExternalId condition, which leaves it open to the confused deputy problem"})
+	}
+	return out
+}
+
+// checkFederated checks an identity provider principal.
+func checkFederated(p *policy.Policy, s string) []Finding {
+	principals := []string{display(s)}
+	if strings.ContainsAny(principals[0], "*?") {
+		return []Finding{{Check: WildcardFederated, Severity: SeverityError, Principals: principals,
+			Reason: "the wildcard trusts identity providers other than the intended one"}}
+	}
+
+	if strings.Contains(s, ":saml-provider/") {
+		if !allows(p, "sts:AssumeRoleWithSAML") {
+			return []Finding{mismatch(principals, "sts:AssumeRoleWithSAML")}
+		}
+		if !hasCondition(p, keyIn([]string{"SAML:aud"})) {
+			return []Finding{{Check: SamlAudience, Severity: SeverityWarning, Principals: principals,
+				Reason: "no condition on SAML:aud, so assertions issued for any audience are accepted"}}
+		}
+		return nil
+	}
+
+	host := s
+	if _, after, ok := strings.Cut(s, ":oidc-provider/"); ok {
+		host = after
+	}
+	if !allows(p, "sts:AssumeRoleWithWebIdentity") {
+		return []Finding{mismatch(principals, "sts:AssumeRoleWithWebIdentity")}
+	}
+	if strings.EqualFold(host, githubHost) {
+		return checkGithub(p, principals)
+	}
+	claims := func(key string) bool { return len(key) > len(host) && strings.EqualFold(key[:len(host)+1], host+":") }
+	if !hasCondition(p, claims) {
+		return []Finding{{Check: WebIdentityAudience, Severity: SeverityWarning, Principals: principals,
+			Reason: fmt.Sprintf("no condition on the token claims, such as %s:aud or %s:sub, so tokens issued to any client of the provider are accepted", host, host)}}
+	}
+	return nil
+}
+
+func mismatch(principals []string, action string) Finding {
+	return Finding{Check: ActionMismatch, Severity: SeverityWarning, Principals: principals,
+		Reason: fmt.Sprintf("the statement does not allow %s, which users of the identity provider call, so it never applies to them", action)}
+}
+
+// checkGithub requires a condition on the sub claim that names the owner of
+// the repositories, such as repo:octo-org/*. Any GitHub user can otherwise
+// run a workflow that assumes the role.
+func checkGithub(p *policy.Policy, principals []string) []Finding {
+	key := githubHost + ":sub"
+	values, ok := conditionValues(p, key)
+	if !ok {
+		return []Finding{{Check: GithubSubject, Severity: SeverityError, Principals: principals,
+			Reason: fmt.Sprintf("no condition on %s, so workflows of any GitHub repository can assume the role", key)}}
+	}
+	var broad []string
+	for _, v := range values {
+		if broadSubject(v) {
+			broad = append(broad, v)
+		}
+	}
+	if len(broad) > 0 {
+		return []Finding{{Check: GithubSubject, Severity: SeverityError, Principals: principals,
+			Reason: fmt.Sprintf("the %s condition allows workflows of any repository: %s", key, strings.Join(broad, ", "))}}
+	}
+	return nil
+}
+
+// broadSubject reports whether a sub claim pattern matches repositories of
+// any owner: the literal text before its first wildcard does not name the
+// owner, as repo:octo-org/ does. A pattern without wildcards matches one
+// subject only.
+func broadSubject(v string) bool {
+	v = display(v)
+	i := strings.IndexAny(v, "*?")
+	if i < 0 {
+		return false
+	}
+	rest, ok := strings.CutPrefix(v[:i], "repo:")
+	if !ok {
+		return true
+	}
+	owner, _, ok := strings.Cut(rest, "/")
+	return !ok || owner == ""
+}
+
+// subjectType returns the principal type of subject i, guessing it when the
+// statement does not record types. An untyped wildcard has no type.
+func subjectType(p *policy.Policy, i int) string {
+	if t := p.SubjectType(i); t != "" || len(p.SubjectTypes) == len(p.Subjects) || p.Subjects[i] == arn.Wildcard {
+		return t
+	}
+	return render.PrincipalType(p.Subjects[i])
+}
+
+// accountOf returns the account of an AWS principal, false when it can be
+// any account.
+func accountOf(s string) (string, bool) {
+	if !strings.HasPrefix(s, "arn:") {
+		return s, !strings.ContainsAny(display(s), "*?")
+	}
+	a, err := arn.Parse(s)
+	if err != nil || a.Account == "" || strings.ContainsAny(a.Account, "*?") {
+		return "", false
+	}
+	return a.Account, true
+}
+
+// allows reports whether the Action or NotAction element of p allows action.
+func allows(p *policy.Policy, action string) bool {
+	for _, pattern := range p.Actions {
+		if eval.Match(pattern, action, true, nil) {
+			return true
+		}
+	}
+	if len(p.Actions) > 0 {
+		return false
+	}
+	for _, pattern := range p.NotActions {
+		if eval.Match(pattern, action, true, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasCondition reports whether p requires a key accepted by match to equal
+// or be like some value.
+func hasCondition(p *policy.Policy, match func(key string) bool) bool {
+	for _, c := range p.Condition {
+		if !positive(c.Operation) {
+			continue
+		}
+		for _, k := range c.Key {
+			if match(k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// conditionValues returns the values key is required to equal or be like, and
+// whether any condition requires it.
+func conditionValues(p *policy.Policy, key string) ([]string, bool) {
+	var out []string
+	found := false
+	for _, c := range p.Condition {
+		if !positive(c.Operation) {
+			continue
+		}
+		for j, k := range c.Key {
+			if strings.EqualFold(k, key) && j < len(c.Value) {
+				found = true
+				out = append(out, policy.ValueStrings(c.Value[j])...)
+			}
+		}
+	}
+	return out, found
+}
+
+// positive reports whether op requires a string or ARN to match, rather than
+// not to match or to be absent.
+func positive(op string) bool {
+	family := policy.OperatorFamily(op)
+	base := op
+	if i := strings.Index(base, ":"); i >= 0 {
+		base = base[i+1:]
+	}
+	return (family == policy.FamilyString || family == policy.FamilyArn) && !strings.Contains(base, "Not")
+}
+
+// keyIn matches the condition keys of keys, ignoring case.
+func keyIn(keys []string) func(string) bool {
+	return func(key string) bool {
+		return slices.ContainsFunc(keys, func(k string) bool { return strings.EqualFold(k, key) })
+	}
+}
+
+// display writes the parser's wildcards as *.
+func display(s string) string {
+	return strings.ReplaceAll(s, arn.Wildcard, "*")
+}
@@ -0,0 +1,195 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/parser"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+var cfg = Config{Account: "111122223333", TrustedAccounts: []string{"444455556666"}}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		checks     []Check
+		principals [][]string
+	}{
+		{
+			name: "GitHub Without Subject",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"},
+				"Condition": {"StringEquals": {"token.actions.githubusercontent.com:aud": "sts.amazonaws.com"}}}]}`,
+			checks:     []Check{GithubSubject},
+			principals: [][]string{{"arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"}},
+		},
+		{
+			name: "GitHub Any Repository",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"},
+				"Condition": {"StringLike": {"token.actions.githubusercontent.com:sub": ["repo:octo-org/*", "repo:*"]}}}]}`,
+			checks:     []Check{GithubSubject},
+			principals: [][]string{{"arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"}},
+		},
+		{
+			name: "GitHub Negated Subject",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"},
+				"Condition": {"StringNotEquals": {"token.actions.githubusercontent.com:sub": "repo:evil/repo:ref:refs/heads/main"}}}]}`,
+			checks:     []Check{GithubSubject},
+			principals: [][]string{{"arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"}},
+		},
+		{
+			name: "GitHub Owner Subject",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"},
+				"Condition": {"StringLike": {"token.actions.githubusercontent.com:sub": "repo:octo-org/*"}}}]}`,
+		},
+		{
+			name: "OIDC Without Claims",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/ABC"}}]}`,
+			checks:     []Check{WebIdentityAudience},
+			principals: [][]string{{"arn:aws:iam::111122223333:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/ABC"}},
+		},
+		{
+			name: "OIDC With Subject",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/ABC"},
+				"Condition": {"StringEquals": {"oidc.eks.us-east-1.amazonaws.com/id/ABC:sub": "system:serviceaccount:app:web"}}}]}`,
+		},
+		{
+			name: "Cognito With Audience",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "cognito-identity.amazonaws.com"},
+				"Condition": {"StringEquals": {"cognito-identity.amazonaws.com:aud": "us-east-1:12345678-abcd"}}}]}`,
+		},
+		{
+			name: "Wildcard Federated",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithWebIdentity",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:oidc-provider/*"}}]}`,
+			checks:     []Check{WildcardFederated},
+			principals: [][]string{{"arn:aws:iam::111122223333:oidc-provider/*"}},
+		},
+		{
+			name: "SAML Without Audience",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRoleWithSAML",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:saml-provider/Okta"}}]}`,
+			checks:     []Check{SamlAudience},
+			principals: [][]string{{"arn:aws:iam::111122223333:saml-provider/Okta"}},
+		},
+		{
+			name: "SAML With Audience",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": ["sts:AssumeRoleWithSAML", "sts:TagSession"],
+				"Principal": {"Federated": "arn:aws:iam::111122223333:saml-provider/Okta"},
+				"Condition": {"StringEquals": {"SAML:aud": "https://signin.aws.amazon.com/saml"}}}]}`,
+		},
+		{
+			name: "SAML With AssumeRole",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole",
+				"Principal": {"Federated": "arn:aws:iam::111122223333:saml-provider/Okta"}}]}`,
+			checks:     []Check{ActionMismatch},
+			principals: [][]string{{"arn:aws:iam::111122223333:saml-provider/Okta"}},
+		},
+		{
+			name: "Third Party Without External Id",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole",
+				"Principal": {"AWS": ["arn:aws:iam::999988887777:root", "arn:aws:iam::111122223333:role/admin", "444455556666"]}}]}`,
+			checks:     []Check{ExternalId},
+			principals: [][]string{{"arn:aws:iam::999988887777:root"}},
+		},
+		{
+			name: "Third Party With External Id",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole",
+				"Principal": {"AWS": "arn:aws:iam::999988887777:root"},
+				"Condition": {"StringEquals": {"sts:ExternalId": "d3adb33f"}}}]}`,
+		},
+		{
+			name:       "Any Principal",
+			policy:     `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole", "Principal": {"AWS": "*"}}]}`,
+			checks:     []Check{WildcardPrincipal},
+			principals: [][]string{{"*"}},
+		},
+		{
+			name: "Any Principal Of The Organization",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole", "Principal": "*",
+				"Condition": {"StringEquals": {"aws:PrincipalOrgID": "o-a1b2c3d4e5"}}}]}`,
+		},
+		{
+			name:   "Service Principal",
+			policy: `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole", "Principal": {"Service": "ec2.amazonaws.com"}}]}`,
+		},
+		{
+			name:   "Deny Statement",
+			policy: `{"Statement": [{"Effect": "Deny", "Action": "sts:AssumeRole", "Principal": {"AWS": "*"}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Analyze(testutil.ParseAs(t, parser.AwsTrust, tt.policy), cfg)
+			var checks []Check
+			var principals [][]string
+			for _, f := range findings {
+				checks = append(checks, f.Check)
+				principals = append(principals, f.Principals)
+			}
+			require.Equal(t, tt.checks, checks)
+			require.Equal(t, tt.principals, principals)
+		})
+	}
+}
+
+func TestBroadSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    bool
+	}{
+		{subject: "*", want: true},
+		{subject: "repo:*", want: true},
+		{subject: "repo*", want: true},
+		{subject: "*:ref:refs/heads/main", want: true},
+		{subject: "repo:octo-*", want: true},
+		{subject: "repo:octo-org*", want: true},
+		{subject: "repo:/*", want: true},
+		{subject: "repo:octo-org/*"},
+		{subject: "repo:octo-org/app:ref:refs/heads/*"},
+		{subject: "repo:octo-org/app:ref:refs/heads/main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			require.Equal(t, tt.want, broadSubject(tt.subject))
+		})
+	}
+}
+
+func TestAnalyzeWithoutAccount(t *testing.T) {
+	policies := testutil.ParseAs(t, parser.AwsTrust, `{"Statement": [{"Effect": "Allow", "Action": "sts:AssumeRole",
+		"Principal": {"AWS": "arn:aws:iam::111122223333:root"}}]}`)
+	require.Empty(t, Analyze(policies, cfg))
+
+	findings := Analyze(policies, Config{})
+	require.Len(t, findings, 1)
+	require.Equal(t, ExternalId, findings[0].Check)
+	require.Equal(t, SeverityWarning, findings[0].Severity)
+	require.Equal(t, "statement 0 (:0): warning external-id for arn:aws:iam::111122223333:root: "+
+		"third-party accounts can assume the role without an sts:ExternalId condition, which leaves it open to the confused deputy problem",
+		findings[0].String())
+}
+
+func TestAnalyzeUntypedSubjects(t *testing.T) {
+	// Statements built without principal types fall back to their form.
+	policies := []*policy.Policy{{
+		Id: "Web", Allowed: true, Actions: []string{"sts:AssumeRoleWithWebIdentity"},
+		Subjects: []string{"arn:aws:iam::111122223333:oidc-provider/token.actions.githubusercontent.com"},
+	}}
+	findings := Analyze(policies, cfg)
+	require.Len(t, findings, 1)
+	require.Equal(t, GithubSubject, findings[0].Check)
+	require.Equal(t, SeverityError, findings[0].Severity)
+	require.Equal(t, "Web", findings[0].Id)
+}
@@ -0,0 +1,287 @@
+// Package usage reports which parts of a policy observed access exercised,
+// such as the calls CloudTrail recorded, to find the permissions nobody uses.
+package usage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/paullesiak/policyparser/pkg/catalog"
+	"github.com/paullesiak/policyparser/pkg/cloudtrail"
+	"github.com/paullesiak/policyparser/pkg/eval"
+	"github.com/paullesiak/policyparser/pkg/policy"
+)
+
+// Access is one observed call.
+type Access struct {
+	Time      time.Time `json:"time,omitzero" yaml:"time,omitempty"`
+	Principal string    `json:"principal,omitempty" yaml:"principal,omitempty"` // ARN of the caller, if known
+	Action    string    `json:"action" yaml:"action"`
+	Resources []string  `json:"resources,omitempty" yaml:"resources,omitempty"` // ARNs; none when unknown
+}
+
+// FromEvents returns the accesses of CloudTrail events, with the IAM action
+// cloudtrail.Event.Action maps their event names to, such as s3:ListBucket
+// for ListObjectsV2. Denied calls did not exercise any permission and are
+// left out.
+func FromEvents(events []cloudtrail.Event) []Access {
+	var out []Access
+	for _, e := range events {
+		if e.Denied() {
+			continue
+		}
+		out = append(out, Access{Time: e.Time, Principal: e.Principal, Action: e.Action(), Resources: e.Resources})
+	}
+	return out
+}
+
+// ReadCSV reads accesses from CSV rows of an action, an optional resource ARN
+// and an optional time, a date or RFC 3339 time. A first row starting with
+// the column name action is a header.
+func ReadCSV(r io.Reader) ([]Access, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	var out []Access
+	for first := true; ; first = false {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading access CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(row[0]), "action") {
+			continue
+		}
+		a := Access{Action: strings.TrimSpace(row[0])}
+		if a.Action == "" {
+			return nil, fmt.Errorf("line %d: missing action", line)
+		}
+		if len(row) > 1 && strings.TrimSpace(row[1]) != "" {
+			a.Resources = []string{strings.TrimSpace(row[1])}
+		}
+		if len(row) > 2 && strings.TrimSpace(row[2]) != "" {
+			t, ok := policy.ParseDate(strings.TrimSpace(row[2]))
+			if !ok {
+				return nil, fmt.Errorf("line %d: %q is not a date or RFC 3339 time", line, row[2])
+			}
+			a.Time = t
+		}
+		out = append(out, a)
+	}
+}
+
+// Entry is the use of one Action or Resource entry of a statement.
+type Entry struct {
+	Entry    string    `json:"entry" yaml:"entry"`
+	Count    int       `json:"count" yaml:"count"`
+	LastUsed time.Time `json:"last-used,omitzero" yaml:"last-used,omitempty"`
+}
+
+// Statement is the use of one Allow statement.
+type Statement struct {
+	Id        string    `json:"id" yaml:"id"`
+	Count     int       `json:"count" yaml:"count"`
+	LastUsed  time.Time `json:"last-used,omitzero" yaml:"last-used,omitempty"`
+	Actions   []Entry   `json:"actions,omitempty" yaml:"actions,omitempty"`
+	Resources []Entry   `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
+
+// Used reports whether any access exercised the statement.
+func (s Statement) Used() bool {
+	return s.Count > 0
+}
+
+type Report struct {
+	Statements []Statement `json:"statements" yaml:"statements"`
+	Accesses   int         `json:"accesses" yaml:"accesses"`   // number of accesses read
+	Ungranted  int         `json:"ungranted" yaml:"ungranted"` // accesses no Allow statement grants
+}
+
+// Analyze counts for each Allow statement of policies, and for each of its
+// Action and Resource entries, the accesses that match it, and when the last
+// of them happened. Only the resources of a resource type the action acts on
+// count, such as the object and not the bucket of s3:GetObject. An access
+// without such resources matches any Resource element but counts only for a
+// Resource entry of *, and an access without a
+// principal matches any Principal element. Conditions are not evaluated, as
+// logs do not record the request context, so an access counts for every
+// statement that may have granted it.
+//
+// Deny statements are not reported: the accesses that happened are those
+// they did not deny.
+func Analyze(policies []*policy.Policy, accesses []Access) *Report {
+	r := &Report{Accesses: len(accesses)}
+	for _, p := range policies {
+		if p == nil || !p.Allowed {
+			continue
+		}
+		s := Statement{Id: p.Id}
+		for _, a := range p.Actions {
+			s.Actions = append(s.Actions, Entry{Entry: a})
+		}
+		for _, res := range p.Resources {
+			s.Resources = append(s.Resources, Entry{Entry: res})
+		}
+		r.Statements = append(r.Statements, s)
+	}
+
+	c := catalog.Default()
+	for _, a := range accesses {
+		var resources []string
+		for _, res := range a.Resources {
+			if c.AppliesTo(a.Action, res) {
+				resources = append(resources, res)
+			}
+		}
+		a.Resources = resources
+		granted := false
+		i := 0
+		for _, p := range policies {
+			if p == nil || !p.Allowed {
+				continue
+			}
+			s := &r.Statements[i]
+			i++
+			if !matches(p, a) {
+				continue
+			}
+			granted = true
+			use(&s.Count, &s.LastUsed, a.Time)
+			for j := range s.Actions {
+				if eval.Match(s.Actions[j].Entry, a.Action, true, nil) {
+					use(&s.Actions[j].Count, &s.Actions[j].LastUsed, a.Time)
+				}
+			}
+			for j := range s.Resources {
+				if len(a.Resources) == 0 && s.Resources[j].Entry == "<.*>" {
+					use(&s.Resources[j].Count, &s.Resources[j].LastUsed, a.Time)
+				}
+				for _, res := range a.Resources {
+					if eval.Match(s.Resources[j].Entry, res, false, nil) {
+						use(&s.Resources[j].Count, &s.Resources[j].LastUsed, a.Time)
+						break
+					}
+				}
+			}
+		}
+		if !granted {
+			r.Ungranted++
+		}
+	}
+	return r
+}
+
+func use(count *int, last *time.Time, t time.Time) {
+	*count++
+	if t.After(*last) {
+		*last = t
+	}
+}
+
+// matches reports whether a statement grants an access, ignoring conditions.
+// Policy variables are not resolved, so entries that use them never match.
+func matches(p *policy.Policy, a Access) bool {
+	if !matchAny(p.Actions, p.NotActions, []string{a.Action}, true) {
+		return false
+	}
+	if len(a.Resources) > 0 && !matchAny(p.Resources, p.NotResources, a.Resources, false) {
+		return false
+	}
+	if a.Principal == "" {
+		return true
+	}
+	switch {
+	case len(p.Subjects) > 0:
+		for _, s := range p.Subjects {
+			if eval.MatchPrincipal(s, a.Principal, nil) {
+				return true
+			}
+		}
+		return false
+	case len(p.NotSubjects) > 0:
+		for _, s := range p.NotSubjects {
+			if eval.MatchPrincipal(s, a.Principal, nil) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchAny reports whether any of values matches an element and its Not*
+// counterpart. A statement without either element applies to everything.
+func matchAny(include, exclude, values []string, ignoreCase bool) bool {
+	for _, v := range values {
+		switch {
+		case len(include) > 0:
+			for _, pattern := range include {
+				if eval.Match(pattern, v, ignoreCase, nil) {
+					return true
+				}
+			}
+		case len(exclude) > 0:
+			excluded := false
+			for _, pattern := range exclude {
+				if eval.Match(pattern, v, ignoreCase, nil) {
+					excluded = true
+					break
+				}
+			}
+			if !excluded {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// Unused returns the statements no access exercised.
+func (r *Report) Unused() []Statement {
+	var out []Statement
+	for _, s := range r.Statements {
+		if !s.Used() {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, s := range r.Statements {
+		fmt.Fprintf(&b, "statement %s: %s\n", s.Id, describe(s.Count, s.LastUsed))
+		if !s.Used() {
+			continue
+		}
+		for _, e := range s.Actions {
+			fmt.Fprintf(&b, "  action %s: %s\n", e.Entry, describe(e.Count, e.LastUsed))
+		}
+		for _, e := range s.Resources {
+			fmt.Fprintf(&b, "  resource %s: %s\n", e.Entry, describe(e.Count, e.LastUsed))
+		}
+	}
+	fmt.Fprintf(&b, "%d of %d statements unused, %d of %d accesses not granted\n",
+		len(r.Unused()), len(r.Statements), r.Ungranted, r.Accesses)
+	return b.String()
+}
+
+func describe(count int, last time.Time) string {
+	switch {
+	case count == 0:
+		return "never used"
+	case last.IsZero():
+		return fmt.Sprintf("used %d times", count)
+	}
+	return fmt.Sprintf("used %d times, last %s", count, last.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,168 @@
+package usage
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/paullesiak/policyparser/internal/testutil"
+	"github.com/paullesiak/policyparser/pkg/cloudtrail"
+)
+
+func day(d int) time.Time {
+	return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAnalyze(t *testing.T) {
+	policies := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Objects", "Effect": "Allow", "Action": ["s3:GetObject", "s3:PutObject", "s3:DeleteObject"],
+		 "Resource": ["arn:aws:s3:::artifacts/*", "arn:aws:s3:::logs/*"]},
+		{"Sid": "Metrics", "Effect": "Allow", "Action": "cloudwatch:*", "Resource": "*"},
+		{"Sid": "Admin", "Effect": "Allow", "NotAction": "iam:*", "Resource": "*", "Condition": {"Bool": {"aws:MultiFactorAuthPresent": "true"}}},
+		{"Sid": "NoDelete", "Effect": "Deny", "Action": "s3:DeleteObject", "Resource": "*"}]}`)
+
+	accesses := []Access{
+		{Time: day(1), Action: "s3:GetObject", Resources: []string{"arn:aws:s3:::artifacts/app.zip", "arn:aws:s3:::artifacts"}},
+		{Time: day(3), Action: "s3:getobject", Resources: []string{"arn:aws:s3:::artifacts/app.zip"}},
+		{Time: day(2), Action: "s3:PutObject"},
+		{Time: day(4), Action: "cloudwatch:PutMetricData"},
+		{Time: day(5), Action: "iam:CreateUser"},
+	}
+	report := Analyze(policies, accesses)
+	require.Equal(t, 5, report.Accesses)
+	require.Equal(t, 1, report.Ungranted)
+	require.Len(t, report.Statements, 3)
+
+	objects := report.Statements[0]
+	require.Equal(t, "Objects", objects.Id)
+	require.Equal(t, 3, objects.Count)
+	require.Equal(t, day(3), objects.LastUsed)
+	require.Equal(t, []Entry{
+		{Entry: "s3:GetObject", Count: 2, LastUsed: day(3)},
+		{Entry: "s3:PutObject", Count: 1, LastUsed: day(2)},
+		{Entry: "s3:DeleteObject"},
+	}, objects.Actions)
+	require.Equal(t, []Entry{
+		{Entry: "arn:aws:s3:::artifacts/<.*>", Count: 2, LastUsed: day(3)},
+		{Entry: "arn:aws:s3:::logs/<.*>"},
+	}, objects.Resources)
+
+	// Conditions are not evaluated, so Admin may have granted every non-IAM call.
+	require.Equal(t, 1, report.Statements[1].Count)
+	require.Equal(t, 4, report.Statements[2].Count)
+	require.Empty(t, report.Unused())
+
+	unused := Analyze(policies, accesses[3:])
+	require.Equal(t, []string{"Objects"}, []string{unused.Unused()[0].Id})
+	require.Equal(t, `statement Objects: never used
+statement Metrics: used 1 times, last 2026-03-04T00:00:00Z
+  action cloudwatch:<.*>: used 1 times, last 2026-03-04T00:00:00Z
+  resource <.*>: used 1 times, last 2026-03-04T00:00:00Z
+statement Admin: used 1 times, last 2026-03-04T00:00:00Z
+  resource <.*>: used 1 times, last 2026-03-04T00:00:00Z
+1 of 3 statements unused, 1 of 2 accesses not granted
+`, unused.String())
+}
+
+func TestAnalyzeResourceTypes(t *testing.T) {
+	policies := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Bucket", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::artifacts"},
+		{"Sid": "Objects", "Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::artifacts/*"},
+		{"Sid": "List", "Effect": "Allow", "Action": "s3:ListBucket", "Resource": "arn:aws:s3:::artifacts"}]}`)
+
+	events := []cloudtrail.Event{
+		{Source: "s3.amazonaws.com", Name: "GetObject", Resources: []string{"arn:aws:s3:::artifacts/app.zip", "arn:aws:s3:::artifacts"}},
+		{Source: "s3.amazonaws.com", Name: "ListObjectsV2", Resources: []string{"arn:aws:s3:::artifacts"}},
+	}
+	report := Analyze(policies, FromEvents(events))
+	require.Equal(t, 0, report.Ungranted)
+	// The bucket of a GetObject event is not what s3:GetObject acts on.
+	require.Len(t, report.Unused(), 1)
+	require.Equal(t, "Bucket", report.Unused()[0].Id)
+	require.Equal(t, 1, report.Statements[1].Count)
+	require.Equal(t, 1, report.Statements[2].Count)
+}
+
+func TestAnalyzePrincipals(t *testing.T) {
+	policies := testutil.Parse(t, `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "Deploy", "Effect": "Allow", "Principal": {"AWS": "arn:aws:iam::111122223333:role/deploy"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::artifacts/*"},
+		{"Sid": "Others", "Effect": "Allow", "NotPrincipal": {"AWS": "arn:aws:iam::111122223333:role/deploy"}, "Action": "s3:GetObject", "Resource": "arn:aws:s3:::artifacts/*"}]}`)
+
+	report := Analyze(policies, []Access{
+		{Principal: "arn:aws:sts::111122223333:assumed-role/deploy/build", Action: "s3:GetObject", Resources: []string{"arn:aws:s3:::artifacts/a"}},
+		{Principal: "arn:aws:iam::444455556666:user/bob", Action: "s3:GetObject", Resources: []string{"arn:aws:s3:::artifacts/b"}},
+		{Action: "s3:GetObject", Resources: []string{"arn:aws:s3:::artifacts/c"}},
+		{Action: "s3:GetObject", Resources: []string{"arn:aws:s3:::other/c"}},
+	})
+	require.Equal(t, 2, report.Statements[0].Count)
+	require.Equal(t, 2, report.Statements[1].Count)
+	require.Equal(t, 1, report.Ungranted)
+}
+
+func TestFromEvents(t *testing.T) {
+	data, err := os.ReadFile("../cloudtrail/testdata/trail.json")
+	require.NoError(t, err)
+	events, err := cloudtrail.Read(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	accesses := FromEvents(events)
+	require.Len(t, accesses, 5)
+	require.Equal(t, Access{
+		Time:      time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		Principal: "arn:aws:sts::111122223333:assumed-role/deploy/build",
+		Action:    "s3:GetObject",
+		Resources: []string{"arn:aws:s3:::artifacts/app.zip", "arn:aws:s3:::artifacts"},
+	}, accesses[0])
+	for _, a := range accesses {
+		require.NotEqual(t, "iam:CreateUser", a.Action)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []Access
+		wantErr string
+	}{
+		{
+			name: "Header And Optional Columns",
+			text: "action,resource,time\ns3:GetObject, arn:aws:s3:::artifacts/app.zip, 2026-03-01\n# a comment\nec2:DescribeInstances\nsts:GetCallerIdentity,,2026-03-02T10:00:00Z\n",
+			want: []Access{
+				{Time: day(1), Action: "s3:GetObject", Resources: []string{"arn:aws:s3:::artifacts/app.zip"}},
+				{Action: "ec2:DescribeInstances"},
+				{Time: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), Action: "sts:GetCallerIdentity"},
+			},
+		},
+		{
+			name: "No Header",
+			text: "s3:PutObject,arn:aws:s3:::logs/a\n",
+			want: []Access{{Action: "s3:PutObject", Resources: []string{"arn:aws:s3:::logs/a"}}},
+		},
+		{
+			name:    "Bad Time",
+			text:    "s3:PutObject,arn:aws:s3:::logs/a,yesterday\n",
+			wantErr: `line 1: "yesterday" is not a date`,
+		},
+		{
+			name:    "Missing Action",
+			text:    "action\ns3:GetObject\n,arn:aws:s3:::logs/a\n",
+			wantErr: "line 3: missing action",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCSV(strings.NewReader(tt.text))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}